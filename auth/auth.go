@@ -0,0 +1,165 @@
+// Package auth authenticates the clients of the HTTP transports and limits
+// the tools and devices each of them may use.
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"mcp-android-adb-server/policy"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Scope is the set of tools an identity may call.
+type Scope string
+
+const (
+	// ScopeRead allows the tools that only read the device, for observers.
+	ScopeRead Scope = "read"
+	// ScopeWrite also allows the tools that change the device recoverably.
+	ScopeWrite Scope = "write"
+	// ScopeAdmin allows every tool, including destructive ones.
+	ScopeAdmin Scope = "admin"
+)
+
+var scopeRisks = map[Scope]policy.Risk{
+	ScopeRead:  policy.RiskRead,
+	ScopeWrite: policy.RiskWrite,
+	ScopeAdmin: policy.RiskDestructive,
+}
+
+// MaxRisk returns the highest risk of the tools allowed by the scope.
+func (s Scope) MaxRisk() (policy.Risk, bool) {
+	risk, ok := scopeRisks[s]
+	return risk, ok
+}
+
+// Identity is a client of the HTTP transports, authenticated by a bearer
+// token or the common name of its TLS client certificate.
+type Identity struct {
+	Name     string   `json:"name" yaml:"name" toml:"name"`                // Recorded in the audit log
+	Token    string   `json:"token" yaml:"token" toml:"token"`             // Bearer token
+	ClientCN string   `json:"client_cn" yaml:"client_cn" toml:"client_cn"` // Common name of the client certificate
+	Scope    Scope    `json:"scope" yaml:"scope" toml:"scope"`             // read, write or admin
+	Tools    []string `json:"tools" yaml:"tools" toml:"tools"`             // If set, the only tools allowed
+	Devices  []string `json:"devices" yaml:"devices" toml:"devices"`       // If set, the only device serials and addresses allowed
+}
+
+// Validate returns an error if the identity cannot be used.
+func (id *Identity) Validate() error {
+	if id.Name == "" {
+		return errors.New("name is required")
+	}
+	if id.Token == "" && id.ClientCN == "" {
+		return fmt.Errorf("identity %s needs a token or a client_cn", id.Name)
+	}
+	if _, ok := id.Scope.MaxRisk(); !ok {
+		return fmt.Errorf("identity %s: invalid scope %q, expected read, write or admin", id.Name, id.Scope)
+	}
+	return nil
+}
+
+// CheckTool returns a *policy.DeniedError if the identity may not call a
+// tool of the given risk.
+func (id *Identity) CheckTool(name string, risk policy.Risk) error {
+	subject := fmt.Sprintf("tool %s", name)
+
+	if len(id.Tools) > 0 && !slices.Contains(id.Tools, name) {
+		return &policy.DeniedError{Subject: subject, Reason: fmt.Sprintf("it is not one of the tools allowed to %s", id.Name)}
+	}
+	if maxRisk, _ := id.Scope.MaxRisk(); risk > maxRisk {
+		return &policy.DeniedError{Subject: subject, Reason: fmt.Sprintf("it is a %s operation and %s has the %s scope", risk, id.Name, id.Scope)}
+	}
+	return nil
+}
+
+// CheckDevice returns a *policy.DeniedError if the identity may not use the
+// device with the given serial or address.
+func (id *Identity) CheckDevice(serial string) error {
+	if len(id.Devices) > 0 && !slices.Contains(id.Devices, serial) {
+		return &policy.DeniedError{Subject: fmt.Sprintf("device %s", serial), Reason: fmt.Sprintf("it is not one of the devices allowed to %s", id.Name)}
+	}
+	return nil
+}
+
+// ErrUnauthenticated reports a request without valid credentials.
+var ErrUnauthenticated = errors.New("missing or invalid credentials")
+
+// Authenticator identifies the clients of the HTTP transports.
+type Authenticator struct {
+	identities []Identity
+}
+
+// New returns an authenticator accepting the given identities. Without
+// identities, a verified client certificate authenticates with the admin
+// scope under its common name, so that mTLS alone can guard the server.
+func New(identities []Identity) (*Authenticator, error) {
+	tokens := make(map[string]bool)
+	for i := range identities {
+		if err := identities[i].Validate(); err != nil {
+			return nil, err
+		}
+		if token := identities[i].Token; token != "" {
+			if tokens[token] {
+				return nil, fmt.Errorf("identity %s reuses the token of another identity", identities[i].Name)
+			}
+			tokens[token] = true
+		}
+	}
+
+	return &Authenticator{identities: identities}, nil
+}
+
+// Authenticate returns the identity of a request, or ErrUnauthenticated.
+// A bearer token is checked first, then the verified client certificate.
+func (a *Authenticator) Authenticate(r *http.Request) (*Identity, error) {
+	if token, ok := bearerToken(r); ok {
+		for i := range a.identities {
+			id := &a.identities[i]
+			if id.Token != "" && subtle.ConstantTimeCompare([]byte(id.Token), []byte(token)) == 1 {
+				return id, nil
+			}
+		}
+		return nil, ErrUnauthenticated
+	}
+
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		cn := r.TLS.VerifiedChains[0][0].Subject.CommonName
+		if len(a.identities) == 0 {
+			return &Identity{Name: cn, ClientCN: cn, Scope: ScopeAdmin}, nil
+		}
+		for i := range a.identities {
+			if id := &a.identities[i]; id.ClientCN != "" && id.ClientCN == cn {
+				return id, nil
+			}
+		}
+	}
+
+	return nil, ErrUnauthenticated
+}
+
+// bearerToken returns the token of the Authorization header.
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+type identityKey struct{}
+
+// WithIdentity returns a context carrying the identity of the client.
+func WithIdentity(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// FromContext returns the identity of the client, nil if it was not
+// authenticated, e.g. over stdio.
+func FromContext(ctx context.Context) *Identity {
+	id, _ := ctx.Value(identityKey{}).(*Identity)
+	return id
+}
@@ -0,0 +1,128 @@
+package auth_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"mcp-android-adb-server/auth"
+	"mcp-android-adb-server/policy"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var identities = []auth.Identity{
+	{Name: "observer", Token: "read-token", Scope: auth.ScopeRead, Devices: []string{"emulator-5554"}},
+	{Name: "ci", ClientCN: "ci-runner", Scope: auth.ScopeWrite, Tools: []string{"tap", "screenshot"}},
+	{Name: "admin", Token: "admin-token", Scope: auth.ScopeAdmin},
+}
+
+// withClientCert returns the TLS state of a verified client certificate
+func withClientCert(cn string) *tls.ConnectionState {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: cn}}
+	return &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+}
+
+// TestAuthenticate tests bearer tokens and client certificates
+func TestAuthenticate(t *testing.T) {
+	a, err := auth.New(identities)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		header string
+		tls    *tls.ConnectionState
+		want   string
+	}{
+		"token":             {header: "Bearer admin-token", want: "admin"},
+		"lowercase scheme":  {header: "bearer read-token", want: "observer"},
+		"client cert":       {tls: withClientCert("ci-runner"), want: "ci"},
+		"token before cert": {header: "Bearer read-token", tls: withClientCert("ci-runner"), want: "observer"},
+		"wrong token":       {header: "Bearer nope", tls: withClientCert("ci-runner")},
+		"unknown cert":      {tls: withClientCert("stranger")},
+		"basic auth":        {header: "Basic YWRtaW46YWRtaW4="},
+		"nothing":           {},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/mcp", nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			r.TLS = tt.tls
+
+			id, err := a.Authenticate(r)
+			if tt.want == "" {
+				if !errors.Is(err, auth.ErrUnauthenticated) {
+					t.Errorf("Expected ErrUnauthenticated, got %v %v", id, err)
+				}
+				return
+			}
+			if err != nil || id.Name != tt.want {
+				t.Errorf("Expected %s, got %v %v", tt.want, id, err)
+			}
+		})
+	}
+
+	// Without identities any verified certificate is an admin
+	a, _ = auth.New(nil)
+	r := httptest.NewRequest("POST", "/mcp", nil)
+	r.TLS = withClientCert("lab-host")
+	if id, err := a.Authenticate(r); err != nil || id.Name != "lab-host" || id.Scope != auth.ScopeAdmin {
+		t.Errorf("Expected the certificate to authenticate as admin, got %v %v", id, err)
+	}
+}
+
+// TestCheck tests scopes, tool lists and device lists
+func TestCheck(t *testing.T) {
+	observer, ci, admin := &identities[0], &identities[1], &identities[2]
+
+	tests := []struct {
+		id   *auth.Identity
+		tool string
+		risk policy.Risk
+		ok   bool
+	}{
+		{observer, "screenshot", policy.RiskRead, true},
+		{observer, "tap", policy.RiskWrite, false},
+		{ci, "tap", policy.RiskWrite, true},
+		{ci, "input_text", policy.RiskWrite, false},
+		{admin, "uninstall_app", policy.RiskDestructive, true},
+	}
+	for _, tt := range tests {
+		err := tt.id.CheckTool(tt.tool, tt.risk)
+		var denied *policy.DeniedError
+		if tt.ok && err != nil || !tt.ok && !errors.As(err, &denied) {
+			t.Errorf("%s calling %s: got %v", tt.id.Name, tt.tool, err)
+		}
+	}
+
+	if err := observer.CheckDevice("emulator-5554"); err != nil {
+		t.Errorf("Expected the listed device to be allowed, got %v", err)
+	}
+	if err := observer.CheckDevice("192.168.1.2:5555"); err == nil || !strings.Contains(err.Error(), "observer") {
+		t.Errorf("Expected an unlisted device to be denied, got %v", err)
+	}
+	if err := admin.CheckDevice("192.168.1.2:5555"); err != nil {
+		t.Errorf("Expected any device without a list, got %v", err)
+	}
+}
+
+// TestNew tests that invalid identities are refused
+func TestNew(t *testing.T) {
+	tests := map[string][]auth.Identity{
+		"no name":         {{Token: "t", Scope: auth.ScopeRead}},
+		"no credentials":  {{Name: "a", Scope: auth.ScopeRead}},
+		"invalid scope":   {{Name: "a", Token: "t", Scope: "root"}},
+		"duplicate token": {{Name: "a", Token: "t", Scope: auth.ScopeRead}, {Name: "b", Token: "t", Scope: auth.ScopeAdmin}},
+	}
+
+	for name, ids := range tests {
+		if _, err := auth.New(ids); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
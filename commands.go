@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"mcp-android-adb-server/config"
+	"mcp-android-adb-server/device"
+	"net/url"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// requiredBinaries are the device commands the tools rely on
+var requiredBinaries = []string{"uiautomator", "screencap", "input", "pm", "am", "cmd", "settings", "dumpsys", "wm", "monkey"}
+
+// runDevices lists the devices attached to the ADB server
+func runDevices(args []string) int {
+	fs, configFile := newFlagSet("devices")
+	asJSON := fs.Bool("json", false, "print the devices as JSON")
+	_ = fs.Parse(args)
+
+	cfg, ok := loadConfig(*configFile)
+	if !ok {
+		return 1
+	}
+
+	devices, err := device.ListDevices("")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to list devices: %v\n", err)
+		return 1
+	}
+
+	if *asJSON {
+		if devices == nil {
+			devices = []device.DeviceEntry{}
+		}
+		return printJSON(devices)
+	}
+
+	if len(devices) == 0 {
+		fmt.Println("no devices attached")
+		return 0
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SERIAL\tSTATE\tMODEL\tPRODUCT\tCONFIGURED")
+	for _, e := range devices {
+		configured := ""
+		if e.Serial == cfg.Device.ID {
+			configured = "*"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", e.Serial, e.State, e.Model, e.Product, configured)
+	}
+	_ = w.Flush()
+
+	return 0
+}
+
+// doctor prints the outcome of checks and remembers failures
+type doctor struct {
+	failed bool
+}
+
+func (doc *doctor) ok(format string, args ...any) {
+	fmt.Printf("[ok]   "+format+"\n", args...)
+}
+
+func (doc *doctor) warn(format string, args ...any) {
+	fmt.Printf("[warn] "+format+"\n", args...)
+}
+
+func (doc *doctor) fail(format string, args ...any) {
+	doc.failed = true
+	fmt.Printf("[fail] "+format+"\n", args...)
+}
+
+// runDoctor checks everything the server needs to run
+func runDoctor(args []string) int {
+	fs, configFile := newFlagSet("doctor")
+	_ = fs.Parse(args)
+
+	doc := &doctor{}
+
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		doc.fail("configuration: %v", err)
+		return 1
+	}
+	if *configFile != "" {
+		doc.ok("configuration %s", *configFile)
+	} else {
+		doc.ok("configuration from the environment")
+	}
+
+	doc.checkServer(cfg.Server)
+	doc.checkDevice(cfg)
+	doc.checkVision(cfg.Vision)
+
+	if doc.failed {
+		return 1
+	}
+	return 0
+}
+
+// checkServer checks the TLS files and the authentication of the HTTP
+// transports
+func (doc *doctor) checkServer(c config.ServerConfig) {
+	if c.Transport == "stdio" {
+		doc.ok("transport stdio")
+		return
+	}
+
+	tlsConfig, authenticator, err := httpSecurity(c)
+	switch {
+	case err != nil:
+		doc.fail("transport %s: %v", c.Transport, err)
+	case authenticator == nil:
+		doc.warn("transport %s on %s without authentication", c.Transport, c.Addr)
+	default:
+		doc.ok("transport %s on %s, tls %t, %d identities", c.Transport, c.Addr, tlsConfig != nil, len(c.Identities))
+	}
+}
+
+// checkDevice checks the ADB server, the state of the configured device and
+// the binaries on it
+func (doc *doctor) checkDevice(cfg *config.Config) {
+	version, err := device.AdbServerVersion("")
+	if err != nil {
+		doc.fail("adb server not reachable: %v, start it with adb start-server", err)
+		return
+	}
+	doc.ok("adb server version %d", version)
+
+	devices, err := device.ListDevices("")
+	if err != nil {
+		doc.fail("failed to list devices: %v", err)
+		return
+	}
+
+	id := cfg.Device.ID
+	if id == "" {
+		doc.fail("no device configured, set DEVICE_ID or device.id to one of %d attached devices", len(devices))
+		return
+	}
+
+	state := ""
+	for _, e := range devices {
+		if e.Serial == id {
+			state = e.State
+		}
+	}
+
+	switch state {
+	case "device":
+		doc.ok("device %s is attached", id)
+	case "":
+		if device.IsNetworkSerial(id) {
+			doc.warn("device %s is not connected, the server connects to it on start", id)
+		} else {
+			doc.fail("device %s is not attached", id)
+		}
+		return
+	case "unauthorized":
+		doc.fail("device %s is unauthorized, accept the USB debugging prompt on the device", id)
+		return
+	default:
+		doc.fail("device %s is %s", id, state)
+		return
+	}
+
+	d, err := device.NewAndroidDevice(id, deviceOptions(cfg)...)
+	if err != nil {
+		doc.fail("failed to connect device %s: %v", id, err)
+		return
+	}
+	defer d.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	script := fmt.Sprintf("for b in %s; do command -v $b >/dev/null 2>&1 || echo $b; done", strings.Join(requiredBinaries, " "))
+	result, err := d.WithContext(ctx).Exec(script, device.ExecOptions{})
+	if err != nil {
+		doc.fail("failed to look for device binaries: %v", err)
+		return
+	}
+
+	if missing := strings.Fields(result.Stdout); len(missing) > 0 {
+		doc.fail("missing device binaries: %s", strings.Join(missing, ", "))
+	} else {
+		doc.ok("device binaries: %s", strings.Join(requiredBinaries, ", "))
+	}
+}
+
+// checkVision checks the vision model configuration
+func (doc *doctor) checkVision(vc config.VisionConfig) {
+	if !vc.Configured() {
+		doc.ok("vision not configured, screenshot_description is not registered")
+		return
+	}
+
+	if vc.BaseURL != "" {
+		u, err := url.Parse(vc.BaseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			doc.fail("vision base_url %q is not an http(s) URL", vc.BaseURL)
+			return
+		}
+	}
+	doc.ok("vision model %s", vc.Model)
+}
+
+// runTools prints the schemas of the tools the server would register
+func runTools(args []string) int {
+	fs, configFile := newFlagSet("tools")
+	selectTools := toolFlags(fs)
+	_ = fs.Parse(args)
+
+	cfg, ok := loadConfig(*configFile, selectTools)
+	if !ok {
+		return 1
+	}
+
+	// The hooks log every request, which is noise here
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	s := newServer(cfg, device.NewDetachedDevice(cfg.Device.ID, deviceOptions(cfg)...))
+
+	response := s.HandleMessage(context.Background(), json.RawMessage(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+	data, err := json.Marshal(response)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to list tools: %v\n", err)
+		return 1
+	}
+
+	var list struct {
+		Result struct {
+			Tools json.RawMessage `json:"tools"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil || list.Result.Tools == nil {
+		fmt.Fprintf(os.Stderr, "failed to list tools: %s\n", data)
+		return 1
+	}
+
+	return printJSON(list.Result.Tools)
+}
+
+// runVersion prints the version and how the binary was built
+func runVersion(args []string) int {
+	_ = flag.NewFlagSet("version", flag.ExitOnError).Parse(args)
+
+	revision := ""
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				revision = setting.Value
+			}
+		}
+	}
+
+	fmt.Printf("mcp-android-adb-server %s %s %s/%s", version, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	if revision != "" {
+		fmt.Printf(" %s", revision)
+	}
+	fmt.Println()
+
+	return 0
+}
+
+// printJSON prints v as indented JSON
+func printJSON(v any) int {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,506 @@
+// Package config loads the server configuration from a YAML, JSON or TOML
+// file, overridden by environment variables.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mcp-android-adb-server/auth"
+	"mcp-android-adb-server/device"
+	"mcp-android-adb-server/policy"
+	"mcp-android-adb-server/tools"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// redacted replaces secrets in printed configurations.
+const redacted = "******"
+
+// Config is the configuration of the server.
+type Config struct {
+	Server ServerConfig  `json:"server" yaml:"server" toml:"server"`
+	Device DeviceConfig  `json:"device" yaml:"device" toml:"device"`
+	Tools  ToolsConfig   `json:"tools" yaml:"tools" toml:"tools"`
+	Vision VisionConfig  `json:"vision" yaml:"vision" toml:"vision"`
+	Log    LogConfig     `json:"log" yaml:"log" toml:"log"`
+	Output OutputConfig  `json:"output" yaml:"output" toml:"output"`
+	Policy policy.Policy `json:"policy" yaml:"policy" toml:"policy"`
+}
+
+// ServerConfig configures the transport of the MCP server.
+type ServerConfig struct {
+	Transport       string   `json:"transport" yaml:"transport" toml:"transport"`                      // stdio, sse or http
+	Addr            string   `json:"addr" yaml:"addr" toml:"addr"`                                     // Listen address of the sse and http transports
+	BasePath        string   `json:"base_path" yaml:"base_path" toml:"base_path"`                      // Prefix of the endpoint paths, e.g. /android
+	ShutdownTimeout Duration `json:"shutdown_timeout" yaml:"shutdown_timeout" toml:"shutdown_timeout"` // Time given to tool calls in flight on shutdown
+	AllowedOrigins  []string `json:"allowed_origins" yaml:"allowed_origins" toml:"allowed_origins"`    // Browser origins allowed besides the server itself
+
+	TLS        TLSConfig       `json:"tls" yaml:"tls" toml:"tls"`
+	Identities []auth.Identity `json:"identities" yaml:"identities" toml:"identities"` // Clients allowed on the sse and http transports
+}
+
+// TLSConfig configures HTTPS for the sse and http transports.
+type TLSConfig struct {
+	CertFile     string `json:"cert_file" yaml:"cert_file" toml:"cert_file"`
+	KeyFile      string `json:"key_file" yaml:"key_file" toml:"key_file"`
+	ClientCAFile string `json:"client_ca_file" yaml:"client_ca_file" toml:"client_ca_file"` // Requires client certificates signed by these CAs
+}
+
+// AuthEnabled reports whether clients of the HTTP transports must
+// authenticate, with a bearer token or a client certificate.
+func (c *ServerConfig) AuthEnabled() bool {
+	return len(c.Identities) > 0 || c.TLS.ClientCAFile != ""
+}
+
+// Transports are the values of server.transport.
+var Transports = []string{"stdio", "sse", "http"}
+
+// DeviceConfig configures the Android device.
+type DeviceConfig struct {
+	ID                 string   `json:"id" yaml:"id" toml:"id"` // Serial, or host:port for Wi-Fi
+	ScreenLockPassword string   `json:"screen_lock_password" yaml:"screen_lock_password" toml:"screen_lock_password"`
+	SwipeDuration      Duration `json:"swipe_duration" yaml:"swipe_duration" toml:"swipe_duration"`
+	LongTapDuration    Duration `json:"long_tap_duration" yaml:"long_tap_duration" toml:"long_tap_duration"`
+	SleepDuration      Duration `json:"sleep_duration" yaml:"sleep_duration" toml:"sleep_duration"`
+	ReconnectAttempts  int      `json:"reconnect_attempts" yaml:"reconnect_attempts" toml:"reconnect_attempts"`
+	ReconnectBackoff   Duration `json:"reconnect_backoff" yaml:"reconnect_backoff" toml:"reconnect_backoff"`
+}
+
+// ToolsConfig configures the MCP tools.
+type ToolsConfig struct {
+	Timeout        Duration            `json:"timeout" yaml:"timeout" toml:"timeout"`                            // Default timeout of a tool call
+	Timeouts       map[string]Duration `json:"timeouts" yaml:"timeouts" toml:"timeouts"`                         // Per-tool timeouts
+	Profile        string              `json:"profile" yaml:"profile" toml:"profile"`                            // Named set of tools registered, e.g. minimal
+	Include        []string            `json:"include" yaml:"include" toml:"include"`                            // Tools registered in addition to the profile
+	Exclude        []string            `json:"exclude" yaml:"exclude" toml:"exclude"`                            // Tools not registered
+	ShellMaxOutput int                 `json:"shell_max_output" yaml:"shell_max_output" toml:"shell_max_output"` // Bytes of each stream returned by shell_command
+}
+
+// VisionConfig configures the visual model describing screenshots.
+type VisionConfig struct {
+	APIKey  string `json:"api_key" yaml:"api_key" toml:"api_key"`
+	BaseURL string `json:"base_url" yaml:"base_url" toml:"base_url"` // OpenAI compatible API
+	Model   string `json:"model" yaml:"model" toml:"model"`
+}
+
+// Configured reports whether the visual model can be used, the
+// screenshot_description tool is only registered if it is.
+func (v *VisionConfig) Configured() bool {
+	return v.APIKey != "" && v.Model != ""
+}
+
+// visionTool is the tool using the visual model.
+const visionTool = "screenshot_description"
+
+// LogConfig configures the rotated log file.
+type LogConfig struct {
+	File        string   `json:"file" yaml:"file" toml:"file"`
+	AuditFile   string   `json:"audit_file" yaml:"audit_file" toml:"audit_file"`       // Tool calls with the client identity, the log file if empty
+	AuditRedact []string `json:"audit_redact" yaml:"audit_redact" toml:"audit_redact"` // Arguments left out of the audit log besides code and text, "argument" or "tool.argument"
+	Level       string   `json:"level" yaml:"level" toml:"level"`                      // debug, info, warn or error
+	MaxSize     int      `json:"max_size" yaml:"max_size" toml:"max_size"`             // Megabytes before rotating
+	MaxBackups  int      `json:"max_backups" yaml:"max_backups" toml:"max_backups"`
+	MaxAge      int      `json:"max_age" yaml:"max_age" toml:"max_age"` // Days to keep rotated files
+	Compress    bool     `json:"compress" yaml:"compress" toml:"compress"`
+}
+
+// OutputConfig configures where files are written.
+type OutputConfig struct {
+	Screenshots string `json:"screenshots" yaml:"screenshots" toml:"screenshots"`
+}
+
+// Duration is a time.Duration written as a Go duration, e.g. "1m30s".
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(strings.TrimSpace(string(text)))
+	if err != nil {
+		return fmt.Errorf("invalid duration %q, expected a duration such as 90s", text)
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// BaseDir returns the directory of the log file and screenshots.
+func BaseDir() string {
+	baseDir, _ := os.UserHomeDir()
+	if baseDir == "" {
+		baseDir = os.TempDir()
+	}
+
+	return path.Join(baseDir, "mcp-android-adb-server")
+}
+
+// Default returns the configuration used without a file or environment.
+func Default() *Config {
+	baseDir := BaseDir()
+
+	return &Config{
+		Server: ServerConfig{
+			Transport:       "stdio",
+			Addr:            "localhost:8080",
+			ShutdownTimeout: Duration(device.DefaultTimeout),
+		},
+		Device: DeviceConfig{
+			SwipeDuration:     Duration(device.DefaultSwipeDuration),
+			LongTapDuration:   Duration(device.DefaultLongTapDuration),
+			SleepDuration:     Duration(device.DefaultSleepDuration),
+			ReconnectAttempts: device.DefaultReconnectAttempts,
+			ReconnectBackoff:  Duration(device.DefaultReconnectBackoff),
+		},
+		Tools: ToolsConfig{
+			Timeout:        Duration(device.DefaultTimeout),
+			Profile:        tools.DefaultProfile,
+			ShellMaxOutput: device.DefaultMaxOutput,
+		},
+		Log: LogConfig{
+			File:       path.Join(baseDir, "mcp-android-adb-server.log"),
+			Level:      "debug",
+			MaxSize:    10,
+			MaxBackups: 5,
+			MaxAge:     30,
+			Compress:   true,
+		},
+		Output: OutputConfig{
+			Screenshots: path.Join(baseDir, "screenshots"),
+		},
+	}
+}
+
+// Load reads the configuration file at path, if any, applies the
+// environment variables and validates the result.
+func Load(path string) (*Config, error) {
+	c := Default()
+
+	if path != "" {
+		if err := c.readFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.ApplyEnv(os.Getenv); err != nil {
+		return nil, err
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// readFile decodes a configuration file over c, the format is chosen by
+// the extension. Unknown keys are errors so typos do not go unnoticed.
+func (c *Config) readFile(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(file)); ext {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("config %s: %w", file, err)
+		}
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(c); err != nil {
+			return fmt.Errorf("config %s: %w", file, err)
+		}
+	case ".toml":
+		meta, err := toml.Decode(string(data), c)
+		if err != nil {
+			return fmt.Errorf("config %s: %w", file, err)
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("config %s: unknown key %s", file, undecoded[0])
+		}
+	default:
+		return fmt.Errorf("config %s: unsupported format %q, expected .yaml, .yml, .json or .toml", file, ext)
+	}
+
+	return nil
+}
+
+// ApplyEnv overrides the configuration with the environment variables
+// returned by getenv, e.g. os.Getenv.
+func (c *Config) ApplyEnv(getenv func(string) string) error {
+	var errs []error
+
+	setString := func(name string, dst *string) {
+		if value := getenv(name); value != "" {
+			*dst = value
+		}
+	}
+	setList := func(name string, dst *[]string) {
+		if value := getenv(name); value != "" {
+			*dst = SplitList(value)
+		}
+	}
+	setBool := func(name string, dst *bool) {
+		if value := getenv(name); value != "" {
+			*dst = value == "true"
+		}
+	}
+
+	setString("MCP_TRANSPORT", &c.Server.Transport)
+	setString("MCP_ADDR", &c.Server.Addr)
+	setString("MCP_BASE_PATH", &c.Server.BasePath)
+	setList("MCP_ALLOWED_ORIGINS", &c.Server.AllowedOrigins)
+	setString("DEVICE_ID", &c.Device.ID)
+	setString("SCREEN_LOCK_PASSWORD", &c.Device.ScreenLockPassword)
+	setString("VISUAL_MODEL_API_KEY", &c.Vision.APIKey)
+	setString("VISUAL_MODEL_BASE_URL", &c.Vision.BaseURL)
+	setString("VISUAL_MODEL_NAME", &c.Vision.Model)
+	setString("LOG_FILE", &c.Log.File)
+	setString("AUDIT_LOG_FILE", &c.Log.AuditFile)
+	setList("AUDIT_REDACT", &c.Log.AuditRedact)
+	setString("LOG_LEVEL", &c.Log.Level)
+	setString("SCREENSHOT_DIR", &c.Output.Screenshots)
+
+	if value := getenv("TOOL_TIMEOUT"); value != "" {
+		if err := c.Tools.Timeout.UnmarshalText([]byte(value)); err != nil {
+			errs = append(errs, fmt.Errorf("TOOL_TIMEOUT: %w", err))
+		}
+	}
+	if value := getenv("TOOL_TIMEOUTS"); value != "" {
+		if err := c.Tools.parseTimeouts(value); err != nil {
+			errs = append(errs, fmt.Errorf("TOOL_TIMEOUTS: %w", err))
+		}
+	}
+	setString("TOOL_PROFILE", &c.Tools.Profile)
+	setList("TOOL_INCLUDE", &c.Tools.Include)
+	setList("TOOL_EXCLUDE", &c.Tools.Exclude)
+	// Kept from before tool selection, a shorthand for including or
+	// excluding the vision tool
+	if value := getenv("VISUAL_MODEL_ON"); value == "true" {
+		c.Tools.Include = append(c.Tools.Include, visionTool)
+	} else if value != "" {
+		c.Tools.Exclude = append(c.Tools.Exclude, visionTool)
+	}
+	if value := getenv("SHELL_MAX_OUTPUT"); value != "" {
+		maxOutput, err := strconv.Atoi(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("SHELL_MAX_OUTPUT: invalid number %q", value))
+		} else {
+			c.Tools.ShellMaxOutput = maxOutput
+		}
+	}
+
+	if file := getenv("POLICY_FILE"); file != "" {
+		p, err := policy.Load(file)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("POLICY_FILE: %w", err))
+		} else {
+			c.Policy = *p
+		}
+	}
+	setBool("READ_ONLY", &c.Policy.ReadOnly)
+	if name := getenv("CONFIRM_RISK"); name != "" {
+		risk, err := policy.ParseRisk(name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("CONFIRM_RISK: %w", err))
+		}
+		c.Policy.Confirm = &risk
+	}
+
+	return errors.Join(errs...)
+}
+
+// SplitList splits a comma separated list, e.g. "tap,back".
+func SplitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// parseTimeouts parses per-tool timeouts, e.g. "install_app=10m,shell_command=30s".
+func (t *ToolsConfig) parseTimeouts(value string) error {
+	for _, entry := range strings.Split(value, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+
+		name, timeout, ok := strings.Cut(entry, "=")
+		if !ok {
+			return fmt.Errorf("invalid tool timeout %q, expected name=duration", entry)
+		}
+
+		var d Duration
+		if err := d.UnmarshalText([]byte(timeout)); err != nil {
+			return err
+		}
+
+		if t.Timeouts == nil {
+			t.Timeouts = make(map[string]Duration)
+		}
+		t.Timeouts[strings.TrimSpace(name)] = d
+	}
+
+	return nil
+}
+
+// Validate returns every problem of the configuration joined in one error.
+func (c *Config) Validate() error {
+	var errs []error
+
+	positive := func(name string, d Duration) {
+		if d <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive, got %s", name, time.Duration(d)))
+		}
+	}
+	unknownTools := func(name string, names []string) {
+		for _, tool := range names {
+			if !tools.KnownTool(tool) {
+				errs = append(errs, fmt.Errorf("%s: unknown tool %q", name, tool))
+			}
+		}
+	}
+	positive("server.shutdown_timeout", c.Server.ShutdownTimeout)
+	positive("device.swipe_duration", c.Device.SwipeDuration)
+	positive("device.long_tap_duration", c.Device.LongTapDuration)
+	positive("device.sleep_duration", c.Device.SleepDuration)
+	positive("device.reconnect_backoff", c.Device.ReconnectBackoff)
+	positive("tools.timeout", c.Tools.Timeout)
+	for _, name := range sortedKeys(c.Tools.Timeouts) {
+		positive("tools.timeouts."+name, c.Tools.Timeouts[name])
+	}
+
+	if !slices.Contains(Transports, c.Server.Transport) {
+		errs = append(errs, fmt.Errorf("server.transport: expected one of %s, got %q", strings.Join(Transports, ", "), c.Server.Transport))
+	}
+	if c.Server.Transport != "stdio" && c.Server.Addr == "" {
+		errs = append(errs, fmt.Errorf("server.addr is required by the %s transport", c.Server.Transport))
+	}
+	if c.Server.BasePath != "" && !strings.HasPrefix(c.Server.BasePath, "/") {
+		errs = append(errs, fmt.Errorf("server.base_path must start with /, got %q", c.Server.BasePath))
+	}
+	for _, origin := range c.Server.AllowedOrigins {
+		if u, err := url.Parse(strings.TrimSuffix(origin, "/")); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" {
+			errs = append(errs, fmt.Errorf("server.allowed_origins: expected scheme://host[:port], got %q", origin))
+		}
+	}
+
+	if (c.Server.TLS.CertFile == "") != (c.Server.TLS.KeyFile == "") {
+		errs = append(errs, fmt.Errorf("server.tls.cert_file and server.tls.key_file must be set together"))
+	}
+	if c.Server.TLS.ClientCAFile != "" && c.Server.TLS.CertFile == "" {
+		errs = append(errs, fmt.Errorf("server.tls.client_ca_file requires server.tls.cert_file"))
+	}
+	if _, err := auth.New(c.Server.Identities); err != nil {
+		errs = append(errs, fmt.Errorf("server.identities: %w", err))
+	}
+
+	if c.Device.ReconnectAttempts < 0 {
+		errs = append(errs, fmt.Errorf("device.reconnect_attempts must not be negative, got %d", c.Device.ReconnectAttempts))
+	}
+	if c.Tools.ShellMaxOutput <= 0 {
+		errs = append(errs, fmt.Errorf("tools.shell_max_output must be positive, got %d", c.Tools.ShellMaxOutput))
+	}
+
+	if err := tools.ValidProfile(c.Tools.Profile); err != nil {
+		errs = append(errs, fmt.Errorf("tools.profile: %w", err))
+	}
+	unknownTools("tools.include", c.Tools.Include)
+	unknownTools("tools.exclude", c.Tools.Exclude)
+	for _, id := range c.Server.Identities {
+		unknownTools("server.identities."+id.Name+".tools", id.Tools)
+	}
+	if slices.Contains(c.Tools.Include, visionTool) && !slices.Contains(c.Tools.Exclude, visionTool) && !c.Vision.Configured() {
+		errs = append(errs, fmt.Errorf("vision.api_key and vision.model are required to include %s", visionTool))
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		errs = append(errs, fmt.Errorf("log.level: expected debug, info, warn or error, got %q", c.Log.Level))
+	}
+	if c.Log.File == "" {
+		errs = append(errs, fmt.Errorf("log.file is required"))
+	}
+	if c.Output.Screenshots == "" {
+		errs = append(errs, fmt.Errorf("output.screenshots is required"))
+	}
+
+	if err := c.Policy.Compile(); err != nil {
+		errs = append(errs, fmt.Errorf("policy: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
+
+// LogLevel returns the parsed log level, debug if it is invalid.
+func (c *Config) LogLevel() slog.Level {
+	level := slog.LevelDebug
+	_ = level.UnmarshalText([]byte(c.Log.Level))
+	return level
+}
+
+// Write writes the configuration with secrets redacted in the format named
+// by an extension, e.g. ".yaml", ".json" or ".toml".
+func (c *Config) Write(w io.Writer, format string) error {
+	redactedConfig := *c
+	if redactedConfig.Device.ScreenLockPassword != "" {
+		redactedConfig.Device.ScreenLockPassword = redacted
+	}
+	if redactedConfig.Vision.APIKey != "" {
+		redactedConfig.Vision.APIKey = redacted
+	}
+	redactedConfig.Server.Identities = slices.Clone(c.Server.Identities)
+	for i := range redactedConfig.Server.Identities {
+		if redactedConfig.Server.Identities[i].Token != "" {
+			redactedConfig.Server.Identities[i].Token = redacted
+		}
+	}
+
+	switch strings.ToLower(format) {
+	case ".yaml", ".yml", "":
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(&redactedConfig); err != nil {
+			return err
+		}
+		return encoder.Close()
+	case ".json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(&redactedConfig)
+	case ".toml":
+		return toml.NewEncoder(w).Encode(&redactedConfig)
+	default:
+		return fmt.Errorf("unsupported format %q, expected .yaml, .json or .toml", format)
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
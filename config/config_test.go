@@ -0,0 +1,228 @@
+package config_test
+
+import (
+	"bytes"
+	"mcp-android-adb-server/auth"
+	"mcp-android-adb-server/config"
+	"mcp-android-adb-server/policy"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeFile writes content to name in a temporary directory
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	file := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+// TestLoadFormats tests that YAML, JSON and TOML files load the same configuration
+func TestLoadFormats(t *testing.T) {
+	files := map[string]string{
+		"config.yaml": `
+device:
+  id: emulator-5554
+  swipe_duration: 300ms
+tools:
+  timeout: 90s
+  timeouts:
+    install_app: 20m
+  profile: app-testing
+  include: [screenshot]
+  exclude: [shell_command]
+vision:
+  api_key: sk-test
+  model: qwen2.5-vl
+policy:
+  read_only: true
+  deny: ["^reboot"]
+  confirm: destructive
+`,
+		"config.json": `{
+  "device": {"id": "emulator-5554", "swipe_duration": "300ms"},
+  "tools": {"timeout": "90s", "timeouts": {"install_app": "20m"}, "profile": "app-testing", "include": ["screenshot"], "exclude": ["shell_command"]},
+  "vision": {"api_key": "sk-test", "model": "qwen2.5-vl"},
+  "policy": {"read_only": true, "deny": ["^reboot"], "confirm": "destructive"}
+}`,
+		"config.toml": `
+[device]
+id = "emulator-5554"
+swipe_duration = "300ms"
+
+[tools]
+timeout = "90s"
+profile = "app-testing"
+include = ["screenshot"]
+exclude = ["shell_command"]
+
+[tools.timeouts]
+install_app = "20m"
+
+[vision]
+api_key = "sk-test"
+model = "qwen2.5-vl"
+
+[policy]
+read_only = true
+deny = ["^reboot"]
+confirm = "destructive"
+`,
+	}
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			c, err := config.Load(writeFile(t, name, content))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if c.Device.ID != "emulator-5554" || time.Duration(c.Device.SwipeDuration) != 300*time.Millisecond {
+				t.Errorf("Unexpected device config %+v", c.Device)
+			}
+			if time.Duration(c.Device.LongTapDuration) != 2*time.Second {
+				t.Errorf("Expected defaults for missing keys, got %+v", c.Device)
+			}
+			if time.Duration(c.Tools.Timeout) != 90*time.Second || time.Duration(c.Tools.Timeouts["install_app"]) != 20*time.Minute {
+				t.Errorf("Unexpected tool timeouts %+v", c.Tools)
+			}
+			if c.Tools.Profile != "app-testing" || !reflect.DeepEqual(c.Tools.Include, []string{"screenshot"}) || !reflect.DeepEqual(c.Tools.Exclude, []string{"shell_command"}) {
+				t.Errorf("Unexpected tool selection %+v", c.Tools)
+			}
+			if !c.Vision.Configured() || c.Vision.Model != "qwen2.5-vl" {
+				t.Errorf("Unexpected vision config %+v", c.Vision)
+			}
+			if !c.Policy.ReadOnly || !c.Policy.NeedsConfirm(policy.RiskDestructive) {
+				t.Errorf("Unexpected policy %+v", c.Policy)
+			}
+			if err := c.Policy.CheckCommand("reboot", true); err == nil {
+				t.Error("Expected the deny rule to be compiled")
+			}
+		})
+	}
+}
+
+// TestApplyEnv tests that environment variables override the file
+func TestApplyEnv(t *testing.T) {
+	env := map[string]string{
+		"DEVICE_ID":        "192.168.1.2:5555",
+		"TOOL_TIMEOUT":     "30s",
+		"TOOL_TIMEOUTS":    "install_app=5m, measure_startup=1h",
+		"READ_ONLY":        "true",
+		"CONFIRM_RISK":     "write",
+		"SHELL_MAX_OUTPUT": "4096",
+		"VISUAL_MODEL_ON":  "true",
+		"TOOL_PROFILE":     "minimal",
+		"TOOL_EXCLUDE":     "back, tap",
+	}
+
+	c := config.Default()
+	c.Device.ID = "from-file"
+	if err := c.ApplyEnv(func(name string) string { return env[name] }); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Device.ID != "192.168.1.2:5555" {
+		t.Errorf("Expected DEVICE_ID to override the file, got %q", c.Device.ID)
+	}
+	if time.Duration(c.Tools.Timeout) != 30*time.Second || time.Duration(c.Tools.Timeouts["measure_startup"]) != time.Hour {
+		t.Errorf("Unexpected tool timeouts %+v", c.Tools)
+	}
+	if !c.Policy.ReadOnly || !c.Policy.NeedsConfirm(policy.RiskWrite) || c.Tools.ShellMaxOutput != 4096 {
+		t.Errorf("Unexpected overrides %+v %+v", c.Policy, c.Tools)
+	}
+
+	if c.Tools.Profile != "minimal" || !reflect.DeepEqual(c.Tools.Exclude, []string{"back", "tap"}) || !reflect.DeepEqual(c.Tools.Include, []string{"screenshot_description"}) {
+		t.Errorf("Unexpected tool selection %+v", c.Tools)
+	}
+
+	if err := c.Validate(); err == nil || !strings.Contains(err.Error(), "vision.api_key") {
+		t.Errorf("Expected vision without an API key to be invalid, got %v", err)
+	}
+
+	err := config.Default().ApplyEnv(func(name string) string {
+		return map[string]string{"TOOL_TIMEOUTS": "install_app", "CONFIRM_RISK": "high"}[name]
+	})
+	if err == nil || !strings.Contains(err.Error(), "TOOL_TIMEOUTS") || !strings.Contains(err.Error(), "CONFIRM_RISK") {
+		t.Errorf("Expected both invalid variables to be reported, got %v", err)
+	}
+
+	c = config.Default()
+	err = c.ApplyEnv(func(name string) string {
+		return map[string]string{"SHELL_MAX_OUTPUT": "1MB"}[name]
+	})
+	if err == nil || strings.Count(err.Error(), "\n") != 0 || c.Tools.ShellMaxOutput != config.Default().Tools.ShellMaxOutput {
+		t.Errorf("Expected a single error and the default limit, got %v and %d", err, c.Tools.ShellMaxOutput)
+	}
+	if err := c.Validate(); err != nil {
+		t.Errorf("Expected the default limit to stay valid, got %v", err)
+	}
+}
+
+// TestLoadInvalid tests that configuration errors are reported at startup
+func TestLoadInvalid(t *testing.T) {
+	tests := map[string]struct {
+		name, content, want string
+	}{
+		"unknown key":      {"c.yaml", "device:\n  idd: x\n", "idd"},
+		"bad duration":     {"c.json", `{"device": {"sleep_duration": "soon"}}`, "soon"},
+		"negative":         {"c.toml", "[device]\nsleep_duration = \"-1s\"\n", "device.sleep_duration must be positive"},
+		"log level":        {"c.yaml", "log:\n  level: loud\n", "log.level"},
+		"bad pattern":      {"c.yaml", "policy:\n  deny: [\"(\"]\n", "policy"},
+		"unknown format":   {"c.ini", "", "unsupported format"},
+		"unknown toml key": {"c.toml", "[tools]\nexcluded = []\n", "excluded"},
+		"transport":        {"c.yaml", "server:\n  transport: websocket\n", "server.transport"},
+		"base path":        {"c.json", `{"server": {"transport": "http", "base_path": "mcp"}}`, "server.base_path"},
+		"allowed origin":   {"c.json", `{"server": {"allowed_origins": ["app.example.com"]}}`, "server.allowed_origins"},
+		"tool profile":     {"c.yaml", "tools:\n  profile: everything\n", "tools.profile"},
+		"identity scope":   {"c.yaml", "server:\n  identities:\n    - name: ci\n      token: t\n      scope: root\n", "invalid scope"},
+		"identity tool":    {"c.yaml", "server:\n  identities:\n    - name: ci\n      token: t\n      scope: read\n      tools: [screen_size, screenshots]\n", "server.identities.ci.tools: unknown tool \"screenshots\""},
+		"excluded tool":    {"c.json", `{"tools": {"include": ["tap"], "exclude": ["swipe"]}}`, "tools.exclude: unknown tool \"swipe\""},
+		"included tool":    {"c.toml", "[tools]\ninclude = [\"tap_element\"]\n", "tools.include: unknown tool \"tap_element\""},
+		"tls key":          {"c.toml", "[server.tls]\ncert_file = \"cert.pem\"\n", "key_file"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := config.Load(writeFile(t, tt.name, tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected an error mentioning %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+// TestWrite tests that a printed configuration loads back with secrets redacted
+func TestWrite(t *testing.T) {
+	c := config.Default()
+	c.Device.ScreenLockPassword = "1234"
+	c.Vision.APIKey = "sk-secret"
+	c.Server.Identities = []auth.Identity{{Name: "ci", Token: "tok-secret", Scope: auth.ScopeRead}}
+	c.Tools.Timeouts = map[string]config.Duration{"install_app": config.Duration(time.Minute)}
+	c.Log.AuditRedact = []string{"emulator_sms.number"}
+
+	for _, format := range []string{".yaml", ".json", ".toml"} {
+		var buf bytes.Buffer
+		if err := c.Write(&buf, format); err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(buf.String(), "sk-secret") || strings.Contains(buf.String(), "1234") || strings.Contains(buf.String(), "tok-secret") {
+			t.Errorf("Expected secrets to be redacted in %s:\n%s", format, buf.String())
+		}
+
+		loaded, err := config.Load(writeFile(t, "config"+format, buf.String()))
+		if err != nil {
+			t.Fatalf("Failed to load the printed %s: %v\n%s", format, err, buf.String())
+		}
+		if loaded.Tools.Timeouts["install_app"] != config.Duration(time.Minute) || !reflect.DeepEqual(loaded.Log, c.Log) {
+			t.Errorf("Expected the printed %s to load back, got %+v", format, loaded)
+		}
+	}
+}
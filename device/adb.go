@@ -0,0 +1,133 @@
+package device
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// adbTimeout bounds a request to the ADB server.
+const adbTimeout = 10 * time.Second
+
+// adbConn is a connection to the ADB server for requests gadb does not
+// support, see SERVICES.TXT in the ADB sources for the protocol.
+type adbConn struct {
+	conn net.Conn
+}
+
+// dialAdb connects to the ADB server at addr.
+func dialAdb(addr string) (*adbConn, error) {
+	conn, err := net.DialTimeout("tcp", addr, adbTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("adb server: %w", err)
+	}
+
+	_ = conn.SetDeadline(time.Now().Add(adbTimeout))
+	return &adbConn{conn: conn}, nil
+}
+
+// Close closes the connection.
+func (c *adbConn) Close() error {
+	return c.conn.Close()
+}
+
+// send sends a request prefixed with its hex length.
+func (c *adbConn) send(request string) error {
+	_, err := fmt.Fprintf(c.conn, "%04x%s", len(request), request)
+	return err
+}
+
+// status reads an OKAY status, or the message of a FAIL status as error.
+func (c *adbConn) status() error {
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(c.conn, buf); err != nil {
+		return fmt.Errorf("adb server: %w", err)
+	}
+
+	switch string(buf) {
+	case "OKAY":
+		return nil
+	case "FAIL":
+		msg, err := c.readString()
+		if err != nil {
+			return fmt.Errorf("adb server: %w", err)
+		}
+		return &adbError{msg: msg}
+	default:
+		return fmt.Errorf("adb server: unexpected status %q", buf)
+	}
+}
+
+// adbError is a FAIL status of the ADB server.
+type adbError struct {
+	msg string
+}
+
+func (e *adbError) Error() string {
+	return e.msg
+}
+
+// readString reads a string prefixed with its hex length.
+func (c *adbConn) readString() (string, error) {
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(c.conn, buf); err != nil {
+		return "", err
+	}
+
+	size, err := strconv.ParseUint(string(buf), 16, 32)
+	if err != nil {
+		return "", fmt.Errorf("invalid length %q", buf)
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(c.conn, data); err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+// request sends request and reads its status.
+func (c *adbConn) request(request string) error {
+	if err := c.send(request); err != nil {
+		return fmt.Errorf("adb server: %w", err)
+	}
+
+	return c.status()
+}
+
+// adbHost runs a host request, which the ADB server answers itself.
+func (d *AndroidDevice) adbHost(request string) (*adbConn, error) {
+	c, err := dialAdb(d.adbAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.request(request); err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// adbService opens a service on the device through the ADB server.
+func (d *AndroidDevice) adbService(service string) (*adbConn, error) {
+	c, err := dialAdb(d.adbAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.request("host:transport:" + d.id); err != nil {
+		c.Close()
+		return nil, err
+	}
+	if err := c.request(service); err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
@@ -0,0 +1,116 @@
+package device
+
+import (
+	"fmt"
+	"mcp-android-adb-server/shellquote"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// ClipperPackage is the package of the clipboard helper app used on devices
+// without `cmd clipboard`, see https://github.com/majido/clipper
+var ClipperPackage = "ca.zgrs.clipper"
+
+// pasteThreshold is the text length above which InputText should paste.
+const pasteThreshold = 100
+
+// e.g. `Broadcast completed: result=-1, data="copied text"`
+var clipperDataRegexp = regexp.MustCompile(`(?s)result=-1, data="(.*)"\s*$`)
+
+// ShouldPaste reports whether text is better entered through the clipboard
+// than `input text`, which only handles short ASCII strings reliably.
+func ShouldPaste(text string) bool {
+	if len(text) > pasteThreshold {
+		return true
+	}
+
+	for _, r := range text {
+		if r > unicode.MaxASCII || !unicode.IsPrint(r) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Clipboard returns the text of the primary clip.
+func (d *AndroidDevice) Clipboard() (string, error) {
+	out, err := d.RunShellCommand("cmd clipboard get-primary-clip")
+	if err != nil {
+		return "", fmt.Errorf("get clipboard: %w", err)
+	}
+
+	if !clipboardUnsupported(out) {
+		return strings.TrimSuffix(out, "\n"), nil
+	}
+
+	if out, err = d.clipper("get"); err != nil {
+		return "", err
+	}
+
+	match := clipperDataRegexp.FindStringSubmatch(out)
+	if match == nil {
+		// The helper returns no data for an empty clipboard
+		if strings.Contains(out, "result=0") {
+			return "", nil
+		}
+		return "", fmt.Errorf("get clipboard: %s", strings.TrimSpace(out))
+	}
+
+	return match[1], nil
+}
+
+// SetClipboard sets the primary clip to text.
+func (d *AndroidDevice) SetClipboard(text string) error {
+	result, err := d.Exec(shellquote.Command("cmd clipboard set-primary-clip", text), ExecOptions{})
+	if err != nil {
+		return fmt.Errorf("set clipboard: %w", err)
+	}
+
+	if !clipboardUnsupported(result.Stdout + result.Stderr) {
+		if result.ExitCode != 0 {
+			return fmt.Errorf("set clipboard: %s", result.failure())
+		}
+		return nil
+	}
+
+	_, err = d.clipper("set", "-e", "text", text)
+	return err
+}
+
+// PasteText enters text by setting the clipboard and pressing paste.
+func (d *AndroidDevice) PasteText(text string) error {
+	if err := d.SetClipboard(text); err != nil {
+		return err
+	}
+
+	return d.InputKey(KeycodePaste)
+}
+
+// clipper sends a broadcast to the clipboard helper app.
+func (d *AndroidDevice) clipper(action string, extras ...string) (string, error) {
+	installed, err := d.InstalledApp(ClipperPackage)
+	if err != nil {
+		return "", fmt.Errorf("clipboard helper: %w", err)
+	}
+	if !installed {
+		return "", fmt.Errorf("cmd clipboard is not available on this device, install the clipboard helper %s", ClipperPackage)
+	}
+
+	args := append([]string{"-n", ClipperPackage + "/.ClipperReceiver", "-a", "clipper." + action}, extras...)
+	out, err := d.shell("am broadcast", args...)
+	if err != nil {
+		return "", fmt.Errorf("clipboard helper: %w", err)
+	}
+
+	return out, nil
+}
+
+// clipboardUnsupported reports whether the output of `cmd clipboard` shows
+// that the clipboard service has no shell commands.
+func clipboardUnsupported(out string) bool {
+	return strings.Contains(out, "No shell command implementation") ||
+		strings.Contains(out, "Unknown command") ||
+		strings.Contains(out, "Can't find service")
+}
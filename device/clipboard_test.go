@@ -0,0 +1,70 @@
+package device_test
+
+import (
+	"mcp-android-adb-server/device"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestShouldPaste tests choosing clipboard paste for long or non-ASCII text
+func TestShouldPaste(t *testing.T) {
+	testCases := map[string]bool{
+		"hello":                  false,
+		"你好":                     true,
+		"こんにちは":                  true,
+		"line1\nline2":           true,
+		strings.Repeat("a", 99):  false,
+		strings.Repeat("a", 101): true,
+	}
+
+	for text, expected := range testCases {
+		if got := device.ShouldPaste(text); got != expected {
+			t.Errorf("ShouldPaste(%q) = %v, expected %v", text, got, expected)
+		}
+	}
+}
+
+// TestClipboardFallback tests falling back to the clipboard helper app
+func TestClipboardFallback(t *testing.T) {
+	fake := newFakeTransport()
+	fake.reply("cmd clipboard", "No shell command implementation.\n")
+	fake.reply("pm path ca.zgrs.clipper", "package:/data/app/ca.zgrs.clipper-1/base.apk\n")
+	fake.reply("am broadcast -n ca.zgrs.clipper/.ClipperReceiver -a clipper.get",
+		"Broadcasting: Intent { act=clipper.get }\nBroadcast completed: result=-1, data=\"你好 world\"\n")
+	d := device.NewTestDevice("fake", fake)
+
+	text, err := d.Clipboard()
+	if err != nil {
+		t.Fatalf("Failed to get clipboard: %v", err)
+	}
+	if text != "你好 world" {
+		t.Errorf("Unexpected clipboard text: %q", text)
+	}
+
+	if err := d.InputText("it's 你好", true); err != nil {
+		t.Fatalf("Failed to paste text: %v", err)
+	}
+
+	history := fake.history()
+	expected := []string{
+		"cmd clipboard set-primary-clip 'it'\\''s 你好'",
+		"pm path ca.zgrs.clipper",
+		"am broadcast -n ca.zgrs.clipper/.ClipperReceiver -a clipper.set -e text 'it'\\''s 你好'",
+		"input keyevent 279",
+	}
+	if got := history[len(history)-len(expected):]; !reflect.DeepEqual(got, expected) {
+		t.Errorf("Commands do not match expected:\nexpected %q\ngot      %q", expected, got)
+	}
+}
+
+// TestClipboardHelperMissing tests reporting a missing clipboard helper
+func TestClipboardHelperMissing(t *testing.T) {
+	fake := newFakeTransport()
+	fake.reply("cmd clipboard", "No shell command implementation.\n")
+	d := device.NewTestDevice("fake", fake)
+
+	if err := d.SetClipboard("hello"); err == nil || !strings.Contains(err.Error(), device.ClipperPackage) {
+		t.Errorf("Expected error naming the clipboard helper, got %v", err)
+	}
+}
@@ -0,0 +1,267 @@
+package device
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	// e.g. "Active default network: 100"
+	activeNetworkRegexp = regexp.MustCompile(`Active default network: (\d+)`)
+
+	// Fields of a NetworkAgentInfo line in `dumpsys connectivity`
+	interfaceNameRegexp = regexp.MustCompile(`InterfaceName: (\S+)`)
+	linkAddressesRegexp = regexp.MustCompile(`LinkAddresses: \[ ?([^\]]*)\]`)
+	dnsAddressesRegexp  = regexp.MustCompile(`DnsAddresses: \[ ?([^\]]*)\]`)
+	transportsRegexp    = regexp.MustCompile(`Transports: (\S+)`)
+
+	// e.g. "21: wlan0    inet 192.168.1.5/24 brd 192.168.1.255 scope global wlan0\ ..."
+	ipAddrRegexp = regexp.MustCompile(`^\d+:\s+(\S+)\s+inet6?\s+(\S+)`)
+)
+
+// Radio is a wireless radio of the device.
+type Radio string
+
+const (
+	RadioWifi       Radio = "wifi"
+	RadioMobileData Radio = "mobile_data"
+	RadioAirplane   Radio = "airplane_mode"
+	RadioBluetooth  Radio = "bluetooth"
+)
+
+// radioSettings maps each radio to the global setting reflecting its state.
+var radioSettings = map[Radio]string{
+	RadioWifi:       "wifi_on",
+	RadioMobileData: "mobile_data",
+	RadioAirplane:   "airplane_mode_on",
+	RadioBluetooth:  "bluetooth_on",
+}
+
+// NetworkStatus represents the connectivity state of the device.
+type NetworkStatus struct {
+	Connected         bool               `json:"connected"`           // Whether there is an active default network
+	ActiveNetwork     *ActiveNetwork     `json:"active_network"`      // Active default network, nil when offline
+	Interfaces        []NetworkInterface `json:"interfaces"`          // Addresses of all interfaces except loopback
+	WifiEnabled       bool               `json:"wifi_enabled"`        // Wi-Fi radio state
+	MobileDataEnabled bool               `json:"mobile_data_enabled"` // Mobile data state
+	AirplaneMode      bool               `json:"airplane_mode"`       // Airplane mode state
+	BluetoothEnabled  bool               `json:"bluetooth_enabled"`   // Bluetooth radio state
+}
+
+// ActiveNetwork represents the network used by default for app traffic.
+type ActiveNetwork struct {
+	ID        int      `json:"id"`        // Network ID
+	Transport string   `json:"transport"` // e.g. WIFI, CELLULAR, ETHERNET, VPN
+	Interface string   `json:"interface"` // e.g. wlan0
+	Addresses []string `json:"addresses"` // Link addresses with prefix length
+	DNS       []string `json:"dns"`       // DNS servers
+	Validated bool     `json:"validated"` // Whether internet access was validated
+}
+
+// NetworkInterface represents the addresses assigned to a network interface.
+type NetworkInterface struct {
+	Name      string   `json:"name"`
+	Addresses []string `json:"addresses"`
+}
+
+// SetWifi enables or disables the Wi-Fi radio.
+func (d *AndroidDevice) SetWifi(enabled bool) error {
+	return d.setRadio(RadioWifi, enabled, func(sdk int, enabled bool) []string {
+		cmds := []string{"svc wifi " + enableDisable(enabled)}
+		if sdk >= 30 {
+			cmds = append([]string{"cmd wifi set-wifi-enabled " + enableDisable(enabled) + "d"}, cmds...)
+		}
+		return cmds
+	})
+}
+
+// SetMobileData enables or disables mobile data.
+func (d *AndroidDevice) SetMobileData(enabled bool) error {
+	return d.setRadio(RadioMobileData, enabled, func(sdk int, enabled bool) []string {
+		cmds := []string{"svc data " + enableDisable(enabled)}
+		if sdk >= 31 {
+			cmds = append(cmds, "cmd phone data "+enableDisable(enabled))
+		}
+		return cmds
+	})
+}
+
+// SetAirplaneMode enables or disables airplane mode.
+func (d *AndroidDevice) SetAirplaneMode(enabled bool) error {
+	return d.setRadio(RadioAirplane, enabled, func(sdk int, enabled bool) []string {
+		if sdk >= 28 {
+			return []string{"cmd connectivity airplane-mode " + enableDisable(enabled)}
+		}
+
+		// Older releases only notice the setting after the broadcast
+		value := "0"
+		if enabled {
+			value = "1"
+		}
+		return []string{
+			"settings put global airplane_mode_on " + value +
+				" && am broadcast -a android.intent.action.AIRPLANE_MODE --ez state " + strconv.FormatBool(enabled),
+		}
+	})
+}
+
+// SetBluetooth enables or disables the Bluetooth radio.
+func (d *AndroidDevice) SetBluetooth(enabled bool) error {
+	return d.setRadio(RadioBluetooth, enabled, func(sdk int, enabled bool) []string {
+		cmds := []string{"svc bluetooth " + enableDisable(enabled)}
+		if sdk >= 33 {
+			cmds = append([]string{"cmd bluetooth_manager " + enableDisable(enabled)}, cmds...)
+		}
+		return cmds
+	})
+}
+
+// RadioEnabled reports whether a radio is enabled.
+func (d *AndroidDevice) RadioEnabled(radio Radio) (bool, error) {
+	value, err := d.GetSetting(SettingsGlobal, radioSettings[radio])
+	if err != nil {
+		return false, err
+	}
+
+	// wifi_on is 2 when Wi-Fi stays on in airplane mode
+	return value != "0" && value != settingNull && value != "", nil
+}
+
+// NetworkStatus returns the connectivity state of the device.
+func (d *AndroidDevice) NetworkStatus() (*NetworkStatus, error) {
+	out, err := d.RunShellCommand("dumpsys connectivity")
+	if err != nil {
+		return nil, fmt.Errorf("dumpsys connectivity: %w", err)
+	}
+
+	status := &NetworkStatus{ActiveNetwork: parseActiveNetwork(out)}
+	status.Connected = status.ActiveNetwork != nil
+
+	addrOutput, err := d.RunShellCommand("ip -o addr show")
+	if err != nil {
+		return nil, fmt.Errorf("ip addr: %w", err)
+	}
+	status.Interfaces = parseIPAddr(addrOutput)
+
+	radios := map[Radio]*bool{
+		RadioWifi:       &status.WifiEnabled,
+		RadioMobileData: &status.MobileDataEnabled,
+		RadioAirplane:   &status.AirplaneMode,
+		RadioBluetooth:  &status.BluetoothEnabled,
+	}
+	for radio, enabled := range radios {
+		if *enabled, err = d.RadioEnabled(radio); err != nil {
+			return nil, err
+		}
+	}
+
+	return status, nil
+}
+
+// setRadio records the original state of a radio so it is restored when the
+// device is closed, then runs the commands for the device API level until one succeeds.
+func (d *AndroidDevice) setRadio(radio Radio, enabled bool, commands func(sdk int, enabled bool) []string) error {
+	original, err := d.RadioEnabled(radio)
+	if err != nil {
+		return err
+	}
+
+	sdk, err := d.SDK()
+	if err != nil {
+		return err
+	}
+
+	d.onCloseOnce("restore "+string(radio), func(d *AndroidDevice) error {
+		return d.runFirst(commands(sdk, original))
+	})
+
+	return d.runFirst(commands(sdk, enabled))
+}
+
+// enableDisable returns "enable" or "disable".
+func enableDisable(enabled bool) string {
+	if enabled {
+		return "enable"
+	}
+	return "disable"
+}
+
+// parseActiveNetwork parses the active default network from `dumpsys connectivity`.
+func parseActiveNetwork(out string) *ActiveNetwork {
+	match := activeNetworkRegexp.FindStringSubmatch(out)
+	if match == nil {
+		return nil
+	}
+
+	network := &ActiveNetwork{}
+	network.ID, _ = strconv.Atoi(match[1])
+
+	marker := "network{" + match[1] + "}"
+	for _, line := range strings.Split(out, "\n") {
+		if !strings.Contains(line, "NetworkAgentInfo") || !strings.Contains(line, marker) {
+			continue
+		}
+
+		if m := transportsRegexp.FindStringSubmatch(line); m != nil {
+			network.Transport = strings.Split(m[1], "|")[0]
+		}
+		if m := interfaceNameRegexp.FindStringSubmatch(line); m != nil {
+			network.Interface = m[1]
+		}
+		if m := linkAddressesRegexp.FindStringSubmatch(line); m != nil {
+			network.Addresses = splitAddresses(m[1])
+		}
+		if m := dnsAddressesRegexp.FindStringSubmatch(line); m != nil {
+			network.DNS = splitAddresses(m[1])
+		}
+		network.Validated = strings.Contains(line, "&VALIDATED") || strings.Contains(line, " VALIDATED")
+		break
+	}
+
+	return network
+}
+
+// splitAddresses splits a comma separated address list, dropping the leading
+// slash InetAddress.toString adds.
+func splitAddresses(list string) []string {
+	addresses := []string{}
+	for _, addr := range strings.Split(list, ",") {
+		if addr = strings.TrimPrefix(strings.TrimSpace(addr), "/"); addr != "" {
+			addresses = append(addresses, addr)
+		}
+	}
+
+	return addresses
+}
+
+// parseIPAddr parses the output of `ip -o addr show`, skipping loopback.
+func parseIPAddr(out string) []NetworkInterface {
+	byName := make(map[string]*NetworkInterface)
+	var names []string
+
+	for _, line := range strings.Split(out, "\n") {
+		match := ipAddrRegexp.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil || match[1] == "lo" {
+			continue
+		}
+
+		iface, ok := byName[match[1]]
+		if !ok {
+			iface = &NetworkInterface{Name: match[1]}
+			byName[match[1]] = iface
+			names = append(names, match[1])
+		}
+		iface.Addresses = append(iface.Addresses, match[2])
+	}
+
+	sort.Strings(names)
+	interfaces := make([]NetworkInterface, 0, len(names))
+	for _, name := range names {
+		interfaces = append(interfaces, *byName[name])
+	}
+
+	return interfaces
+}
@@ -0,0 +1,89 @@
+package device_test
+
+import (
+	"mcp-android-adb-server/device"
+	"reflect"
+	"testing"
+)
+
+const dumpsysConnectivity = `NetworkProviders for:
+Active default network: 100
+
+Current Networks:
+  NetworkAgentInfo{network{100}  handle{432902426637}  ni{WIFI CONNECTED extra: }  Score(Policies : IS_VALIDATED&EVER_USER_SELECTED ; KeepConnected : 0)  created everValidated lastValidated  lp{{InterfaceName: wlan0 LinkAddresses: [ fe80::1c2d:3eff:fe4f:5a6b/64,192.168.1.23/24 ] DnsAddresses: [ /192.168.1.1,/8.8.8.8 ] Domains: lan MTU: 1500 Routes: [ ]}}  nc{[ Transports: WIFI Capabilities: NOT_METERED&INTERNET&NOT_RESTRICTED&TRUSTED&NOT_VPN&VALIDATED&NOT_ROAMING LinkUpBandwidth>=37062Kbps]}}
+  NetworkAgentInfo{network{101}  handle{437197393933}  ni{MOBILE[LTE] CONNECTED extra: internet}  lp{{InterfaceName: rmnet0 LinkAddresses: [ 10.0.0.2/30 ] DnsAddresses: [ /10.0.0.1 ]}}  nc{[ Transports: CELLULAR Capabilities: INTERNET&NOT_RESTRICTED]}}
+`
+
+const ipAddr = `1: lo    inet 127.0.0.1/8 scope host lo\       valid_lft forever preferred_lft forever
+21: wlan0    inet 192.168.1.23/24 brd 192.168.1.255 scope global wlan0\       valid_lft forever preferred_lft forever
+21: wlan0    inet6 fe80::1c2d:3eff:fe4f:5a6b/64 scope link \       valid_lft forever preferred_lft forever
+15: rmnet0    inet 10.0.0.2/30 scope global rmnet0\       valid_lft forever preferred_lft forever
+`
+
+// TestNetworkStatus tests parsing the active network and interface addresses
+func TestNetworkStatus(t *testing.T) {
+	fake := newFakeTransport()
+	fake.reply("dumpsys connectivity", dumpsysConnectivity)
+	fake.reply("ip -o addr show", ipAddr)
+	fake.reply("settings get global wifi_on", "1\n")
+	fake.reply("settings get global mobile_data", "1\n")
+	fake.reply("settings get global airplane_mode_on", "0\n")
+	fake.reply("settings get global bluetooth_on", "null\n")
+	d := device.NewTestDevice("fake", fake)
+
+	status, err := d.NetworkStatus()
+	if err != nil {
+		t.Fatalf("Failed to get network status: %v", err)
+	}
+
+	expected := &device.NetworkStatus{
+		Connected: true,
+		ActiveNetwork: &device.ActiveNetwork{
+			ID:        100,
+			Transport: "WIFI",
+			Interface: "wlan0",
+			Addresses: []string{"fe80::1c2d:3eff:fe4f:5a6b/64", "192.168.1.23/24"},
+			DNS:       []string{"192.168.1.1", "8.8.8.8"},
+			Validated: true,
+		},
+		Interfaces: []device.NetworkInterface{
+			{Name: "rmnet0", Addresses: []string{"10.0.0.2/30"}},
+			{Name: "wlan0", Addresses: []string{"192.168.1.23/24", "fe80::1c2d:3eff:fe4f:5a6b/64"}},
+		},
+		WifiEnabled:       true,
+		MobileDataEnabled: true,
+	}
+	if !reflect.DeepEqual(status, expected) {
+		t.Errorf("Network status does not match expected:\nexpected %+v\ngot      %+v", expected, status)
+	}
+}
+
+// TestSetWifiFallback tests falling back to svc and restoring the original state on close
+func TestSetWifiFallback(t *testing.T) {
+	fake := newFakeTransport()
+	fake.reply("getprop ro.build.version.sdk", "30\n")
+	fake.reply("settings get global wifi_on", "1\n")
+	fake.handle("cmd wifi", func(string) (string, error) {
+		return "", &fakeExit{code: 255, stderr: "Unknown command: set-wifi-enabled\n"}
+	})
+	d := device.NewTestDevice("fake", fake)
+
+	if err := d.SetWifi(false); err != nil {
+		t.Fatalf("Failed to disable Wi-Fi: %v", err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatalf("Failed to close device: %v", err)
+	}
+
+	expected := []string{
+		"settings get global wifi_on",
+		"getprop ro.build.version.sdk",
+		"cmd wifi set-wifi-enabled disabled",
+		"svc wifi disable",
+		"cmd wifi set-wifi-enabled enabled",
+		"svc wifi enable",
+	}
+	if history := fake.history(); !reflect.DeepEqual(history, expected) {
+		t.Errorf("Commands do not match expected:\nexpected %q\ngot      %q", expected, history)
+	}
+}
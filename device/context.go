@@ -0,0 +1,154 @@
+package device
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// killTimeout bounds killing the remote process of a cancelled command.
+const killTimeout = 5 * time.Second
+
+// DefaultTimeout bounds an operation started for a client, e.g. a tool
+// call, unless it has a timeout of its own.
+const DefaultTimeout = 2 * time.Minute
+
+// pidFilePrefix starts the line that records the PID of a cancellable command.
+const pidFilePrefix = "echo $$ 2>/dev/null > "
+
+// pidSeq numbers the PID files of cancellable commands.
+var pidSeq atomic.Uint64
+
+// WithContext returns a view of the device whose operations stop when ctx
+// is done. A cancelled shell command is killed on the device. The view
+// shares the session of d, including the changes restored by Close.
+func (d *AndroidDevice) WithContext(ctx context.Context) *AndroidDevice {
+	if ctx == nil {
+		panic("nil context")
+	}
+
+	view := *d
+	view.ctx = ctx
+	if s, ok := d.supervisor(); ok {
+		view.adb = s.bind(ctx)
+	}
+	return &view
+}
+
+// Context returns the context of the device, context.Background unless the
+// device was created by WithContext.
+func (d *AndroidDevice) Context() context.Context {
+	return d.ctx
+}
+
+// runShellContext runs a shell command that is killed when the context of
+// the device is done. The shell records its PID so the command and its
+// children can be killed on cancellation.
+func (d *AndroidDevice) runShellContext(cmd string, args ...string) (string, error) {
+	if err := d.ctx.Err(); err != nil {
+		return "", err
+	}
+
+	if len(args) > 0 {
+		cmd = fmt.Sprintf("%s %s", cmd, strings.Join(args, " "))
+	}
+
+	wrapped, pidFile := wrapCommand(cmd)
+
+	type result struct {
+		out string
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		out, err := d.adb.RunShellCommand(wrapped)
+		done <- result{out, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.out, r.err
+	case <-d.ctx.Done():
+		d.killRemote(pidFile)
+		return "", fmt.Errorf("%s: %w", cmd, d.ctx.Err())
+	}
+}
+
+// wrapCommand wraps cmd so the shell records its PID in the returned file.
+func wrapCommand(cmd string) (wrapped, pidFile string) {
+	pidFile = path.Join(TempPath, fmt.Sprintf(".mcp-%d-%d.pid", os.Getpid(), pidSeq.Add(1)))
+	// The shell service merges stderr into the output, so errors of the PID
+	// file are discarded. pidFilePrefix redirects stderr before the file.
+	wrapped = fmt.Sprintf("%s%s\n%s\nstatus=$?; rm -f %s 2>/dev/null; exit $status", pidFilePrefix, pidFile, cmd, pidFile)
+	return wrapped, pidFile
+}
+
+// killRemote kills the process recorded in pidFile and its children.
+func (d *AndroidDevice) killRemote(pidFile string) {
+	kill := fmt.Sprintf("p=$(cat %s 2>/dev/null) && { pkill -P $p; kill $p; rm -f %s; } 2>/dev/null", pidFile, pidFile)
+
+	done := make(chan struct{})
+	go func() {
+		_, _ = d.adb.RunShellCommand(kill)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(killTimeout):
+	}
+}
+
+// unwrapCommand returns the command wrapped by runShellContext.
+func unwrapCommand(cmd string) string {
+	if !strings.HasPrefix(cmd, pidFilePrefix) {
+		return cmd
+	}
+
+	_, body, _ := strings.Cut(cmd, "\n")
+	body, _, _ = strings.Cut(body, "\nstatus=$?")
+	return body
+}
+
+// pushFile pushes local to remotePath until the context of the device is
+// done. gadb cannot abort a transfer, so it finishes in the background.
+func (d *AndroidDevice) pushFile(local *os.File, remotePath string) error {
+	return d.wait(func() error {
+		return d.adb.PushFile(local, remotePath)
+	})
+}
+
+// pull pulls remotePath into dest until the context of the device is done.
+func (d *AndroidDevice) pull(remotePath string, dest io.Writer) error {
+	return d.wait(func() error {
+		return d.adb.Pull(remotePath, dest)
+	})
+}
+
+// wait runs fn and returns its error, or the context error once the
+// context of the device is done.
+func (d *AndroidDevice) wait(fn func() error) error {
+	if d.ctx.Done() == nil {
+		return fn()
+	}
+	if err := d.ctx.Err(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- fn()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-d.ctx.Done():
+		return d.ctx.Err()
+	}
+}
@@ -0,0 +1,124 @@
+package device_test
+
+import (
+	"context"
+	"errors"
+	"mcp-android-adb-server/device"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRunShellCommandCancel tests killing a command when its context is cancelled
+func TestRunShellCommandCancel(t *testing.T) {
+	killed := make(chan string, 1)
+
+	fake := newFakeTransport()
+	fake.handle("echo $$ 2>/dev/null > ", func(cmd string) (string, error) {
+		<-killed
+		return "", nil
+	})
+	fake.handle("p=$(cat ", func(cmd string) (string, error) {
+		killed <- cmd
+		return "", nil
+	})
+	d := device.NewTestDevice("fake", fake)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := d.WithContext(ctx).RunShellCommand("logcat")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected deadline exceeded, got %v", err)
+	}
+
+	history := fake.history()
+	if len(history) != 2 {
+		t.Fatalf("Expected the command and the kill, got %q", history)
+	}
+
+	pidFile := strings.TrimPrefix(strings.SplitN(history[0], "\n", 2)[0], "echo $$ 2>/dev/null > ")
+	if !strings.Contains(history[0], "\nlogcat\n") || !strings.HasPrefix(pidFile, device.TempPath) {
+		t.Errorf("Unexpected wrapped command: %q", history[0])
+	}
+	if !strings.Contains(history[1], "pkill -P $p") || !strings.Contains(history[1], pidFile) {
+		t.Errorf("Unexpected kill command: %q", history[1])
+	}
+}
+
+// TestRunShellCommandPidFileError tests that a PID file that cannot be
+// written does not change the output of the command
+func TestRunShellCommandPidFileError(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no shell to run the wrapped command")
+	}
+
+	temp := device.TempPath
+	device.TempPath = filepath.Join(t.TempDir(), "missing")
+	defer func() { device.TempPath = temp }()
+
+	// The shell service merges stderr into stdout
+	fake := newFakeTransport()
+	fake.handle("echo $$ 2>/dev/null > ", func(cmd string) (string, error) {
+		out, err := exec.Command(sh, "-c", cmd).CombinedOutput()
+		return string(out), err
+	})
+	d := device.NewTestDevice("fake", fake)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	out, err := d.WithContext(ctx).RunShellCommand("echo hello")
+	if err != nil || out != "hello\n" {
+		t.Errorf("Expected only the command output, got %q %v", out, err)
+	}
+}
+
+// TestWithContextCleanup tests that cleanups run after the view that
+// registered them was cancelled
+func TestWithContextCleanup(t *testing.T) {
+	fake := newFakeTransport()
+	fake.handle("echo $$ 2>/dev/null > ", func(cmd string) (string, error) {
+		if strings.Contains(cmd, "settings get system screen_off_timeout") {
+			return "60000\n", nil
+		}
+		return "", nil
+	})
+	d := device.NewTestDevice("fake", fake)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := d.WithContext(ctx).PutSetting(device.SettingsSystem, "screen_off_timeout", "600000"); err != nil {
+		t.Fatalf("Failed to put setting: %v", err)
+	}
+	cancel()
+
+	if _, err := d.WithContext(ctx).RunShellCommand("ls"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected canceled error, got %v", err)
+	}
+
+	if err := d.Close(); err != nil {
+		t.Fatalf("Failed to close device: %v", err)
+	}
+
+	history := fake.history()
+	if last := history[len(history)-1]; last != "settings put system screen_off_timeout 60000" {
+		t.Errorf("Setting should be restored without the cancelled context, got %q", last)
+	}
+}
+
+// TestSleepCancel tests that Sleep returns when the context is done
+func TestSleepCancel(t *testing.T) {
+	d := device.NewTestDevice("fake", newFakeTransport())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	d.WithContext(ctx).Sleep(time.Minute)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Sleep should return immediately, took %s", elapsed)
+	}
+}
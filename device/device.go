@@ -1,18 +1,34 @@
 package device
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"os"
 	"path"
 	"path/filepath"
-	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/electricbubble/gadb"
 )
 
+// defaultAdbAddress is the address of the local ADB server.
+var defaultAdbAddress = fmt.Sprintf("localhost:%d", gadb.AdbServerPort)
+
+// Default durations of gestures and waits, and of reconnecting.
+const (
+	DefaultSwipeDuration     = 500 * time.Millisecond
+	DefaultLongTapDuration   = 2 * time.Second
+	DefaultSleepDuration     = time.Second
+	DefaultReconnectAttempts = 5
+	DefaultReconnectBackoff  = 250 * time.Millisecond
+)
+
 // TempPath is the path to the temporary directory on the device.
 var TempPath = "/data/local/tmp"
 
@@ -21,28 +37,11 @@ type App struct {
 	PackageName string `json:"package_name"`
 }
 
-// SystemInfo represents the system information of the device.
-type SystemInfo struct {
-	Model            string `json:"model"`             // Device model
-	Brand            string `json:"brand"`             // Device brand
-	Manufacturer     string `json:"manufacturer"`      // Manufacturer
-	AndroidVersion   string `json:"android_version"`   // Android version
-	SDK              int    `json:"sdk"`               // SDK version
-	Battery          int    `json:"battery"`           // Battery percentage
-	BatteryStatus    string `json:"battery_status"`    // Battery status (charging/discharging)
-	ScreenWidth      int    `json:"screen_width"`      // Screen width
-	ScreenHeight     int    `json:"screen_height"`     // Screen height
-	ScreenDensity    int    `json:"screen_density"`    // Screen density
-	NetworkType      string `json:"network_type"`      // Network type (WiFi/Mobile data)
-	WifiSSID         string `json:"wifi_ssid"`         // WiFi SSID
-	WifiSignal       int    `json:"wifi_signal"`       // WiFi signal strength
-	LocationEnabled  bool   `json:"location_enabled"`  // Location service enabled
-	IMEI             string `json:"imei"`              // IMEI
-	SerialNumber     string `json:"serial_number"`     // Serial number
-	TotalRAM         int64  `json:"total_ram"`         // Total RAM (bytes)
-	AvailableRAM     int64  `json:"available_ram"`     // Available RAM (bytes)
-	TotalStorage     int64  `json:"total_storage"`     // Total storage (bytes)
-	AvailableStorage int64  `json:"available_storage"` // Available storage (bytes)
+// transport is the subset of gadb.Device used by AndroidDevice.
+type transport interface {
+	RunShellCommand(cmd string, args ...string) (string, error)
+	PushFile(local *os.File, remotePath string, modification ...time.Time) error
+	Pull(remotePath string, dest io.Writer) error
 }
 
 // Option is a function that can be used to configure an AndroidDevice instance.
@@ -51,41 +50,170 @@ type Option func(*AndroidDevice)
 // AndroidDevice represents an Android device.
 type AndroidDevice struct {
 	id              string
-	adb             gadb.Device
+	adb             transport
 	swipeDuration   time.Duration
 	longTapDuration time.Duration
 	sleepDuration   time.Duration
 	screenshotPath  string
 	screenPassword  string
+	adbAddress      string
+
+	reconnectAttempts int
+	reconnectBackoff  time.Duration
+
+	// ctx bounds the operations of a view created by WithContext
+	ctx context.Context
+
+	// session is shared by the device and all its views
+	*session
+}
+
+// session is the state of a device shared by its context views.
+type session struct {
+	mu       sync.Mutex
+	sdk      int
+	settings map[settingKey]string
+	cleanups []cleanup
+	route    *routePlayer
+	doze     *dozeChanges
+	ops      opLock
+	features map[string]bool
+}
+
+// cleanup is a named function run when the device is closed. It receives
+// a view of the device without deadline, so cleanups run even when the
+// operation that registered them was cancelled.
+type cleanup struct {
+	name string
+	fn   func(d *AndroidDevice) error
 }
 
 // NewAndroidDevice creates a new AndroidDevice instance.
 func NewAndroidDevice(id string, opts ...Option) (*AndroidDevice, error) {
-	adb, err := getAdb(id)
-	if err != nil {
+	if IsNetworkSerial(id) {
+		return newWirelessDevice(id, opts...)
+	}
+
+	d := newAndroidDevice(id, nil, opts...)
+	if err := d.supervise(func() (transport, error) {
+		return resolveAdb(d.adbAddress, d.id)
+	}); err != nil {
 		return nil, err
 	}
 
+	return d, nil
+}
+
+// newAndroidDevice creates a new AndroidDevice on top of the given transport.
+func newAndroidDevice(id string, adb transport, opts ...Option) *AndroidDevice {
 	wd, _ := os.Getwd()
 	d := &AndroidDevice{
 		id:              id,
 		adb:             adb,
-		swipeDuration:   time.Millisecond * 500,
-		longTapDuration: time.Second * 2,
-		sleepDuration:   time.Second,
+		swipeDuration:   DefaultSwipeDuration,
+		longTapDuration: DefaultLongTapDuration,
+		sleepDuration:   DefaultSleepDuration,
 		screenshotPath:  path.Join(wd, "screenshot"),
+		adbAddress:      defaultAdbAddress,
+
+		reconnectAttempts: DefaultReconnectAttempts,
+		reconnectBackoff:  DefaultReconnectBackoff,
+
+		ctx:     context.Background(),
+		session: &session{},
 	}
 
 	for _, opt := range opts {
 		opt(d)
 	}
 
-	return d, nil
+	d.onClose("restore settings", (*AndroidDevice).RestoreSettings)
+
+	return d
+}
+
+// Serial returns the serial number of the device.
+func (d *AndroidDevice) Serial() string {
+	return d.id
+}
+
+// onClose registers a function to run when the device is closed.
+// Functions run in reverse order of registration.
+func (d *AndroidDevice) onClose(name string, fn func(d *AndroidDevice) error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.cleanups = append(d.cleanups, cleanup{name: name, fn: fn})
+}
+
+// onCloseOnce registers a function to run when the device is closed unless
+// a function with the same name is already registered.
+func (d *AndroidDevice) onCloseOnce(name string, fn func(d *AndroidDevice) error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, c := range d.cleanups {
+		if c.name == name {
+			return
+		}
+	}
+
+	d.cleanups = append(d.cleanups, cleanup{name: name, fn: fn})
+}
+
+// hasCleanup reports whether a cleanup with the given name is registered.
+func (d *AndroidDevice) hasCleanup(name string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, c := range d.cleanups {
+		if c.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// OnClose registers a function to run when the device is closed unless a
+// function with the same name is already registered, e.g. to stop background
+// work that uses the device.
+func (d *AndroidDevice) OnClose(name string, fn func() error) {
+	d.onCloseOnce(name, func(*AndroidDevice) error {
+		return fn()
+	})
 }
 
-// getAdb returns a new gadb.Device instance.
-func getAdb(id string) (gadb.Device, error) {
-	adb, err := gadb.NewClient()
+// Close reverts the changes made to the device during the session.
+func (d *AndroidDevice) Close() error {
+	d.mu.Lock()
+	cleanups := d.cleanups
+	d.cleanups = nil
+	d.mu.Unlock()
+
+	background := d.WithContext(context.Background())
+
+	var errs []error
+	for i := len(cleanups) - 1; i >= 0; i-- {
+		if err := cleanups[i].fn(background); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", cleanups[i].name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// getAdb returns a new gadb.Device instance from the ADB server at addr.
+func getAdb(addr, id string) (gadb.Device, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return gadb.Device{}, err
+	}
+	portNumber, err := strconv.Atoi(port)
+	if err != nil {
+		return gadb.Device{}, fmt.Errorf("invalid ADB server address %q", addr)
+	}
+
+	adb, err := gadb.NewClientWith(host, portNumber)
 	if err != nil {
 		return gadb.Device{}, err
 	}
@@ -104,6 +232,19 @@ func getAdb(id string) (gadb.Device, error) {
 	return gadb.Device{}, nil
 }
 
+// resolveAdb finds the device with serial id on the ADB server at addr.
+func resolveAdb(addr, id string) (transport, error) {
+	adb, err := getAdb(addr, id)
+	if err != nil {
+		return nil, err
+	}
+	if adb.Serial() == "" {
+		return nil, fmt.Errorf("device %q not found", id)
+	}
+
+	return adb, nil
+}
+
 // WithSwipeDuration sets the swipe duration for the device.
 func WithSwipeDuration(duration time.Duration) Option {
 	return func(d *AndroidDevice) {
@@ -152,15 +293,15 @@ func (d *AndroidDevice) InstallApp(filePath string, reinstall ...bool) (err erro
 	}
 
 	remotePath := path.Join(TempPath, apkName)
-	if err := d.adb.PushFile(appFile, remotePath); err != nil {
+	if err := d.pushFile(appFile, remotePath); err != nil {
 		return fmt.Errorf("apk push: %w", err)
 	}
 
 	var shellOutput string
 	if len(reinstall) != 0 && reinstall[0] {
-		shellOutput, err = d.RunShellCommand("pm install", "-r", remotePath)
+		shellOutput, err = d.shell("pm install -r", remotePath)
 	} else {
-		shellOutput, err = d.RunShellCommand("pm install", remotePath)
+		shellOutput, err = d.shell("pm install", remotePath)
 	}
 
 	if err != nil {
@@ -178,9 +319,9 @@ func (d *AndroidDevice) InstallApp(filePath string, reinstall ...bool) (err erro
 func (d *AndroidDevice) UninstallApp(packageName string, keepData ...bool) (err error) {
 	var shellOutput string
 	if len(keepData) != 0 && keepData[0] {
-		shellOutput, err = d.RunShellCommand("pm uninstall", "-k", packageName)
+		shellOutput, err = d.shell("pm uninstall -k", packageName)
 	} else {
-		shellOutput, err = d.RunShellCommand("pm uninstall", packageName)
+		shellOutput, err = d.shell("pm uninstall", packageName)
 	}
 
 	if err != nil {
@@ -196,14 +337,14 @@ func (d *AndroidDevice) UninstallApp(packageName string, keepData ...bool) (err
 
 // TerminateApp terminates an app on the device.
 func (d *AndroidDevice) TerminateApp(packageName string) (err error) {
-	_, err = d.RunShellCommand("am force-stop", packageName)
+	_, err = d.shell("am force-stop", packageName)
 	return
 }
 
 // LaunchApp launches an app on the device.
 func (d *AndroidDevice) LaunchApp(packageName string) (err error) {
 	var shellOutput string
-	if shellOutput, err = d.RunShellCommand("monkey -p", packageName, "-c android.intent.category.LAUNCHER 1"); err != nil {
+	if shellOutput, err = d.shell("monkey -p", packageName, "-c", "android.intent.category.LAUNCHER", "1"); err != nil {
 		return err
 	}
 
@@ -247,13 +388,20 @@ func (d *AndroidDevice) ListApp() ([]App, error) {
 
 // InstalledApp checks if an app is installed on the device.
 func (d *AndroidDevice) InstalledApp(packageName string) (bool, error) {
-	out, err := d.RunShellCommand("pm list packages|grep ", packageName)
-
+	out, err := d.shell("pm path", packageName)
 	if err != nil {
 		return false, err
 	}
 
-	return len(out) != 0, nil
+	// pm path prints nothing for unknown packages, and one line per APK
+	// for installed ones, e.g. package:/data/app/com.example.app/base.apk
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "package:") {
+			return true, nil
+		}
+	}
+
+	return false, nil
 }
 
 // UnlockScreen unlocks the screen of the device.
@@ -334,21 +482,33 @@ func (d *AndroidDevice) IsScreenActive() (bool, error) {
 	return strings.Contains(out, "mWakefulness=Awake"), nil
 }
 
+// inputTextEscaper encodes spaces the way `input text` expects.
+var inputTextEscaper = strings.NewReplacer(" ", "%s")
+
 // InputText inputs text on the device.
-func (d *AndroidDevice) InputText(text string) (err error) {
-	_, err = d.RunShellCommand("input", "text", text)
+// e.g. InputText("你好", true) pastes the text through the clipboard
+func (d *AndroidDevice) InputText(text string, paste ...bool) (err error) {
+	if len(paste) != 0 && paste[0] {
+		return d.PasteText(text)
+	}
+
+	_, err = d.shell("input text", inputTextEscaper.Replace(text))
 	return
 }
 
 // InputKey inputs a key on the device.
 func (d *AndroidDevice) InputKey(keyCode int) (err error) {
-	_, err = d.RunShellCommand("input", "keyevent", strconv.Itoa(keyCode))
+	_, err = d.shell("input keyevent", strconv.Itoa(keyCode))
 	return
 }
 
 // RunShellCommand runs a shell command on the device.
 func (d *AndroidDevice) RunShellCommand(cmd string, args ...string) (string, error) {
-	return d.adb.RunShellCommand(cmd, args...)
+	if d.ctx.Done() == nil {
+		return d.adb.RunShellCommand(cmd, args...)
+	}
+
+	return d.runShellContext(cmd, args...)
 }
 
 // Swipe swipes on the device from one point to another point.
@@ -358,7 +518,7 @@ func (d *AndroidDevice) Swipe(x, y, x2, y2 int, duration ...time.Duration) (err
 	}
 
 	dur := int(duration[0] / time.Millisecond)
-	_, err = d.RunShellCommand("input", "swipe", strconv.Itoa(x), strconv.Itoa(y), strconv.Itoa(x2),
+	_, err = d.shell("input swipe", strconv.Itoa(x), strconv.Itoa(y), strconv.Itoa(x2),
 		strconv.Itoa(y2), strconv.Itoa(dur))
 	return
 }
@@ -427,41 +587,35 @@ func (d *AndroidDevice) getSwipeArgs(r1, r2 float64, args ...any) (float64, floa
 	return r1, r2, duration
 }
 
-// ScreenSize returns the screen size of the device.
+// ScreenSize returns the effective screen size of the device in the current orientation.
 func (d *AndroidDevice) ScreenSize() (int, int, error) {
 	out, err := d.RunShellCommand("wm size")
 	if err != nil {
 		return 0, 0, err
 	}
 
-	// Regular expression to match width and height values in "Physical size: 1080x2400"
-	reg := regexp.MustCompile(`Physical size: (\d+)x(\d+)`)
-	match := reg.FindStringSubmatch(out)
-	if len(match) != 3 {
-		return 0, 0, fmt.Errorf("failed to parse screen size: %s", out)
+	width, height, err := parseScreenSize(out)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// Coordinates follow the display rotation, fall back to the natural
+	// orientation when the rotation cannot be read.
+	if rotation, err := d.Rotation(); err == nil && (rotation == 90 || rotation == 270) {
+		width, height = height, width
 	}
 
-	width, _ := strconv.Atoi(match[1])
-	height, _ := strconv.Atoi(match[2])
 	return width, height, nil
 }
 
-// ScreenDpi returns the screen DPI of the device.
+// ScreenDpi returns the effective screen DPI of the device.
 func (d *AndroidDevice) ScreenDpi() (int, error) {
 	out, err := d.RunShellCommand("wm density")
 	if err != nil {
 		return 0, err
 	}
 
-	// Regular expression to match DPI value in "Physical density: 440"
-	reg := regexp.MustCompile(`Physical density: (\d+)`)
-	match := reg.FindStringSubmatch(out)
-	if len(match) != 2 {
-		return 0, fmt.Errorf("failed to parse screen DPI: %s", out)
-	}
-
-	dpi, _ := strconv.Atoi(match[1])
-	return dpi, nil
+	return parseScreenDensity(out)
 }
 
 // Screenshot takes a screenshot of the device and saves it to the screenshotPath.
@@ -482,7 +636,7 @@ func (d *AndroidDevice) Screenshot() (*os.File, error) {
 
 	remotePath := path.Join(TempPath, filename)
 
-	_, err := d.RunShellCommand("screencap", "-p", remotePath)
+	_, err := d.shell("screencap -p", remotePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to capture screenshot: %w", err)
 	}
@@ -492,13 +646,13 @@ func (d *AndroidDevice) Screenshot() (*os.File, error) {
 		return nil, fmt.Errorf("failed to create local file: %w", err)
 	}
 
-	if err := d.adb.Pull(remotePath, file); err != nil {
+	if err := d.pull(remotePath, file); err != nil {
 		file.Close()
 		os.Remove(localPath)
 		return nil, fmt.Errorf("failed to pull screenshot: %w", err)
 	}
 
-	_, _ = d.RunShellCommand("rm", remotePath)
+	_, _ = d.shell("rm -f", remotePath)
 
 	if _, err := file.Seek(0, 0); err != nil {
 		file.Close()
@@ -510,7 +664,7 @@ func (d *AndroidDevice) Screenshot() (*os.File, error) {
 
 // Tap taps on the device at the specified coordinates.
 func (d *AndroidDevice) Tap(x, y int) (err error) {
-	_, err = d.RunShellCommand("input", "tap", strconv.Itoa(x), strconv.Itoa(y))
+	_, err = d.shell("input tap", strconv.Itoa(x), strconv.Itoa(y))
 	return
 }
 
@@ -540,156 +694,53 @@ func (d *AndroidDevice) Back(num ...int) error {
 	return nil
 }
 
-// Sleep sleeps for a specified duration.
-func (d *AndroidDevice) Sleep(delay ...time.Duration) {
-	if len(delay) != 0 {
-		time.Sleep(delay[0])
-	} else {
-		time.Sleep(d.sleepDuration)
-	}
-}
-
-// SystemInfo returns the system information of the device.
-func (d *AndroidDevice) SystemInfo() (info *SystemInfo, err error) {
-	info = &SystemInfo{}
-
-	// Get device basic information
-	propOutput, err := d.RunShellCommand("getprop")
+// getprop returns the value of a system property.
+func (d *AndroidDevice) getprop(name string) (string, error) {
+	out, err := d.shell("getprop", name)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get device properties: %w", err)
+		return "", fmt.Errorf("getprop %s: %w", name, err)
 	}
 
-	// Parse device model
-	if match := regexp.MustCompile(`\[ro.product.model\]:\s*\[(.*?)\]`).FindStringSubmatch(propOutput); len(match) > 1 {
-		info.Model = match[1]
-	}
-
-	// Parse device brand
-	if match := regexp.MustCompile(`\[ro.product.brand\]:\s*\[(.*?)\]`).FindStringSubmatch(propOutput); len(match) > 1 {
-		info.Brand = match[1]
-	}
-
-	// Parse manufacturer
-	if match := regexp.MustCompile(`\[ro.product.manufacturer\]:\s*\[(.*?)\]`).FindStringSubmatch(propOutput); len(match) > 1 {
-		info.Manufacturer = match[1]
-	}
+	return strings.TrimSpace(out), nil
+}
 
-	// Parse Android version
-	if match := regexp.MustCompile(`\[ro.build.version.release\]:\s*\[(.*?)\]`).FindStringSubmatch(propOutput); len(match) > 1 {
-		info.AndroidVersion = match[1]
+// SDK returns the API level of the device.
+func (d *AndroidDevice) SDK() (int, error) {
+	d.mu.Lock()
+	sdk := d.sdk
+	d.mu.Unlock()
+	if sdk != 0 {
+		return sdk, nil
 	}
 
-	// Parse SDK version
-	if match := regexp.MustCompile(`\[ro.build.version.sdk\]:\s*\[(.*?)\]`).FindStringSubmatch(propOutput); len(match) > 1 {
-		info.SDK, _ = strconv.Atoi(match[1])
+	value, err := d.getprop("ro.build.version.sdk")
+	if err != nil {
+		return 0, err
 	}
 
-	// Parse serial number
-	if match := regexp.MustCompile(`\[ro.serialno\]:\s*\[(.*?)\]`).FindStringSubmatch(propOutput); len(match) > 1 {
-		info.SerialNumber = match[1]
+	if sdk, err = strconv.Atoi(value); err != nil {
+		return 0, fmt.Errorf("failed to parse SDK version: %q", value)
 	}
 
-	// Get battery information
-	batteryOutput, err := d.RunShellCommand("dumpsys battery")
-	if err == nil {
-		// Parse battery level
-		if match := regexp.MustCompile(`level: (\d+)`).FindStringSubmatch(batteryOutput); len(match) > 1 {
-			info.Battery, _ = strconv.Atoi(match[1])
-		}
+	d.mu.Lock()
+	d.sdk = sdk
+	d.mu.Unlock()
 
-		// Parse battery status
-		if strings.Contains(batteryOutput, "status: 2") {
-			info.BatteryStatus = "Charging"
-		} else if strings.Contains(batteryOutput, "status: 5") {
-			info.BatteryStatus = "Full"
-		} else {
-			info.BatteryStatus = "Discharging"
-		}
-	}
-
-	// Get screen information
-	info.ScreenWidth, info.ScreenHeight, _ = d.ScreenSize()
-	info.ScreenDensity, _ = d.ScreenDpi()
-
-	// Get network information
-	wifiOutput, err := d.RunShellCommand("dumpsys wifi")
-	if err == nil {
-		// Check if WiFi is connected
-		if strings.Contains(wifiOutput, "mNetworkInfo") && strings.Contains(wifiOutput, "state: CONNECTED") {
-			info.NetworkType = "WiFi"
-
-			// Get WiFi SSID
-			if match := regexp.MustCompile(`"(.*?)"`).FindStringSubmatch(wifiOutput); len(match) > 1 {
-				info.WifiSSID = match[1]
-			}
-
-			// Get WiFi signal strength
-			if match := regexp.MustCompile(`RSSI: (-?\d+)`).FindStringSubmatch(wifiOutput); len(match) > 1 {
-				info.WifiSignal, _ = strconv.Atoi(match[1])
-			}
-		} else {
-			// Check mobile data
-			dataOutput, err := d.RunShellCommand("dumpsys telephony.registry")
-			if err == nil && strings.Contains(dataOutput, "mDataConnectionState=2") {
-				info.NetworkType = "Mobile Data"
-			} else {
-				info.NetworkType = "No Network"
-			}
-		}
-	}
+	return sdk, nil
+}
 
-	// Get location service status
-	locationOutput, err := d.RunShellCommand("settings get secure location_providers_allowed")
-	if err == nil {
-		info.LocationEnabled = locationOutput != ""
+// Sleep sleeps for a specified duration.
+func (d *AndroidDevice) Sleep(delay ...time.Duration) {
+	duration := d.sleepDuration
+	if len(delay) != 0 {
+		duration = delay[0]
 	}
 
-	// Get IMEI (requires READ_PHONE_STATE permission)
-	imeiOutput, err := d.RunShellCommand("service call iphonesubinfo 1")
-	if err == nil && !strings.Contains(imeiOutput, "Exception") {
-		// Parse IMEI (complex format, needs special handling)
-		var imei strings.Builder
-		for _, match := range regexp.MustCompile(`'(.*?)'`).FindAllStringSubmatch(imeiOutput, -1) {
-			if len(match) > 1 {
-				imei.WriteString(match[1])
-			}
-		}
-		info.IMEI = strings.Replace(imei.String(), ".", "", -1)
-	}
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
 
-	// Get memory information
-	memOutput, err := d.RunShellCommand("cat /proc/meminfo")
-	if err == nil {
-		// Parse total memory
-		if match := regexp.MustCompile(`MemTotal:\s+(\d+) kB`).FindStringSubmatch(memOutput); len(match) > 1 {
-			totalKB, _ := strconv.ParseInt(match[1], 10, 64)
-			info.TotalRAM = totalKB * 1024 // Convert to bytes
-		}
-
-		// Parse available memory
-		if match := regexp.MustCompile(`MemAvailable:\s+(\d+) kB`).FindStringSubmatch(memOutput); len(match) > 1 {
-			availableKB, _ := strconv.ParseInt(match[1], 10, 64)
-			info.AvailableRAM = availableKB * 1024 // Convert to bytes
-		}
-	}
-
-	// Get storage information
-	storageOutput, err := d.RunShellCommand("df /data")
-	if err == nil {
-		lines := strings.Split(storageOutput, "\n")
-		if len(lines) > 1 {
-			fields := strings.Fields(lines[1])
-			if len(fields) >= 4 {
-				// Total storage
-				totalBlocks, _ := strconv.ParseInt(fields[1], 10, 64)
-				info.TotalStorage = totalBlocks * 1024 // Convert to bytes
-
-				// Available storage
-				availableBlocks, _ := strconv.ParseInt(fields[3], 10, 64)
-				info.AvailableStorage = availableBlocks * 1024 // Convert to bytes
-			}
-		}
+	select {
+	case <-timer.C:
+	case <-d.ctx.Done():
 	}
-
-	return info, nil
 }
@@ -177,11 +177,16 @@ func TestSystemInfo(t *testing.T) {
 		t.Fatal("System information is nil")
 	}
 
-	t.Logf("Device model: %s", info.Model)
-	t.Logf("Brand: %s", info.Brand)
-	t.Logf("Android version: %s", info.AndroidVersion)
-	t.Logf("SDK version: %d", info.SDK)
-	t.Logf("Screen resolution: %dx%d", info.ScreenWidth, info.ScreenHeight)
+	if info.Build == nil || info.Display == nil {
+		t.Fatalf("Build or display information is missing: %v", info.Errors)
+	}
+
+	t.Logf("Device model: %s", info.Build.Model)
+	t.Logf("Brand: %s", info.Build.Brand)
+	t.Logf("Android version: %s", info.Build.AndroidVersion)
+	t.Logf("SDK version: %d", info.Build.SDK)
+	t.Logf("Screen resolution: %dx%d", info.Display.Width, info.Display.Height)
+	t.Logf("Errors: %v", info.Errors)
 }
 
 // TestRunShellCommand tests running shell commands
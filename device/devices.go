@@ -0,0 +1,113 @@
+package device
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DeviceEntry is a device known to the ADB server.
+type DeviceEntry struct {
+	Serial      string `json:"serial"`
+	State       string `json:"state"` // device, unauthorized, offline, recovery, ...
+	Product     string `json:"product,omitempty"`
+	Model       string `json:"model,omitempty"`
+	Device      string `json:"device,omitempty"`
+	TransportID string `json:"transport_id,omitempty"`
+}
+
+// ListDevices lists the devices of the ADB server at addr, the local
+// server if addr is empty.
+func ListDevices(addr string) ([]DeviceEntry, error) {
+	out, err := hostString(addr, "host:devices-l")
+	if err != nil {
+		return nil, err
+	}
+
+	return parseDevices(out), nil
+}
+
+// AdbServerVersion returns the protocol version of the ADB server at addr,
+// the local server if addr is empty.
+func AdbServerVersion(addr string) (int, error) {
+	out, err := hostString(addr, "host:version")
+	if err != nil {
+		return 0, err
+	}
+
+	version, err := strconv.ParseInt(strings.TrimSpace(out), 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("adb server: invalid version %q", out)
+	}
+
+	return int(version), nil
+}
+
+// hostString runs a host request answered with a string on the ADB server
+// at addr.
+func hostString(addr, request string) (string, error) {
+	if addr == "" {
+		addr = defaultAdbAddress
+	}
+
+	d := &AndroidDevice{adbAddress: addr}
+	return d.adbHostString(request)
+}
+
+// parseDevices parses the output of host:devices-l.
+// e.g. "emulator-5554 device product:sdk_gphone64 model:Pixel_7 device:emu64a transport_id:1"
+func parseDevices(out string) []DeviceEntry {
+	var devices []DeviceEntry
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		entry := DeviceEntry{Serial: fields[0], State: fields[1]}
+		for _, field := range fields[2:] {
+			key, value, _ := strings.Cut(field, ":")
+			switch key {
+			case "product":
+				entry.Product = value
+			case "model":
+				entry.Model = value
+			case "device":
+				entry.Device = value
+			case "transport_id":
+				entry.TransportID = value
+			}
+		}
+		devices = append(devices, entry)
+	}
+
+	return devices
+}
+
+// errDetached is returned by the operations of a detached device.
+var errDetached = errors.New("device is not connected")
+
+// NewDetachedDevice creates an AndroidDevice that is not connected, e.g. to
+// describe the tools offered for a device. Its operations fail.
+func NewDetachedDevice(id string, opts ...Option) *AndroidDevice {
+	return newAndroidDevice(id, detachedTransport{}, opts...)
+}
+
+// detachedTransport fails every operation.
+type detachedTransport struct{}
+
+func (detachedTransport) RunShellCommand(string, ...string) (string, error) {
+	return "", errDetached
+}
+
+func (detachedTransport) PushFile(*os.File, string, ...time.Time) error {
+	return errDetached
+}
+
+func (detachedTransport) Pull(string, io.Writer) error {
+	return errDetached
+}
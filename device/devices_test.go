@@ -0,0 +1,60 @@
+package device_test
+
+import (
+	"mcp-android-adb-server/device"
+	"testing"
+)
+
+// TestListDevices tests listing the devices of the ADB server
+func TestListDevices(t *testing.T) {
+	server := newFakeAdbServer(t)
+	server.reply("host:devices-l", "OKAY"+adbString(
+		"emulator-5554          device product:sdk_gphone64_arm64 model:sdk_gphone64_arm64 device:emu64a transport_id:1\n"+
+			"R58M123ABC             unauthorized usb:1-1 transport_id:2\n"+
+			"192.168.1.20:5555      offline transport_id:3\n"))
+
+	devices, err := device.ListDevices(server.addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []device.DeviceEntry{
+		{Serial: "emulator-5554", State: "device", Product: "sdk_gphone64_arm64", Model: "sdk_gphone64_arm64", Device: "emu64a", TransportID: "1"},
+		{Serial: "R58M123ABC", State: "unauthorized", TransportID: "2"},
+		{Serial: "192.168.1.20:5555", State: "offline", TransportID: "3"},
+	}
+	if len(devices) != len(want) {
+		t.Fatalf("Expected %d devices, got %+v", len(want), devices)
+	}
+	for i := range want {
+		if devices[i] != want[i] {
+			t.Errorf("Expected %+v, got %+v", want[i], devices[i])
+		}
+	}
+}
+
+// TestAdbServerVersion tests reading the ADB server version
+func TestAdbServerVersion(t *testing.T) {
+	server := newFakeAdbServer(t)
+	server.reply("host:version", "OKAY"+adbString("0029"))
+
+	version, err := device.AdbServerVersion(server.addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 41 {
+		t.Errorf("Expected version 41, got %d", version)
+	}
+}
+
+// TestDetachedDevice tests that a detached device fails its operations
+func TestDetachedDevice(t *testing.T) {
+	d := device.NewDetachedDevice("emulator-5554")
+
+	if d.Serial() != "emulator-5554" {
+		t.Errorf("Expected the serial to be kept, got %q", d.Serial())
+	}
+	if _, err := d.RunShellCommand("ls"); err == nil {
+		t.Error("Expected a detached device to fail")
+	}
+}
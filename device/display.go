@@ -0,0 +1,283 @@
+package device
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// e.g. "Physical size: 1080x2400" and "Override size: 720x1600"
+	physicalSizeRegexp = regexp.MustCompile(`Physical size: (\d+)x(\d+)`)
+	overrideSizeRegexp = regexp.MustCompile(`Override size: (\d+)x(\d+)`)
+
+	// e.g. "Physical density: 440" and "Override density: 320"
+	physicalDensityRegexp = regexp.MustCompile(`Physical density: (\d+)`)
+	overrideDensityRegexp = regexp.MustCompile(`Override density: (\d+)`)
+
+	// e.g. "mCurrentRotation=ROTATION_90" (Android 10+) or "mRotation=1"
+	currentRotationRegexp = regexp.MustCompile(`mCurrentRotation=(?:ROTATION_)?(\d+)`)
+	legacyRotationRegexp  = regexp.MustCompile(`mRotation=(\d+)`)
+
+	// e.g. "SurfaceOrientation: 1"
+	surfaceOrientationRegexp = regexp.MustCompile(`SurfaceOrientation: (\d+)`)
+
+	// e.g. "Night mode: yes"
+	nightModeRegexp = regexp.MustCompile(`Night mode: (\w+)`)
+)
+
+// Orientation names reported for the current display rotation.
+const (
+	OrientationPortrait  = "portrait"
+	OrientationLandscape = "landscape"
+)
+
+// DisplayInfo represents the current state of the device display.
+type DisplayInfo struct {
+	Width          int    `json:"width"`           // Effective width in the current orientation
+	Height         int    `json:"height"`          // Effective height in the current orientation
+	PhysicalWidth  int    `json:"physical_width"`  // Physical width in the natural orientation
+	PhysicalHeight int    `json:"physical_height"` // Physical height in the natural orientation
+	SizeOverridden bool   `json:"size_overridden"` // Whether `wm size` is overridden
+	Density        int    `json:"density"`         // Effective density
+	Rotation       int    `json:"rotation"`        // Rotation in degrees: 0, 90, 180 or 270
+	Orientation    string `json:"orientation"`     // portrait or landscape
+}
+
+// Display returns the current state of the device display.
+func (d *AndroidDevice) Display() (*DisplayInfo, error) {
+	out, err := d.RunShellCommand("wm size")
+	if err != nil {
+		return nil, err
+	}
+
+	info := &DisplayInfo{}
+	if info.Width, info.Height, err = parseScreenSize(out); err != nil {
+		return nil, err
+	}
+	info.SizeOverridden = overrideSizeRegexp.MatchString(out)
+
+	if match := physicalSizeRegexp.FindStringSubmatch(out); len(match) == 3 {
+		info.PhysicalWidth, _ = strconv.Atoi(match[1])
+		info.PhysicalHeight, _ = strconv.Atoi(match[2])
+	}
+
+	if info.Rotation, err = d.Rotation(); err != nil {
+		return nil, err
+	}
+	if info.Rotation == 90 || info.Rotation == 270 {
+		info.Width, info.Height = info.Height, info.Width
+	}
+
+	info.Orientation = OrientationPortrait
+	if info.Width > info.Height {
+		info.Orientation = OrientationLandscape
+	}
+
+	if info.Density, err = d.ScreenDpi(); err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// Rotation returns the current display rotation in degrees.
+func (d *AndroidDevice) Rotation() (int, error) {
+	out, err := d.RunShellCommand("dumpsys window displays")
+	if err != nil {
+		return 0, fmt.Errorf("get rotation: %w", err)
+	}
+
+	match := currentRotationRegexp.FindStringSubmatch(out)
+	if match == nil {
+		match = legacyRotationRegexp.FindStringSubmatch(out)
+	}
+	if match == nil {
+		// Older releases only report the orientation through the input service
+		if out, err = d.RunShellCommand("dumpsys input"); err != nil {
+			return 0, fmt.Errorf("get rotation: %w", err)
+		}
+		match = surfaceOrientationRegexp.FindStringSubmatch(out)
+	}
+	if match == nil {
+		return 0, fmt.Errorf("failed to parse display rotation")
+	}
+
+	rotation, _ := strconv.Atoi(match[1])
+	if rotation < 4 {
+		rotation *= 90
+	}
+
+	return rotation, nil
+}
+
+// SetRotation locks the display to the given rotation in degrees.
+func (d *AndroidDevice) SetRotation(degrees int) error {
+	if degrees%90 != 0 || degrees < 0 || degrees > 270 {
+		return fmt.Errorf("invalid rotation %d, expected 0, 90, 180 or 270", degrees)
+	}
+
+	if err := d.PutSetting(SettingsSystem, "accelerometer_rotation", "0"); err != nil {
+		return err
+	}
+
+	return d.PutSetting(SettingsSystem, "user_rotation", strconv.Itoa(degrees/90))
+}
+
+// SetAutoRotate enables or disables rotation by the accelerometer.
+func (d *AndroidDevice) SetAutoRotate(enabled bool) error {
+	value := "0"
+	if enabled {
+		value = "1"
+	}
+
+	return d.PutSetting(SettingsSystem, "accelerometer_rotation", value)
+}
+
+// SetScreenSize overrides the display size. The original size is restored when the device is closed.
+func (d *AndroidDevice) SetScreenSize(width, height int) error {
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("invalid screen size %dx%d", width, height)
+	}
+
+	if err := d.snapshotDisplay("size", overrideSizeRegexp); err != nil {
+		return err
+	}
+
+	return d.wm("size", fmt.Sprintf("%dx%d", width, height))
+}
+
+// ResetScreenSize resets the display size to the physical size.
+func (d *AndroidDevice) ResetScreenSize() error {
+	return d.wm("size", "reset")
+}
+
+// SetScreenDensity overrides the display density. The original density is restored when the device is closed.
+func (d *AndroidDevice) SetScreenDensity(dpi int) error {
+	if dpi <= 0 {
+		return fmt.Errorf("invalid screen density %d", dpi)
+	}
+
+	if err := d.snapshotDisplay("density", overrideDensityRegexp); err != nil {
+		return err
+	}
+
+	return d.wm("density", strconv.Itoa(dpi))
+}
+
+// ResetScreenDensity resets the display density to the physical density.
+func (d *AndroidDevice) ResetScreenDensity() error {
+	return d.wm("density", "reset")
+}
+
+// DarkMode reports whether the dark theme is enabled.
+func (d *AndroidDevice) DarkMode() (string, error) {
+	out, err := d.RunShellCommand("cmd uimode night")
+	if err != nil {
+		return "", fmt.Errorf("get dark mode: %w", err)
+	}
+
+	match := nightModeRegexp.FindStringSubmatch(out)
+	if len(match) != 2 {
+		return "", fmt.Errorf("failed to parse dark mode: %s", strings.TrimSpace(out))
+	}
+
+	return match[1], nil
+}
+
+// SetDarkMode enables or disables the dark theme. The original mode is restored when the device is closed.
+func (d *AndroidDevice) SetDarkMode(enabled bool) error {
+	original, err := d.DarkMode()
+	if err != nil {
+		return err
+	}
+
+	d.onCloseOnce("restore dark mode", func(d *AndroidDevice) error {
+		return d.setNightMode(original)
+	})
+
+	mode := "no"
+	if enabled {
+		mode = "yes"
+	}
+
+	return d.setNightMode(mode)
+}
+
+// setNightMode runs `cmd uimode night` with the given mode.
+func (d *AndroidDevice) setNightMode(mode string) error {
+	out, err := d.shell("cmd uimode night", mode)
+	if err != nil {
+		return fmt.Errorf("set dark mode: %w", err)
+	}
+
+	if !nightModeRegexp.MatchString(out) {
+		return fmt.Errorf("set dark mode: %s", strings.TrimSpace(out))
+	}
+
+	return nil
+}
+
+// snapshotDisplay records the current `wm` override of a display property so
+// that it is restored when the device is closed.
+func (d *AndroidDevice) snapshotDisplay(property string, override *regexp.Regexp) error {
+	out, err := d.shell("wm", property)
+	if err != nil {
+		return fmt.Errorf("get screen %s: %w", property, err)
+	}
+
+	original := "reset"
+	if match := override.FindStringSubmatch(out); match != nil {
+		original = strings.Join(match[1:], "x")
+	}
+
+	d.onCloseOnce("restore screen "+property, func(d *AndroidDevice) error {
+		return d.wm(property, original)
+	})
+
+	return nil
+}
+
+// wm runs a `wm` subcommand that prints nothing on success.
+func (d *AndroidDevice) wm(property, value string) error {
+	out, err := d.shell("wm", property, value)
+	if err != nil {
+		return fmt.Errorf("set screen %s: %w", property, err)
+	}
+
+	if out = strings.TrimSpace(out); out != "" {
+		return fmt.Errorf("set screen %s: %s", property, out)
+	}
+
+	return nil
+}
+
+// parseScreenSize parses the effective size from the output of `wm size`.
+func parseScreenSize(out string) (int, int, error) {
+	match := overrideSizeRegexp.FindStringSubmatch(out)
+	if match == nil {
+		match = physicalSizeRegexp.FindStringSubmatch(out)
+	}
+	if len(match) != 3 {
+		return 0, 0, fmt.Errorf("failed to parse screen size: %s", out)
+	}
+
+	width, _ := strconv.Atoi(match[1])
+	height, _ := strconv.Atoi(match[2])
+	return width, height, nil
+}
+
+// parseScreenDensity parses the effective density from the output of `wm density`.
+func parseScreenDensity(out string) (int, error) {
+	match := overrideDensityRegexp.FindStringSubmatch(out)
+	if match == nil {
+		match = physicalDensityRegexp.FindStringSubmatch(out)
+	}
+	if len(match) != 2 {
+		return 0, fmt.Errorf("failed to parse screen DPI: %s", out)
+	}
+
+	dpi, _ := strconv.Atoi(match[1])
+	return dpi, nil
+}
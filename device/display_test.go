@@ -0,0 +1,93 @@
+package device_test
+
+import (
+	"mcp-android-adb-server/device"
+	"reflect"
+	"testing"
+)
+
+// TestDisplayOverrideAndRotation tests reporting the effective size after an override and rotation
+func TestDisplayOverrideAndRotation(t *testing.T) {
+	fake := newFakeTransport()
+	fake.reply("wm size", "Physical size: 1080x2400\nOverride size: 720x1600\n")
+	fake.reply("wm density", "Physical density: 440\nOverride density: 320\n")
+	fake.reply("dumpsys window displays", "  mCurrentRotation=ROTATION_90 mLastOrientation=0\n")
+	d := device.NewTestDevice("fake", fake)
+
+	width, height, err := d.ScreenSize()
+	if err != nil {
+		t.Fatalf("Failed to get screen size: %v", err)
+	}
+	if width != 1600 || height != 720 {
+		t.Errorf("Invalid screen size: %dx%d", width, height)
+	}
+
+	display, err := d.Display()
+	if err != nil {
+		t.Fatalf("Failed to get display: %v", err)
+	}
+
+	expected := &device.DisplayInfo{
+		Width:          1600,
+		Height:         720,
+		PhysicalWidth:  1080,
+		PhysicalHeight: 2400,
+		SizeOverridden: true,
+		Density:        320,
+		Rotation:       90,
+		Orientation:    device.OrientationLandscape,
+	}
+	if !reflect.DeepEqual(display, expected) {
+		t.Errorf("Display does not match expected: expected %+v, got %+v", expected, display)
+	}
+}
+
+// TestSetRotation tests locking the rotation through the settings provider
+func TestSetRotation(t *testing.T) {
+	fake := newFakeTransport()
+	fake.reply("settings get system accelerometer_rotation", "1\n")
+	fake.reply("settings get system user_rotation", "0\n")
+	d := device.NewTestDevice("fake", fake)
+
+	if err := d.SetRotation(45); err == nil {
+		t.Error("Should return error for invalid rotation")
+	}
+
+	if err := d.SetRotation(270); err != nil {
+		t.Fatalf("Failed to set rotation: %v", err)
+	}
+
+	expected := []string{
+		"settings get system accelerometer_rotation",
+		"settings put system accelerometer_rotation 0",
+		"settings get system user_rotation",
+		"settings put system user_rotation 3",
+	}
+	if history := fake.history(); !reflect.DeepEqual(history, expected) {
+		t.Errorf("Commands do not match expected:\nexpected %q\ngot      %q", expected, history)
+	}
+}
+
+// TestSetScreenSizeRestore tests restoring the original screen size override on close
+func TestSetScreenSizeRestore(t *testing.T) {
+	fake := newFakeTransport()
+	fake.handle("wm size", func(cmd string) (string, error) {
+		if cmd != "wm size" {
+			return "", nil
+		}
+		return "Physical size: 1080x2400\nOverride size: 720x1600\n", nil
+	})
+	d := device.NewTestDevice("fake", fake)
+
+	if err := d.SetScreenSize(1080, 1920); err != nil {
+		t.Fatalf("Failed to set screen size: %v", err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatalf("Failed to close device: %v", err)
+	}
+
+	history := fake.history()
+	if last := history[len(history)-1]; last != "wm size 720x1600" {
+		t.Errorf("Expected original override to be restored, got %q", last)
+	}
+}
@@ -0,0 +1,257 @@
+package device
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultMaxOutput is the number of bytes of each output stream kept by Exec.
+const DefaultMaxOutput = 1 << 20
+
+// Output streams of Exec.
+const (
+	Stdout = "stdout"
+	Stderr = "stderr"
+)
+
+// Packet ids of the shell v2 protocol, see shell_protocol.h in the ADB sources.
+const (
+	shellStdout = 1
+	shellStderr = 2
+	shellExit   = 3
+)
+
+// exitMarker separates stdout from the exit code and stderr when the device
+// does not support the shell v2 protocol.
+const exitMarker = "__mcp_exit__"
+
+// ExecResult is the outcome of a shell command run with Exec.
+type ExecResult struct {
+	ExitCode  int    `json:"exit_code"`
+	Stdout    string `json:"stdout"`
+	Stderr    string `json:"stderr"`
+	Truncated bool   `json:"truncated"` // Output beyond MaxOutput was dropped
+}
+
+// failure describes why a command failed, from its stderr, else its stdout.
+func (r *ExecResult) failure() string {
+	for _, out := range []string{r.Stderr, r.Stdout} {
+		if out = strings.TrimSpace(out); out != "" {
+			return out
+		}
+	}
+	return fmt.Sprintf("exit status %d", r.ExitCode)
+}
+
+// ExecOptions configure Exec.
+type ExecOptions struct {
+	MaxOutput int                              // Bytes kept per stream, DefaultMaxOutput if zero
+	OnOutput  func(stream string, data []byte) // Called with the output as it arrives
+}
+
+// Exec runs a shell command and returns its exit code, stdout and stderr.
+// It streams the output with the shell v2 protocol when the device supports
+// it, otherwise stderr goes through a file and the output arrives at the end.
+// e.g. Exec("ls /sdcard", ExecOptions{})
+func (d *AndroidDevice) Exec(cmd string, opts ExecOptions) (*ExecResult, error) {
+	if err := d.ctx.Err(); err != nil {
+		return nil, err
+	}
+	if opts.MaxOutput <= 0 {
+		opts.MaxOutput = DefaultMaxOutput
+	}
+
+	out := &execOutput{max: opts.MaxOutput, onOutput: opts.OnOutput}
+
+	if d.hasFeature("shell_v2") {
+		result, err := d.execV2(cmd, out)
+		if !errors.Is(err, errNotStarted) {
+			return result, err
+		}
+	}
+
+	return d.execCompat(cmd, out)
+}
+
+// errNotStarted reports that the shell v2 service could not be opened, so
+// the command can still run another way.
+var errNotStarted = errors.New("shell v2 not started")
+
+// execV2 runs cmd with the shell v2 protocol. It opens its own connection
+// to the ADB server, so it reconnects through the supervisor like the
+// commands of the transport.
+func (d *AndroidDevice) execV2(cmd string, out *execOutput) (result *ExecResult, err error) {
+	err = d.supervised(isReadOnlyCommand(cmd), func() error {
+		out.reset()
+		result, err = d.execV2Once(cmd, out)
+		return err
+	})
+	return result, err
+}
+
+// execV2Once runs cmd with the shell v2 protocol on a new connection.
+func (d *AndroidDevice) execV2Once(cmd string, out *execOutput) (*ExecResult, error) {
+	pidFile := ""
+	if d.ctx.Done() != nil {
+		cmd, pidFile = wrapCommand(cmd)
+	}
+
+	c, err := d.adbService("shell,v2,raw:" + cmd)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errNotStarted, err)
+	}
+	defer c.Close()
+
+	// Output can take longer than a request to the ADB server
+	_ = c.conn.SetDeadline(time.Time{})
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-d.ctx.Done():
+			d.killRemote(pidFile)
+			c.Close()
+		case <-stop:
+		}
+	}()
+
+	header := make([]byte, 5)
+	for {
+		if _, err := io.ReadFull(c.conn, header); err != nil {
+			if ctxErr := d.ctx.Err(); ctxErr != nil {
+				return nil, fmt.Errorf("%s: %w", unwrapCommand(cmd), ctxErr)
+			}
+			return nil, fmt.Errorf("shell: %w", err)
+		}
+
+		data := make([]byte, binary.LittleEndian.Uint32(header[1:]))
+		if _, err := io.ReadFull(c.conn, data); err != nil {
+			return nil, fmt.Errorf("shell: %w", err)
+		}
+
+		switch header[0] {
+		case shellStdout:
+			out.write(Stdout, data)
+		case shellStderr:
+			out.write(Stderr, data)
+		case shellExit:
+			if len(data) == 0 {
+				return nil, fmt.Errorf("shell: empty exit packet")
+			}
+			return out.result(int(data[0])), nil
+		}
+	}
+}
+
+// execCompat runs cmd with stderr redirected to a file that is read after
+// stdout and the exit code.
+func (d *AndroidDevice) execCompat(cmd string, out *execOutput) (*ExecResult, error) {
+	errFile := path.Join(TempPath, fmt.Sprintf(".mcp-%d-%d.err", os.Getpid(), pidSeq.Add(1)))
+	script := fmt.Sprintf("{ %s\n} 2>%s\nstatus=$?; printf '\\n%s%%d\\n' $status; cat %s 2>/dev/null; rm -f %s",
+		cmd, errFile, exitMarker, errFile, errFile)
+
+	output, err := d.RunShellCommand(script)
+	if err != nil {
+		return nil, err
+	}
+
+	stdout, rest, ok := cutLast(output, "\n"+exitMarker)
+	if !ok {
+		return nil, fmt.Errorf("shell: missing exit status in %q", output)
+	}
+	code, stderr, _ := strings.Cut(rest, "\n")
+
+	exitCode, err := strconv.Atoi(strings.TrimSpace(code))
+	if err != nil {
+		return nil, fmt.Errorf("shell: invalid exit status %q", code)
+	}
+
+	out.write(Stdout, []byte(stdout))
+	out.write(Stderr, []byte(stderr))
+	return out.result(exitCode), nil
+}
+
+// hasFeature reports whether the device supports an ADB feature, e.g.
+// shell_v2. Devices that cannot be asked are assumed not to.
+func (d *AndroidDevice) hasFeature(name string) bool {
+	d.mu.Lock()
+	features := d.features
+	d.mu.Unlock()
+
+	if features == nil {
+		out, err := d.adbHostString(fmt.Sprintf("host-serial:%s:features", d.id))
+		if err != nil {
+			return false
+		}
+
+		features = make(map[string]bool)
+		for _, feature := range strings.Split(out, ",") {
+			features[strings.TrimSpace(feature)] = true
+		}
+
+		d.mu.Lock()
+		d.features = features
+		d.mu.Unlock()
+	}
+
+	return features[name]
+}
+
+// execOutput collects the output of Exec up to max bytes per stream.
+type execOutput struct {
+	max       int
+	onOutput  func(stream string, data []byte)
+	stdout    []byte
+	stderr    []byte
+	truncated bool
+}
+
+func (o *execOutput) write(stream string, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	if o.onOutput != nil {
+		o.onOutput(stream, data)
+	}
+
+	buf := &o.stdout
+	if stream == Stderr {
+		buf = &o.stderr
+	}
+
+	if room := o.max - len(*buf); len(data) > room {
+		data = data[:max(room, 0)]
+		o.truncated = true
+	}
+	*buf = append(*buf, data...)
+}
+
+// reset drops the output collected by an attempt that is retried.
+func (o *execOutput) reset() {
+	o.stdout, o.stderr, o.truncated = nil, nil, false
+}
+
+func (o *execOutput) result(exitCode int) *ExecResult {
+	return &ExecResult{
+		ExitCode:  exitCode,
+		Stdout:    string(o.stdout),
+		Stderr:    string(o.stderr),
+		Truncated: o.truncated,
+	}
+}
+
+// cutLast slices s around the last instance of sep.
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
@@ -0,0 +1,110 @@
+package device_test
+
+import (
+	"encoding/binary"
+	"mcp-android-adb-server/device"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// shellPacket encodes a shell v2 packet.
+func shellPacket(id byte, data string) string {
+	header := make([]byte, 5)
+	header[0] = id
+	binary.LittleEndian.PutUint32(header[1:], uint32(len(data)))
+	return string(header) + data
+}
+
+// TestExecShellV2 tests running a command with the shell v2 protocol
+func TestExecShellV2(t *testing.T) {
+	server := newFakeAdbServer(t)
+	server.reply("host-serial:fake:features", "OKAY"+adbString("cmd,shell_v2,stat_v2"))
+	server.reply("shell,v2,raw:ls /missing", "OKAY"+
+		shellPacket(1, "a\n")+
+		shellPacket(2, "ls: /missing: No such file or directory\n")+
+		shellPacket(1, "b\n")+
+		shellPacket(3, "\x01"))
+
+	fake := newFakeTransport()
+	d := device.NewTestDevice("fake", fake, device.WithAdbAddress(server.addr()))
+
+	var streamed []string
+	result, err := d.Exec("ls /missing", device.ExecOptions{
+		OnOutput: func(stream string, data []byte) {
+			streamed = append(streamed, stream+":"+string(data))
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := device.ExecResult{ExitCode: 1, Stdout: "a\nb\n", Stderr: "ls: /missing: No such file or directory\n"}
+	if *result != want {
+		t.Errorf("Expected %+v, got %+v", want, *result)
+	}
+	if len(streamed) != 3 || streamed[1] != "stderr:ls: /missing: No such file or directory\n" {
+		t.Errorf("Expected the output in arrival order, got %q", streamed)
+	}
+	if len(fake.history()) != 0 {
+		t.Errorf("Expected no command through the transport, got %q", fake.history())
+	}
+}
+
+// TestExecCompat tests the fallback for devices without shell v2
+func TestExecCompat(t *testing.T) {
+	server := newFakeAdbServer(t)
+	server.reply("host-serial:fake:features", "OKAY"+adbString("cmd"))
+
+	fake := newFakeTransport()
+	fake.handle("{ cat /proc/version", func(cmd string) (string, error) {
+		if !strings.Contains(cmd, "2>/data/local/tmp/") {
+			t.Errorf("Expected stderr to be redirected, got %q", cmd)
+		}
+		return "Linux version 5.10\n\n__mcp_exit__2\ncat: warning\n", nil
+	})
+	d := device.NewTestDevice("fake", fake, device.WithAdbAddress(server.addr()))
+
+	result, err := d.Exec("cat /proc/version", device.ExecOptions{MaxOutput: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := device.ExecResult{ExitCode: 2, Stdout: "Linux ve", Stderr: "cat: war", Truncated: true}
+	if *result != want {
+		t.Errorf("Expected %+v, got %+v", want, *result)
+	}
+}
+
+// TestExecShellV2Reconnect tests that shell v2 commands reconnect through
+// the supervisor when the device went offline
+func TestExecShellV2Reconnect(t *testing.T) {
+	var opened atomic.Int32
+	server := newFakeAdbServer(t)
+	server.reply("host-serial:fake:features", "OKAY"+adbString("shell_v2"))
+	server.handle("shell,v2,raw:getprop ro.product.model", func(string) (string, error) {
+		if opened.Add(1) == 1 {
+			return adbFail("device offline"), nil
+		}
+		return "OKAY" + shellPacket(1, "Pixel 5\n") + shellPacket(3, "\x00"), nil
+	})
+
+	var resolved atomic.Int32
+	resolve := func() (device.Transport, error) {
+		resolved.Add(1)
+		return newFakeTransport(), nil
+	}
+	d, err := device.NewTestSupervisedDevice("fake", resolve, device.WithAdbAddress(server.addr()), device.WithReconnect(3, time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := d.Exec("getprop ro.product.model", device.ExecOptions{})
+	if err != nil || result.Stdout != "Pixel 5\n" {
+		t.Fatalf("Expected the command to succeed after reconnecting, got %+v %v", result, err)
+	}
+	if resolved.Load() != 2 || d.ConnectionStatus().Reconnects != 1 {
+		t.Errorf("Expected one reconnect, resolved %d times: %+v", resolved.Load(), d.ConnectionStatus())
+	}
+}
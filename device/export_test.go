@@ -0,0 +1,36 @@
+package device
+
+// NewTestDevice creates an AndroidDevice on top of a fake transport.
+func NewTestDevice(id string, adb transport, opts ...Option) *AndroidDevice {
+	return newAndroidDevice(id, adb, opts...)
+}
+
+// WithAdbAddress points the device at a fake ADB server.
+func WithAdbAddress(addr string) Option {
+	return func(d *AndroidDevice) {
+		d.adbAddress = addr
+	}
+}
+
+// NewTestWirelessDevice creates a network AndroidDevice that reconnects
+// through the ADB server set with WithAdbAddress.
+func NewTestWirelessDevice(id string, opts ...Option) (*AndroidDevice, error) {
+	return newWirelessDevice(id, opts...)
+}
+
+// Transport exposes the transport interface to fakes.
+type Transport = transport
+
+// NewTestSupervisedDevice creates an AndroidDevice that resolves its
+// transport with resolve and reconnects when it fails.
+func NewTestSupervisedDevice(id string, resolve func() (Transport, error), opts ...Option) (*AndroidDevice, error) {
+	d := newAndroidDevice(id, nil, opts...)
+	if err := d.supervise(resolve); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// IsReadOnlyCommand exposes the check deciding whether a command is retried.
+var IsReadOnlyCommand = isReadOnlyCommand
@@ -0,0 +1,257 @@
+package device_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeTransport is an in-memory replacement for the ADB device transport.
+// Shell commands are answered by the first handler whose prefix matches.
+type fakeTransport struct {
+	mu       sync.Mutex
+	handlers []fakeHandler
+	commands []string
+	files    map[string][]byte
+}
+
+// fakeHandler answers shell commands starting with prefix.
+type fakeHandler struct {
+	prefix string
+	fn     func(cmd string) (string, error)
+}
+
+func newFakeTransport() *fakeTransport {
+	return &fakeTransport{files: make(map[string][]byte)}
+}
+
+// handle registers a handler for shell commands starting with prefix.
+func (f *fakeTransport) handle(prefix string, fn func(cmd string) (string, error)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.handlers = append(f.handlers, fakeHandler{prefix: prefix, fn: fn})
+}
+
+// reply registers a fixed output for shell commands starting with prefix.
+func (f *fakeTransport) reply(prefix, out string) {
+	f.handle(prefix, func(string) (string, error) { return out, nil })
+}
+
+// history returns the shell commands run so far.
+func (f *fakeTransport) history() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]string(nil), f.commands...)
+}
+
+// fakeExit is returned by a handler to make a command run with Exec exit
+// with a status and stderr.
+type fakeExit struct {
+	code   int
+	stderr string
+}
+
+func (e *fakeExit) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+func (f *fakeTransport) RunShellCommand(cmd string, args ...string) (string, error) {
+	if len(args) > 0 {
+		cmd = fmt.Sprintf("%s %s", cmd, strings.Join(args, " "))
+	}
+
+	// Commands run by Exec on a device without shell v2 are answered by the
+	// handlers of the wrapped command, unless a handler expects the script
+	if inner, ok := unwrapExec(cmd); ok && f.handler(cmd) == nil {
+		out, err := f.run(inner)
+		var exit *fakeExit
+		switch {
+		case errors.As(err, &exit):
+			return fmt.Sprintf("%s\n__mcp_exit__%d\n%s", out, exit.code, exit.stderr), nil
+		case err != nil:
+			return "", err
+		}
+		return out + "\n__mcp_exit__0\n", nil
+	}
+
+	return f.run(cmd)
+}
+
+// run records and answers a shell command.
+func (f *fakeTransport) run(cmd string) (string, error) {
+	f.mu.Lock()
+	f.commands = append(f.commands, cmd)
+	f.mu.Unlock()
+
+	if h := f.handler(cmd); h != nil {
+		return h.fn(cmd)
+	}
+
+	return "", nil
+}
+
+// handler returns the first handler matching cmd.
+func (f *fakeTransport) handler(cmd string) *fakeHandler {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i, h := range f.handlers {
+		if strings.HasPrefix(cmd, h.prefix) {
+			return &f.handlers[i]
+		}
+	}
+
+	return nil
+}
+
+// unwrapExec returns the command of an Exec script.
+func unwrapExec(cmd string) (string, bool) {
+	if !strings.HasPrefix(cmd, "{ ") {
+		return "", false
+	}
+
+	inner, _, ok := strings.Cut(strings.TrimPrefix(cmd, "{ "), "\n} 2>")
+	return inner, ok
+}
+
+func (f *fakeTransport) PushFile(local *os.File, remotePath string, modification ...time.Time) error {
+	data, err := io.ReadAll(local)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.files[remotePath] = data
+	return nil
+}
+
+func (f *fakeTransport) Pull(remotePath string, dest io.Writer) error {
+	f.mu.Lock()
+	data, ok := f.files[remotePath]
+	f.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("remote object '%s' does not exist", remotePath)
+	}
+
+	_, err := dest.Write(data)
+	return err
+}
+
+// fakeAdbServer is a local server speaking the ADB host protocol.
+// Requests are answered with the raw reply of the first handler whose
+// prefix matches; host:transport requests are always accepted.
+type fakeAdbServer struct {
+	ln       net.Listener
+	mu       sync.Mutex
+	handlers []fakeHandler
+	requests []string
+}
+
+func newFakeAdbServer(t *testing.T) *fakeAdbServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	f := &fakeAdbServer{ln: ln}
+	go f.serve()
+	return f
+}
+
+func (f *fakeAdbServer) addr() string {
+	return f.ln.Addr().String()
+}
+
+// handle registers a handler for requests starting with prefix.
+func (f *fakeAdbServer) handle(prefix string, fn func(request string) (string, error)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.handlers = append(f.handlers, fakeHandler{prefix: prefix, fn: fn})
+}
+
+// reply registers a fixed raw reply for requests starting with prefix.
+func (f *fakeAdbServer) reply(prefix, raw string) {
+	f.handle(prefix, func(string) (string, error) { return raw, nil })
+}
+
+// history returns the requests received so far.
+func (f *fakeAdbServer) history() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]string(nil), f.requests...)
+}
+
+func (f *fakeAdbServer) serve() {
+	for {
+		conn, err := f.ln.Accept()
+		if err != nil {
+			return
+		}
+		go f.serveConn(conn)
+	}
+}
+
+func (f *fakeAdbServer) serveConn(conn net.Conn) {
+	defer conn.Close()
+
+	for {
+		size := make([]byte, 4)
+		if _, err := io.ReadFull(conn, size); err != nil {
+			return
+		}
+		n, err := strconv.ParseUint(string(size), 16, 32)
+		if err != nil {
+			return
+		}
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return
+		}
+		request := string(buf)
+
+		f.mu.Lock()
+		f.requests = append(f.requests, request)
+		handlers := f.handlers
+		f.mu.Unlock()
+
+		if strings.HasPrefix(request, "host:transport:") {
+			conn.Write([]byte("OKAY"))
+			continue
+		}
+
+		raw := adbFail("unknown request " + request)
+		for _, h := range handlers {
+			if strings.HasPrefix(request, h.prefix) {
+				raw, _ = h.fn(request)
+				break
+			}
+		}
+		conn.Write([]byte(raw))
+		return
+	}
+}
+
+// adbString encodes s with its hex length.
+func adbString(s string) string {
+	return fmt.Sprintf("%04x%s", len(s), s)
+}
+
+// adbFail encodes a FAIL status with msg.
+func adbFail(msg string) string {
+	return "FAIL" + adbString(msg)
+}
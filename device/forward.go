@@ -0,0 +1,197 @@
+package device
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Forward is a port forward between the host and the device. Local is
+// always the host side and Remote the device side, as in the adb CLI.
+type Forward struct {
+	Local   string `json:"local"`   // Host socket, e.g. tcp:8080
+	Remote  string `json:"remote"`  // Device socket, e.g. localabstract:chrome_devtools_remote
+	Reverse bool   `json:"reverse"` // Device connections to Remote reach Local
+}
+
+// forwardKinds lists the socket kinds accepted on each side of a forward.
+var forwardKinds = map[string][]string{
+	"local":  {"tcp", "localabstract", "localreserved", "localfilesystem"},
+	"remote": {"tcp", "localabstract", "localreserved", "localfilesystem", "jdwp"},
+}
+
+// Forward forwards connections to the host socket local to the device
+// socket remote, e.g. tcp:9222 to localabstract:chrome_devtools_remote.
+// Use tcp:0 to pick a free host port. The forward is removed when the
+// device is closed. It returns the host socket.
+func (d *AndroidDevice) Forward(local, remote string) (string, error) {
+	local, err := normalizeSocket(local, forwardKinds["local"])
+	if err != nil {
+		return "", err
+	}
+	if remote, err = normalizeSocket(remote, forwardKinds["remote"]); err != nil {
+		return "", err
+	}
+
+	c, err := d.adbHost(fmt.Sprintf("host-serial:%s:forward:%s;%s", d.id, local, remote))
+	if err != nil {
+		return "", fmt.Errorf("forward: %w", err)
+	}
+	defer c.Close()
+
+	// The host sends a second status once the listener is installed
+	if err := c.status(); err != nil {
+		return "", fmt.Errorf("forward: %w", err)
+	}
+	if local == "tcp:0" {
+		port, err := c.readString()
+		if err != nil {
+			return "", fmt.Errorf("forward: %w", err)
+		}
+		local = "tcp:" + port
+	}
+
+	d.onCloseOnce("remove forward "+local, func(d *AndroidDevice) error {
+		return ignoreMissingForward(d.RemoveForward(local, false))
+	})
+
+	return local, nil
+}
+
+// Reverse forwards connections to the device socket remote to the host
+// socket local, e.g. tcp:8081 to tcp:8081 for a development server. Use
+// tcp:0 to pick a free device port. The reverse forward is removed when the
+// device is closed. It returns the device socket.
+func (d *AndroidDevice) Reverse(remote, local string) (string, error) {
+	remote, err := normalizeSocket(remote, forwardKinds["local"])
+	if err != nil {
+		return "", err
+	}
+	if local, err = normalizeSocket(local, forwardKinds["local"]); err != nil {
+		return "", err
+	}
+
+	c, err := d.adbService(fmt.Sprintf("reverse:forward:%s;%s", remote, local))
+	if err != nil {
+		return "", fmt.Errorf("reverse: %w", err)
+	}
+	defer c.Close()
+
+	if err := c.status(); err != nil {
+		return "", fmt.Errorf("reverse: %w", err)
+	}
+	if remote == "tcp:0" {
+		port, err := c.readString()
+		if err != nil {
+			return "", fmt.Errorf("reverse: %w", err)
+		}
+		remote = "tcp:" + port
+	}
+
+	d.onCloseOnce("remove reverse "+remote, func(d *AndroidDevice) error {
+		return ignoreMissingForward(d.RemoveForward(remote, true))
+	})
+
+	return remote, nil
+}
+
+// ListForwards returns the forwards and reverse forwards of the device.
+func (d *AndroidDevice) ListForwards() ([]Forward, error) {
+	c, err := d.adbHost("host:list-forward")
+	if err != nil {
+		return nil, fmt.Errorf("list forwards: %w", err)
+	}
+	out, err := c.readString()
+	c.Close()
+	if err != nil {
+		return nil, fmt.Errorf("list forwards: %w", err)
+	}
+
+	// e.g. "emulator-5554 tcp:9222 localabstract:chrome_devtools_remote"
+	forwards := []Forward{}
+	for _, line := range strings.Split(out, "\n") {
+		if f := strings.Fields(line); len(f) == 3 && f[0] == d.id {
+			forwards = append(forwards, Forward{Local: f[1], Remote: f[2]})
+		}
+	}
+
+	if c, err = d.adbService("reverse:list-forward"); err != nil {
+		return nil, fmt.Errorf("list reverse forwards: %w", err)
+	}
+	out, err = c.readString()
+	c.Close()
+	if err != nil {
+		return nil, fmt.Errorf("list reverse forwards: %w", err)
+	}
+
+	// e.g. "host-19 tcp:8081 tcp:8081", the device socket comes first
+	for _, line := range strings.Split(out, "\n") {
+		if f := strings.Fields(line); len(f) == 3 {
+			forwards = append(forwards, Forward{Local: f[2], Remote: f[1], Reverse: true})
+		}
+	}
+
+	return forwards, nil
+}
+
+// RemoveForward removes the forward listening on the host socket local, or
+// the reverse forward listening on the device socket when reverse is set.
+func (d *AndroidDevice) RemoveForward(socket string, reverse bool) error {
+	socket, err := normalizeSocket(socket, forwardKinds["local"])
+	if err != nil {
+		return err
+	}
+
+	var c *adbConn
+	if reverse {
+		c, err = d.adbService("reverse:killforward:" + socket)
+	} else {
+		c, err = d.adbHost(fmt.Sprintf("host-serial:%s:killforward:%s", d.id, socket))
+	}
+	if err != nil {
+		return fmt.Errorf("remove forward: %w", err)
+	}
+	defer c.Close()
+
+	if err := c.status(); err != nil {
+		return fmt.Errorf("remove forward: %w", err)
+	}
+
+	return nil
+}
+
+// normalizeSocket validates an ADB socket spec. A bare port is a tcp socket.
+func normalizeSocket(spec string, kinds []string) (string, error) {
+	spec = strings.TrimSpace(spec)
+	if _, err := strconv.Atoi(spec); err == nil {
+		spec = "tcp:" + spec
+	}
+
+	kind, name, ok := strings.Cut(spec, ":")
+	if !ok || name == "" || strings.ContainsAny(spec, "; \t\n") {
+		return "", fmt.Errorf("invalid socket %q, expected e.g. tcp:8080", spec)
+	}
+
+	for _, k := range kinds {
+		if k != kind {
+			continue
+		}
+		if kind == "tcp" || kind == "jdwp" {
+			if port, err := strconv.Atoi(name); err != nil || port < 0 || port > 65535 {
+				return "", fmt.Errorf("invalid socket %q", spec)
+			}
+		}
+		return spec, nil
+	}
+
+	return "", fmt.Errorf("invalid socket %q, expected one of %s", spec, strings.Join(kinds, ", "))
+}
+
+// ignoreMissingForward ignores the error of removing a forward that was
+// already removed.
+func ignoreMissingForward(err error) error {
+	if err != nil && strings.Contains(err.Error(), "not found") {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,66 @@
+package device_test
+
+import (
+	"mcp-android-adb-server/device"
+	"reflect"
+	"testing"
+)
+
+// TestForwards tests creating, listing and removing forwards
+func TestForwards(t *testing.T) {
+	adb := newFakeAdbServer(t)
+	adb.reply("host-serial:fake:forward:tcp:0;", "OKAYOKAY"+adbString("41234"))
+	adb.reply("host-serial:fake:forward:", "OKAYOKAY")
+	adb.reply("host-serial:fake:killforward:tcp:9222", "OKAY"+adbFail("listener 'tcp:9222' not found"))
+	adb.reply("host-serial:fake:killforward:", "OKAYOKAY")
+	adb.reply("reverse:forward:", "OKAYOKAY")
+	adb.reply("reverse:killforward:", "OKAYOKAY")
+	adb.reply("host:list-forward", "OKAY"+adbString("fake tcp:9222 localabstract:chrome_devtools_remote\nother tcp:1 tcp:1\n"))
+	adb.reply("reverse:list-forward", "OKAY"+adbString("host-19 tcp:8081 tcp:3000\n"))
+
+	d := device.NewTestDevice("fake", newFakeTransport(), device.WithAdbAddress(adb.addr()))
+
+	local, err := d.Forward("9222", "localabstract:chrome_devtools_remote")
+	if err != nil || local != "tcp:9222" {
+		t.Fatalf("Failed to forward: %q %v", local, err)
+	}
+	if local, err = d.Forward("tcp:0", "jdwp:1234"); err != nil || local != "tcp:41234" {
+		t.Fatalf("Failed to forward to a free port: %q %v", local, err)
+	}
+	if remote, err := d.Reverse("tcp:8081", "tcp:3000"); err != nil || remote != "tcp:8081" {
+		t.Fatalf("Failed to reverse: %q %v", remote, err)
+	}
+
+	forwards, err := d.ListForwards()
+	if err != nil {
+		t.Fatalf("Failed to list forwards: %v", err)
+	}
+	expected := []device.Forward{
+		{Local: "tcp:9222", Remote: "localabstract:chrome_devtools_remote"},
+		{Local: "tcp:3000", Remote: "tcp:8081", Reverse: true},
+	}
+	if !reflect.DeepEqual(forwards, expected) {
+		t.Errorf("Forwards do not match expected: expected %+v, got %+v", expected, forwards)
+	}
+
+	if _, err := d.Forward("jdwp:1", "tcp:1"); err == nil {
+		t.Error("Should reject jdwp on the host side")
+	}
+
+	// The forward to tcp:9222 is already gone, closing should not fail
+	if err := d.Close(); err != nil {
+		t.Fatalf("Failed to close device: %v", err)
+	}
+
+	history := adb.history()
+	removed := history[len(history)-4:]
+	expectedRemoved := []string{
+		"host:transport:fake",
+		"reverse:killforward:tcp:8081",
+		"host-serial:fake:killforward:tcp:41234",
+		"host-serial:fake:killforward:tcp:9222",
+	}
+	if !reflect.DeepEqual(removed, expectedRemoved) {
+		t.Errorf("Cleanup requests do not match expected:\nexpected %q\ngot      %q", expectedRemoved, removed)
+	}
+}
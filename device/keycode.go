@@ -22,4 +22,5 @@ const (
 	KeycodeMoveEnd        = 123 // 移动到行尾的按键
 	KeycodeMediaPlay      = 126 // 媒体播放键
 	KeycodeMediaPause     = 127 // 媒体暂停键
+	KeycodePaste          = 279 // 粘贴键
 )
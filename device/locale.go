@@ -0,0 +1,278 @@
+package device
+
+import (
+	"fmt"
+	"mcp-android-adb-server/shellquote"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// e.g. "Locales for com.example.app for user 0 are [ja-JP,en-US]"
+	appLocalesRegexp = regexp.MustCompile(`are \[(.*)\]`)
+
+	// e.g. "en-US", "zh-Hans-CN", "ja"
+	languageTagRegexp = regexp.MustCompile(`^[a-zA-Z]{2,8}(-[a-zA-Z0-9]{1,8})*$`)
+
+	// e.g. "Asia/Shanghai", "UTC", "America/Argentina/Buenos_Aires"
+	timeZoneRegexp = regexp.MustCompile(`^[A-Za-z0-9_+\-]+(/[A-Za-z0-9_+\-]+)*$`)
+)
+
+// dateTimeTolerance is how far the device clock may drift from the requested time.
+const dateTimeTolerance = 5 * time.Second
+
+// ChangeResult reports whether a change of device configuration took effect.
+type ChangeResult struct {
+	Requested string `json:"requested"`      // Requested value
+	Actual    string `json:"actual"`         // Value read back from the device
+	Applied   bool   `json:"applied"`        // Whether the actual value matches the request
+	Method    string `json:"method"`         // Mechanism used to apply the change
+	Note      string `json:"note,omitempty"` // Additional information, e.g. why the change failed
+}
+
+// Locale returns the current system locale as a language tag.
+func (d *AndroidDevice) Locale() (string, error) {
+	for _, prop := range []string{"persist.sys.locale", "ro.product.locale"} {
+		value, err := d.getprop(prop)
+		if err != nil {
+			return "", err
+		}
+		if value != "" {
+			return value, nil
+		}
+	}
+
+	return "", fmt.Errorf("failed to read system locale")
+}
+
+// SetLocale changes the system locale. This requires root (e.g. an emulator
+// after `adb root`); the framework must be restarted for running apps to pick
+// up the new locale, which happens when restart is true.
+func (d *AndroidDevice) SetLocale(tag string, restart bool) (*ChangeResult, error) {
+	if !languageTagRegexp.MatchString(tag) {
+		return nil, fmt.Errorf("invalid language tag %q", tag)
+	}
+
+	// The property only holds the saved locale, the running configuration
+	// changes once the framework restarts
+	restarted := false
+	result := &ChangeResult{Requested: tag, Method: "setprop persist.sys.locale"}
+	if out, err := d.runAsRoot(shellquote.Command("setprop persist.sys.locale", tag)); err != nil {
+		result.Note = fmt.Sprintf("changing the system locale requires root, use per-app locales instead: %v", err)
+	} else if out = strings.TrimSpace(out); out != "" {
+		result.Note = out
+	} else if restart {
+		if _, err := d.runAsRoot("setprop ctl.restart zygote"); err != nil {
+			result.Note = fmt.Sprintf("failed to restart the framework: %v", err)
+		} else {
+			restarted = true
+			result.Method += ", framework restart"
+		}
+	} else {
+		result.Note = "the new locale is saved and applies after the framework restarts, call again with restart: true"
+	}
+
+	actual, err := d.Locale()
+	if err != nil {
+		return nil, err
+	}
+
+	result.Actual = actual
+	result.Applied = restarted && strings.EqualFold(actual, tag)
+	return result, nil
+}
+
+// AppLocales returns the per-app locales of a package (Android 13+).
+func (d *AndroidDevice) AppLocales(packageName string) (string, error) {
+	out, err := d.shell("cmd locale get-app-locales", packageName, "--user", "current")
+	if err != nil {
+		return "", fmt.Errorf("get app locales: %w", err)
+	}
+
+	match := appLocalesRegexp.FindStringSubmatch(out)
+	if len(match) != 2 {
+		return "", fmt.Errorf("get app locales: %s", strings.TrimSpace(out))
+	}
+
+	return match[1], nil
+}
+
+// SetAppLocale changes the locale of a single app (Android 13+). An empty tag
+// resets the app to follow the system locale.
+func (d *AndroidDevice) SetAppLocale(packageName, tag string) (*ChangeResult, error) {
+	if tag != "" && !languageTagRegexp.MatchString(tag) {
+		return nil, fmt.Errorf("invalid language tag %q", tag)
+	}
+
+	result := &ChangeResult{Requested: tag, Method: "cmd locale set-app-locales"}
+	out, err := d.shell("cmd locale set-app-locales", packageName, "--user", "current", "--locales", tag)
+	if err != nil {
+		return nil, fmt.Errorf("set app locales: %w", err)
+	}
+	result.Note = strings.TrimSpace(out)
+
+	actual, err := d.AppLocales(packageName)
+	if err != nil {
+		result.Note = err.Error()
+		return result, nil
+	}
+
+	result.Actual = actual
+	result.Applied = strings.EqualFold(actual, tag)
+	return result, nil
+}
+
+// TimeZone returns the current time zone ID of the device.
+func (d *AndroidDevice) TimeZone() (string, error) {
+	return d.getprop("persist.sys.timezone")
+}
+
+// SetTimeZone disables automatic time zone detection and changes the time zone.
+func (d *AndroidDevice) SetTimeZone(zone string) (*ChangeResult, error) {
+	if !timeZoneRegexp.MatchString(zone) {
+		return nil, fmt.Errorf("invalid time zone %q", zone)
+	}
+
+	original, err := d.TimeZone()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.PutSetting(SettingsGlobal, "auto_time_zone", "0"); err != nil {
+		return nil, err
+	}
+
+	if original != "" {
+		d.onCloseOnce("restore time zone", func(d *AndroidDevice) error {
+			_, err := d.applyTimeZone(original)
+			return err
+		})
+	}
+
+	method, err := d.applyTimeZone(zone)
+	result := &ChangeResult{Requested: zone, Method: method}
+	if err != nil {
+		result.Note = err.Error()
+	}
+
+	if result.Actual, err = d.TimeZone(); err != nil {
+		return nil, err
+	}
+
+	result.Applied = result.Actual == zone
+	return result, nil
+}
+
+// applyTimeZone tries each known mechanism for changing the time zone in turn
+// and returns the one that took effect.
+func (d *AndroidDevice) applyTimeZone(zone string) (string, error) {
+	methods := []struct {
+		name string
+		cmd  string
+		root bool
+	}{
+		// Android 12+
+		{"cmd time_zone_detector", shellquote.Command("cmd time_zone_detector suggest_manual_time_zone --zone_id", zone), false},
+		{"setprop persist.sys.timezone", shellquote.Command("setprop persist.sys.timezone", zone), true},
+	}
+
+	var errs []string
+	for _, m := range methods {
+		var err error
+		if m.root {
+			_, err = d.runAsRoot(m.cmd)
+		} else {
+			_, err = d.RunShellCommand(m.cmd)
+		}
+
+		if err == nil {
+			if current, _ := d.TimeZone(); current == zone {
+				return m.name, nil
+			}
+			err = fmt.Errorf("time zone unchanged")
+		}
+		errs = append(errs, fmt.Sprintf("%s: %v", m.name, err))
+	}
+
+	return "", fmt.Errorf("set time zone: %s", strings.Join(errs, "; "))
+}
+
+// SetAutoTime enables or disables network provided date and time.
+func (d *AndroidDevice) SetAutoTime(enabled bool) error {
+	value := "0"
+	if enabled {
+		value = "1"
+	}
+
+	return d.PutSetting(SettingsGlobal, "auto_time", value)
+}
+
+// DateTime returns the current time of the device clock.
+func (d *AndroidDevice) DateTime() (time.Time, error) {
+	out, err := d.RunShellCommand("date +%s")
+	if err != nil {
+		return time.Time{}, fmt.Errorf("get date: %w", err)
+	}
+
+	seconds, err := strconv.ParseInt(strings.TrimSpace(out), 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse date: %s", strings.TrimSpace(out))
+	}
+
+	return time.Unix(seconds, 0), nil
+}
+
+// SetDateTime disables automatic time and sets the device clock. This
+// requires root, which is available on emulators and rooted devices.
+// Automatic time is re-enabled when the device is closed, which resyncs the clock.
+func (d *AndroidDevice) SetDateTime(t time.Time) (*ChangeResult, error) {
+	if err := d.SetAutoTime(false); err != nil {
+		return nil, err
+	}
+
+	result := &ChangeResult{Requested: t.UTC().Format(time.RFC3339), Method: "date"}
+
+	// toybox date: MMDDhhmm[[CC]YY][.ss], interpreted in UTC with -u
+	if out, err := d.runAsRoot(shellquote.Command("date -u", t.UTC().Format("010215042006.05"))); err != nil {
+		result.Note = fmt.Sprintf("setting the date requires root: %v", err)
+	} else if strings.Contains(out, "date:") {
+		result.Note = strings.TrimSpace(out)
+	}
+
+	actual, err := d.DateTime()
+	if err != nil {
+		return nil, err
+	}
+
+	result.Actual = actual.UTC().Format(time.RFC3339)
+	result.Applied = actual.Sub(t).Abs() <= dateTimeTolerance
+	return result, nil
+}
+
+// runAsRoot runs a shell command as root, either directly when adbd runs as
+// root or through su.
+func (d *AndroidDevice) runAsRoot(cmd string) (string, error) {
+	uid, err := d.RunShellCommand("id -u")
+	if err != nil {
+		return "", err
+	}
+
+	if strings.TrimSpace(uid) == "0" {
+		return d.RunShellCommand(cmd)
+	}
+
+	out, err := d.shell("su 0 sh -c", cmd)
+	if err != nil {
+		return "", err
+	}
+
+	// su is missing or denied
+	if strings.Contains(out, "su: not found") || strings.Contains(out, "Permission denied") ||
+		strings.Contains(out, "su: invalid uid") {
+		return "", fmt.Errorf("root is not available: %s", strings.TrimSpace(out))
+	}
+
+	return out, nil
+}
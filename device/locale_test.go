@@ -0,0 +1,112 @@
+package device_test
+
+import (
+	"mcp-android-adb-server/device"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSetTimeZoneFallback tests falling back to the root property when the time zone is unchanged
+func TestSetTimeZoneFallback(t *testing.T) {
+	zone := "Asia/Shanghai"
+	fake := newFakeTransport()
+	fake.reply("settings get global auto_time_zone", "1\n")
+	fake.reply("id -u", "0\n")
+	fake.handle("getprop persist.sys.timezone", func(string) (string, error) {
+		for _, cmd := range fake.history() {
+			if strings.HasPrefix(cmd, "setprop persist.sys.timezone "+zone) {
+				return zone + "\n", nil
+			}
+		}
+		return "UTC\n", nil
+	})
+	d := device.NewTestDevice("fake", fake)
+
+	result, err := d.SetTimeZone(zone)
+	if err != nil {
+		t.Fatalf("Failed to set time zone: %v", err)
+	}
+
+	if !result.Applied || result.Actual != zone || result.Method != "setprop persist.sys.timezone" {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	if _, err := d.SetTimeZone("Asia/Shanghai; reboot"); err == nil {
+		t.Error("Should return error for invalid time zone")
+	}
+}
+
+// TestSetDateTimeWithoutRoot tests reporting a date change that did not take effect
+func TestSetDateTimeWithoutRoot(t *testing.T) {
+	fake := newFakeTransport()
+	fake.reply("settings get global auto_time", "1\n")
+	fake.reply("id -u", "2000\n")
+	fake.reply("su ", "/system/bin/sh: su: not found\n")
+	fake.reply("date +%s", "1700000000\n")
+	d := device.NewTestDevice("fake", fake)
+
+	result, err := d.SetDateTime(time.Date(2024, 1, 31, 9, 30, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Failed to set date and time: %v", err)
+	}
+
+	if result.Applied {
+		t.Errorf("Date change should not be applied without root: %+v", result)
+	}
+	if result.Actual != "2023-11-14T22:13:20Z" {
+		t.Errorf("Unexpected actual date: %s", result.Actual)
+	}
+}
+
+// TestSetDateTimeAsRoot tests setting the device clock when adbd runs as root
+func TestSetDateTimeAsRoot(t *testing.T) {
+	target := time.Date(2024, 1, 31, 9, 30, 5, 0, time.UTC)
+	fake := newFakeTransport()
+	fake.reply("settings get global auto_time", "1\n")
+	fake.reply("id -u", "0\n")
+	fake.reply("date -u ", "Wed Jan 31 09:30:05 GMT 2024\n")
+	fake.reply("date +%s", "1706693405\n")
+	d := device.NewTestDevice("fake", fake)
+
+	result, err := d.SetDateTime(target)
+	if err != nil {
+		t.Fatalf("Failed to set date and time: %v", err)
+	}
+
+	if !result.Applied {
+		t.Errorf("Date change should be applied: %+v", result)
+	}
+
+	found := false
+	for _, cmd := range fake.history() {
+		found = found || cmd == "date -u 013109302024.05"
+	}
+	if !found {
+		t.Errorf("Date command not run: %q", fake.history())
+	}
+}
+
+// TestSetLocale tests that a saved locale is only applied after a framework restart
+func TestSetLocale(t *testing.T) {
+	fake := newFakeTransport()
+	fake.reply("id -u", "0\n")
+	fake.reply("getprop persist.sys.locale", "ja-JP\n")
+	d := device.NewTestDevice("fake", fake)
+
+	result, err := d.SetLocale("ja-JP", false)
+	if err != nil {
+		t.Fatalf("Failed to set locale: %v", err)
+	}
+	if result.Applied || result.Actual != "ja-JP" || !strings.Contains(result.Note, "restart") {
+		t.Errorf("Locale should not be applied without a restart: %+v", result)
+	}
+
+	result, err = d.SetLocale("ja-JP", true)
+	if err != nil {
+		t.Fatalf("Failed to set locale: %v", err)
+	}
+	if !result.Applied || !strings.Contains(result.Method, "restart") {
+		t.Errorf("Locale should be applied after a restart: %+v", result)
+	}
+}
@@ -0,0 +1,105 @@
+package device
+
+import (
+	"fmt"
+	"mcp-android-adb-server/emulator"
+	"mcp-android-adb-server/shellquote"
+	"regexp"
+	"strconv"
+)
+
+// MockLocationPackage is the package of the helper app that provides mock
+// locations on physical devices, see https://github.com/appium/io.appium.settings
+var MockLocationPackage = "io.appium.settings"
+
+// mockLocationOp is the app op allowing an app to act as mock location provider.
+const mockLocationOp = "android:mock_location"
+
+// e.g. "Uid mode: android:mock_location: allow" or "android:mock_location: ignore; time=..."
+var mockLocationModeRegexp = regexp.MustCompile(`android:mock_location: (\w+)`)
+
+// SetLocation sets the location reported by the device. Emulators are set
+// through the console, physical devices through the mock location helper.
+func (d *AndroidDevice) SetLocation(latitude, longitude, altitude float64) error {
+	if latitude < -90 || latitude > 90 || longitude < -180 || longitude > 180 {
+		return fmt.Errorf("invalid coordinates %g,%g", latitude, longitude)
+	}
+
+	if emulator.IsEmulator(d.id) {
+		c, err := emulator.DialSerial(d.id)
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+
+		return c.GeoFix(latitude, longitude, altitude)
+	}
+
+	if err := d.enableMockLocation(); err != nil {
+		return err
+	}
+
+	start := "am startservice"
+	if sdk, err := d.SDK(); err == nil && sdk >= 26 {
+		start = "am start-foreground-service"
+	}
+
+	err := d.runChecked(shellquote.Command(start, "-n", MockLocationPackage+"/.LocationService",
+		"--es", "latitude", formatCoordinate(latitude),
+		"--es", "longitude", formatCoordinate(longitude),
+		"--es", "altitude", formatCoordinate(altitude)))
+	if err != nil {
+		return fmt.Errorf("set location: %w", err)
+	}
+
+	return nil
+}
+
+// enableMockLocation allows the helper app to provide mock locations and
+// registers the restore of the original app op mode.
+func (d *AndroidDevice) enableMockLocation() error {
+	d.mu.Lock()
+	for _, c := range d.cleanups {
+		if c.name == "stop mock location" {
+			d.mu.Unlock()
+			return nil
+		}
+	}
+	d.mu.Unlock()
+
+	installed, err := d.InstalledApp(MockLocationPackage)
+	if err != nil {
+		return fmt.Errorf("mock location helper: %w", err)
+	}
+	if !installed {
+		return fmt.Errorf("mock location requires the %s helper app on physical devices", MockLocationPackage)
+	}
+
+	out, err := d.shell("appops get", MockLocationPackage, mockLocationOp)
+	if err != nil {
+		return fmt.Errorf("get mock location mode: %w", err)
+	}
+
+	original := "default"
+	if match := mockLocationModeRegexp.FindStringSubmatch(out); match != nil {
+		original = match[1]
+	}
+
+	if err := d.runChecked(shellquote.Command("appops set", MockLocationPackage, mockLocationOp, "allow")); err != nil {
+		return fmt.Errorf("allow mock location: %w", err)
+	}
+
+	d.onCloseOnce("stop mock location", func(d *AndroidDevice) error {
+		if _, err := d.shell("am stopservice -n", MockLocationPackage+"/.LocationService"); err != nil {
+			return err
+		}
+		return d.runChecked(shellquote.Command("appops set", MockLocationPackage, mockLocationOp, original))
+	})
+
+	return nil
+}
+
+// formatCoordinate formats a coordinate without trailing zeros.
+func formatCoordinate(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
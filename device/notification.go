@@ -0,0 +1,265 @@
+package device
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// Fields of a NotificationRecord header in `dumpsys notification`
+	notificationPkgRegexp     = regexp.MustCompile(`pkg=(\S+)`)
+	notificationIDRegexp      = regexp.MustCompile(` id=(-?\d+)`)
+	notificationTagRegexp     = regexp.MustCompile(` tag=(\S+)`)
+	notificationKeyRegexp     = regexp.MustCompile(`key=(\S+?):? Notification\(`)
+	notificationChannelRegexp = regexp.MustCompile(`channel=(\S+)`)
+
+	// e.g. "android.title=String (Your code)"
+	notificationExtraRegexp = regexp.MustCompile(`^android\.(title|text|subText|bigText)=\w+ \((.*)\)$`)
+
+	// e.g. "mCreationTimeMs=1700000000000"
+	notificationTimeRegexp = regexp.MustCompile(`(?:mCreationTimeMs|postTime)=(\d+)`)
+
+	// e.g. `[0] "Reply" -> PendingIntent{...}`
+	notificationActionRegexp = regexp.MustCompile(`^\[\d+\] "(.*)" ->`)
+
+	// Labels of the "Clear all" button in the notification shade
+	clearAllRegexp = regexp.MustCompile(`(?i)^(clear all|dismiss all|全部清除|清除所有|清除全部)`)
+)
+
+// Notification represents a notification posted on the device.
+type Notification struct {
+	Key      string    `json:"key"`       // Unique notification key
+	Package  string    `json:"package"`   // Package that posted the notification
+	ID       int       `json:"id"`        // Notification ID
+	Tag      string    `json:"tag"`       // Notification tag
+	Channel  string    `json:"channel"`   // Notification channel
+	Title    string    `json:"title"`     // android.title extra
+	Text     string    `json:"text"`      // android.text extra
+	SubText  string    `json:"sub_text"`  // android.subText extra
+	BigText  string    `json:"big_text"`  // android.bigText extra
+	PostTime time.Time `json:"post_time"` // Time the notification was posted
+	Actions  []string  `json:"actions"`   // Action button labels
+}
+
+// ListNotifications lists the active notifications, optionally only those
+// posted by packageName.
+func (d *AndroidDevice) ListNotifications(packageName string) ([]Notification, error) {
+	out, err := d.RunShellCommand("dumpsys notification --noredact")
+	if err != nil {
+		return nil, fmt.Errorf("dumpsys notification: %w", err)
+	}
+
+	notifications := parseNotifications(out)
+	if packageName == "" {
+		return notifications, nil
+	}
+
+	filtered := notifications[:0]
+	for _, n := range notifications {
+		if n.Package == packageName {
+			filtered = append(filtered, n)
+		}
+	}
+
+	return filtered, nil
+}
+
+// OpenNotificationShade expands the notification shade.
+func (d *AndroidDevice) OpenNotificationShade() error {
+	return d.runFirst([]string{"cmd statusbar expand-notifications", "service call statusbar 1"})
+}
+
+// CloseNotificationShade collapses the notification shade.
+func (d *AndroidDevice) CloseNotificationShade() error {
+	return d.runFirst([]string{"cmd statusbar collapse", "service call statusbar 2"})
+}
+
+// ClearNotifications dismisses all clearable notifications by tapping
+// "Clear all" in the notification shade.
+func (d *AndroidDevice) ClearNotifications() error {
+	nodes, err := d.shadeNodes()
+	if err != nil {
+		return err
+	}
+
+	buttons := FindUINodes(nodes, func(n UINode) bool {
+		return strings.HasSuffix(n.ResourceID, ":id/clear_all") ||
+			strings.HasSuffix(n.ResourceID, ":id/dismiss_text") ||
+			strings.HasSuffix(n.ResourceID, ":id/clear_all_button") ||
+			clearAllRegexp.MatchString(n.Text) || clearAllRegexp.MatchString(n.ContentDesc)
+	})
+	if len(buttons) == 0 {
+		_ = d.CloseNotificationShade()
+		return fmt.Errorf("no clear all button found, there may be no dismissible notifications")
+	}
+
+	return d.tapNode(buttons[0])
+}
+
+// TapNotification opens the first notification posted by packageName whose
+// title or text contains match. Either filter may be empty.
+func (d *AndroidDevice) TapNotification(packageName, match string) (*Notification, error) {
+	notifications, err := d.ListNotifications(packageName)
+	if err != nil {
+		return nil, err
+	}
+
+	var target *Notification
+	for i, n := range notifications {
+		if match == "" || containsFold(n.Title, match) || containsFold(n.Text, match) {
+			target = &notifications[i]
+			break
+		}
+	}
+	if target == nil {
+		return nil, fmt.Errorf("no notification found for package %q matching %q", packageName, match)
+	}
+
+	nodes, err := d.shadeNodes()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, label := range []string{target.Title, target.Text} {
+		if label == "" {
+			continue
+		}
+
+		found := FindUINodes(nodes, func(n UINode) bool { return n.Text == label })
+		if len(found) != 0 {
+			return target, d.tapNode(found[0])
+		}
+	}
+
+	_ = d.CloseNotificationShade()
+	return nil, fmt.Errorf("notification %q is not visible in the notification shade", target.Key)
+}
+
+// shadeNodes expands the notification shade and dumps its UI hierarchy.
+func (d *AndroidDevice) shadeNodes() ([]UINode, error) {
+	if err := d.OpenNotificationShade(); err != nil {
+		return nil, err
+	}
+	d.Sleep()
+
+	return d.DumpUI()
+}
+
+// tapNode taps the center of a UI node.
+func (d *AndroidDevice) tapNode(n UINode) error {
+	x, y, err := n.Center()
+	if err != nil {
+		return err
+	}
+
+	return d.Tap(x, y)
+}
+
+// containsFold reports whether substr is within s, ignoring case.
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// parseNotifications parses the NotificationRecord blocks of `dumpsys notification`.
+// Records listed more than once, e.g. while enqueued, are reported once.
+func parseNotifications(out string) []Notification {
+	var notifications []Notification
+	seen := make(map[string]bool)
+
+	lines := strings.Split(out, "\n")
+	for i := 0; i < len(lines); i++ {
+		header := lines[i]
+		if !strings.Contains(header, "NotificationRecord(") {
+			continue
+		}
+
+		n := parseNotificationHeader(header)
+		indent := len(header) - len(strings.TrimLeft(header, " "))
+		inActions := false
+
+		for ; i+1 < len(lines); i++ {
+			line := strings.TrimRight(lines[i+1], "\r")
+			trimmed := strings.TrimSpace(line)
+			if trimmed == "" {
+				continue
+			}
+			if strings.Contains(line, "NotificationRecord(") || len(line)-len(strings.TrimLeft(line, " ")) <= indent {
+				break
+			}
+
+			switch {
+			case strings.HasPrefix(trimmed, "actions={"):
+				inActions = true
+			case inActions && trimmed == "}":
+				inActions = false
+			case inActions:
+				if m := notificationActionRegexp.FindStringSubmatch(trimmed); m != nil {
+					n.Actions = append(n.Actions, m[1])
+				}
+			default:
+				parseNotificationField(&n, trimmed)
+			}
+		}
+
+		if n.Key == "" || seen[n.Key] {
+			continue
+		}
+		seen[n.Key] = true
+		notifications = append(notifications, n)
+	}
+
+	return notifications
+}
+
+// parseNotificationHeader parses the first line of a NotificationRecord.
+func parseNotificationHeader(header string) Notification {
+	n := Notification{Actions: []string{}}
+
+	if m := notificationPkgRegexp.FindStringSubmatch(header); m != nil {
+		n.Package = m[1]
+	}
+	if m := notificationIDRegexp.FindStringSubmatch(header); m != nil {
+		n.ID, _ = strconv.Atoi(m[1])
+	}
+	if m := notificationTagRegexp.FindStringSubmatch(header); m != nil && m[1] != "null" {
+		n.Tag = m[1]
+	}
+	if m := notificationKeyRegexp.FindStringSubmatch(header); m != nil {
+		n.Key = m[1]
+	}
+	if m := notificationChannelRegexp.FindStringSubmatch(header); m != nil {
+		n.Channel = m[1]
+	}
+
+	return n
+}
+
+// parseNotificationField parses a single line of a NotificationRecord body.
+func parseNotificationField(n *Notification, line string) {
+	if m := notificationExtraRegexp.FindStringSubmatch(line); m != nil {
+		switch m[1] {
+		case "title":
+			n.Title = m[2]
+		case "text":
+			n.Text = m[2]
+		case "subText":
+			n.SubText = m[2]
+		case "bigText":
+			n.BigText = m[2]
+		}
+		return
+	}
+
+	if strings.HasPrefix(line, "key=") && n.Key == "" {
+		n.Key = strings.TrimPrefix(line, "key=")
+		return
+	}
+
+	if m := notificationTimeRegexp.FindStringSubmatch(line); m != nil && n.PostTime.IsZero() {
+		ms, _ := strconv.ParseInt(m[1], 10, 64)
+		n.PostTime = time.UnixMilli(ms)
+	}
+}
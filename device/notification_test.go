@@ -0,0 +1,112 @@
+package device_test
+
+import (
+	"mcp-android-adb-server/device"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const dumpsysNotification = `Current Notification Manager state:
+  Notification List:
+    NotificationRecord(0x0a1b2c3d: pkg=com.example.bank user=UserHandle{0} id=7 tag=null importance=4 key=0|com.example.bank|7|null|10123: Notification(channel=otp shortcut=null contentView=null vibrate=null sound=null defaults=0x0 flags=0x10 color=0x00000000 actions=2 vis=PRIVATE))
+      uid=10123 userId=0
+      opPkg=com.example.bank
+      key=0|com.example.bank|7|null|10123
+      notification=
+        pri=1 contentView=null
+        actions={
+          [0] "Copy code" -> PendingIntent{5e1f0a2: PendingIntentRecord{8c7d6e5 com.example.bank broadcastIntent}}
+          [1] "Dismiss" -> PendingIntent{1a2b3c4: PendingIntentRecord{9d8e7f6 com.example.bank broadcastIntent}}
+        }
+        extras={
+          android.title=String (Verification code)
+          android.text=String (123456 is your login code)
+          android.subText=null
+        }
+      mCreationTimeMs=1700000000000
+    NotificationRecord(0x0b2c3d4e: pkg=com.android.systemui user=UserHandle{0} id=1 tag=usb key=0|com.android.systemui|1|usb|10050: Notification(channel=USB shortcut=null contentView=null vibrate=null sound=null defaults=0x0 flags=0x2 color=0x00000000 vis=PUBLIC))
+      extras={
+        android.title=String (Charging this device via USB)
+      }
+      mCreationTimeMs=1700000001000
+  Enqueued Notification List:
+    NotificationRecord(0x0a1b2c3d: pkg=com.example.bank user=UserHandle{0} id=7 tag=null importance=4 key=0|com.example.bank|7|null|10123: Notification(channel=otp))
+`
+
+const shadeDump = `<?xml version='1.0' encoding='UTF-8' standalone='yes' ?><hierarchy rotation="0"><node index="0" text="" resource-id="" class="android.widget.FrameLayout" package="com.android.systemui" content-desc="" clickable="false" bounds="[0,0][1080,2400]"><node index="0" text="Verification code" resource-id="android:id/title" class="android.widget.TextView" package="com.android.systemui" content-desc="" clickable="false" bounds="[100,400][700,460]" /><node index="1" text="Clear all" resource-id="com.android.systemui:id/dismiss_text" class="android.widget.Button" package="com.android.systemui" content-desc="" clickable="true" bounds="[800,1200][1040,1300]" /></node></hierarchy>`
+
+// TestListNotifications tests parsing notification records
+func TestListNotifications(t *testing.T) {
+	fake := newFakeTransport()
+	fake.reply("dumpsys notification", dumpsysNotification)
+	d := device.NewTestDevice("fake", fake)
+
+	notifications, err := d.ListNotifications("")
+	if err != nil {
+		t.Fatalf("Failed to list notifications: %v", err)
+	}
+	if len(notifications) != 2 {
+		t.Fatalf("Expected 2 notifications, got %d: %+v", len(notifications), notifications)
+	}
+
+	expected := device.Notification{
+		Key:      "0|com.example.bank|7|null|10123",
+		Package:  "com.example.bank",
+		ID:       7,
+		Channel:  "otp",
+		Title:    "Verification code",
+		Text:     "123456 is your login code",
+		PostTime: time.UnixMilli(1700000000000),
+		Actions:  []string{"Copy code", "Dismiss"},
+	}
+	if !reflect.DeepEqual(notifications[0], expected) {
+		t.Errorf("Notification does not match expected:\nexpected %+v\ngot      %+v", expected, notifications[0])
+	}
+
+	if notifications[1].Tag != "usb" || notifications[1].Title != "Charging this device via USB" {
+		t.Errorf("Unexpected second notification: %+v", notifications[1])
+	}
+
+	filtered, err := d.ListNotifications("com.android.systemui")
+	if err != nil {
+		t.Fatalf("Failed to list notifications: %v", err)
+	}
+	if len(filtered) != 1 || filtered[0].Package != "com.android.systemui" {
+		t.Errorf("Unexpected filtered notifications: %+v", filtered)
+	}
+}
+
+// TestTapNotification tests tapping a notification matched by its text
+func TestTapNotification(t *testing.T) {
+	fake := newFakeTransport()
+	fake.reply("dumpsys notification", dumpsysNotification)
+	fake.reply("uiautomator dump", "UI hierchary dumped to: /data/local/tmp/window_dump.xml\n")
+	fake.reply("cat /data/local/tmp/window_dump.xml", shadeDump)
+	d := device.NewTestDevice("fake", fake, device.WithSleepDuration(0))
+
+	n, err := d.TapNotification("com.example.bank", "login code")
+	if err != nil {
+		t.Fatalf("Failed to tap notification: %v", err)
+	}
+	if n.ID != 7 {
+		t.Errorf("Tapped wrong notification: %+v", n)
+	}
+
+	history := fake.history()
+	if last := history[len(history)-1]; last != "input tap 400 430" {
+		t.Errorf("Expected tap on notification title, got %q", last)
+	}
+
+	if err := d.ClearNotifications(); err != nil {
+		t.Fatalf("Failed to clear notifications: %v", err)
+	}
+	history = fake.history()
+	if last := history[len(history)-1]; last != "input tap 920 1250" {
+		t.Errorf("Expected tap on clear all, got %q", last)
+	}
+
+	if _, err := d.TapNotification("com.example.missing", ""); err == nil {
+		t.Error("Should return error when no notification matches")
+	}
+}
@@ -0,0 +1,164 @@
+package device
+
+import (
+	"context"
+	"sync"
+)
+
+// OpClass is the class of an operation with respect to other operations on
+// the same device.
+type OpClass int
+
+const (
+	// OpRead operations only read device state and run in parallel.
+	OpRead OpClass = iota
+	// OpMutate operations change device state and run alone.
+	OpMutate
+)
+
+func (c OpClass) String() string {
+	if c == OpRead {
+		return "read"
+	}
+	return "mutate"
+}
+
+// OperationStatus describes the operations running and queued on a device.
+type OperationStatus struct {
+	Running        int    `json:"running"`         // Operations holding the device
+	RunningClass   string `json:"running_class"`   // read or mutate, empty when idle
+	Queued         int    `json:"queued"`          // Operations waiting for the device
+	QueuedMutating int    `json:"queued_mutating"` // Mutating operations among the queued ones
+}
+
+// opLock is a fair readers-writer lock. Waiters are granted in arrival
+// order, so a queued mutating operation holds back later reads.
+type opLock struct {
+	mu      sync.Mutex
+	readers int
+	writer  bool
+	queue   []*opWaiter
+}
+
+// opWaiter is an operation waiting for the device.
+type opWaiter struct {
+	class   OpClass
+	ready   chan struct{}
+	granted bool
+}
+
+// Acquire waits until an operation of the given class may run on the device
+// and returns the function that ends it. It fails if ctx is done first.
+func (d *AndroidDevice) Acquire(ctx context.Context, class OpClass) (func(), error) {
+	l := &d.ops
+
+	l.mu.Lock()
+	if len(l.queue) == 0 && l.compatible(class) {
+		l.grant(class)
+		l.mu.Unlock()
+		return l.releaser(class), nil
+	}
+
+	w := &opWaiter{class: class, ready: make(chan struct{})}
+	l.queue = append(l.queue, w)
+	l.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return l.releaser(class), nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if w.granted {
+			// Granted while giving up, hand the device to the next waiter
+			l.release(class)
+		} else {
+			l.remove(w)
+			// A waiting mutation may have held back reads behind it
+			l.promote()
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// OperationStatus returns the operations running and queued on the device.
+func (d *AndroidDevice) OperationStatus() OperationStatus {
+	l := &d.ops
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	status := OperationStatus{Queued: len(l.queue)}
+	switch {
+	case l.writer:
+		status.Running, status.RunningClass = 1, OpMutate.String()
+	case l.readers > 0:
+		status.Running, status.RunningClass = l.readers, OpRead.String()
+	}
+	for _, w := range l.queue {
+		if w.class == OpMutate {
+			status.QueuedMutating++
+		}
+	}
+
+	return status
+}
+
+// compatible reports whether an operation of class can run now.
+func (l *opLock) compatible(class OpClass) bool {
+	if class == OpRead {
+		return !l.writer
+	}
+	return !l.writer && l.readers == 0
+}
+
+func (l *opLock) grant(class OpClass) {
+	if class == OpRead {
+		l.readers++
+	} else {
+		l.writer = true
+	}
+}
+
+// releaser returns a function releasing class once.
+func (l *opLock) releaser(class OpClass) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+
+			l.release(class)
+		})
+	}
+}
+
+func (l *opLock) release(class OpClass) {
+	if class == OpRead {
+		l.readers--
+	} else {
+		l.writer = false
+	}
+	l.promote()
+}
+
+// promote grants the waiters at the front of the queue that can run.
+func (l *opLock) promote() {
+	for len(l.queue) > 0 && l.compatible(l.queue[0].class) {
+		w := l.queue[0]
+		l.queue = l.queue[1:]
+		l.grant(w.class)
+		w.granted = true
+		close(w.ready)
+	}
+}
+
+func (l *opLock) remove(w *opWaiter) {
+	for i, q := range l.queue {
+		if q == w {
+			l.queue = append(l.queue[:i], l.queue[i+1:]...)
+			return
+		}
+	}
+}
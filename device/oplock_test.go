@@ -0,0 +1,181 @@
+package device_test
+
+import (
+	"context"
+	"errors"
+	"mcp-android-adb-server/device"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestAcquireExclusive tests that mutating operations never overlap
+// each other or reads on the fake transport
+func TestAcquireExclusive(t *testing.T) {
+	var running, mutating, overlaps atomic.Int32
+
+	fake := newFakeTransport()
+	fake.handle("input tap", func(string) (string, error) {
+		if mutating.Add(1) > 1 || running.Load() > 0 {
+			overlaps.Add(1)
+		}
+		time.Sleep(time.Millisecond)
+		mutating.Add(-1)
+		return "", nil
+	})
+	fake.handle("wm size", func(string) (string, error) {
+		running.Add(1)
+		if mutating.Load() > 0 {
+			overlaps.Add(1)
+		}
+		time.Sleep(time.Millisecond)
+		running.Add(-1)
+		return "Physical size: 1080x1920", nil
+	})
+	d := device.NewTestDevice("fake", fake)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+
+			release, err := d.Acquire(context.Background(), device.OpMutate)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer release()
+
+			if err := d.Tap(1, 1); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+
+			release, err := d.WithContext(context.Background()).Acquire(context.Background(), device.OpRead)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer release()
+
+			d.RunShellCommand("wm size")
+		}()
+	}
+	wg.Wait()
+
+	if n := overlaps.Load(); n > 0 {
+		t.Errorf("Expected no overlapping mutations, got %d", n)
+	}
+	if status := d.OperationStatus(); status != (device.OperationStatus{}) {
+		t.Errorf("Expected an idle device, got %+v", status)
+	}
+}
+
+// TestAcquireParallelReads tests that reads run together
+func TestAcquireParallelReads(t *testing.T) {
+	d := device.NewTestDevice("fake", newFakeTransport())
+
+	first, err := d.Acquire(context.Background(), device.OpRead)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := d.Acquire(context.Background(), device.OpRead)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status := d.OperationStatus()
+	if status.Running != 2 || status.RunningClass != "read" || status.Queued != 0 {
+		t.Errorf("Expected two running reads, got %+v", status)
+	}
+
+	first()
+	second()
+	second()
+	if status := d.OperationStatus(); status.Running != 0 {
+		t.Errorf("Expected releasing twice to be harmless, got %+v", status)
+	}
+}
+
+// TestAcquireOrder tests that a queued mutation holds back later reads
+// and that queue depth is reported
+func TestAcquireOrder(t *testing.T) {
+	d := device.NewTestDevice("fake", newFakeTransport())
+
+	read, err := d.Acquire(context.Background(), device.OpRead)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	order := make(chan string, 2)
+	acquire := func(name string, class device.OpClass) {
+		release, err := d.Acquire(context.Background(), class)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		order <- name
+		release()
+	}
+
+	go acquire("mutate", device.OpMutate)
+	waitQueued(t, d, 1)
+	go acquire("read", device.OpRead)
+	waitQueued(t, d, 2)
+
+	status := d.OperationStatus()
+	if status.Running != 1 || status.QueuedMutating != 1 {
+		t.Errorf("Expected one running read and one queued mutation, got %+v", status)
+	}
+
+	read()
+	if first, second := <-order, <-order; first != "mutate" || second != "read" {
+		t.Errorf("Expected the mutation before the later read, got %s then %s", first, second)
+	}
+}
+
+// TestAcquireCancel tests giving up while queued
+func TestAcquireCancel(t *testing.T) {
+	d := device.NewTestDevice("fake", newFakeTransport())
+
+	read, err := d.Acquire(context.Background(), device.OpRead)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := d.Acquire(ctx, device.OpMutate); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected deadline exceeded, got %v", err)
+	}
+
+	// The abandoned mutation no longer holds back reads
+	second, err := d.Acquire(context.Background(), device.OpRead)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := d.OperationStatus(); status.Running != 2 || status.Queued != 0 {
+		t.Errorf("Expected two running reads and an empty queue, got %+v", status)
+	}
+
+	read()
+	second()
+}
+
+// waitQueued waits until n operations are queued on the device
+func waitQueued(t *testing.T, d *device.AndroidDevice, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for d.OperationStatus().Queued != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d queued operations, got %+v", n, d.OperationStatus())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
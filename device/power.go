@@ -0,0 +1,343 @@
+package device
+
+import (
+	"fmt"
+	"mcp-android-adb-server/shellquote"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// Fields of `dumpsys battery`
+	batteryLevelRegexp   = regexp.MustCompile(`(?m)^\s*level: (\d+)`)
+	batteryStatusRegexp  = regexp.MustCompile(`(?m)^\s*status: (\d+)`)
+	batteryPluggedRegexp = regexp.MustCompile(`(?m)^\s*(AC|USB|Wireless) powered: true`)
+
+	// e.g. "Idle=true"
+	inactiveRegexp = regexp.MustCompile(`Idle=(true|false)`)
+)
+
+// Charging sources accepted by SetBattery.
+const (
+	PowerSourceNone     = "none"
+	PowerSourceAC       = "ac"
+	PowerSourceUSB      = "usb"
+	PowerSourceWireless = "wireless"
+)
+
+// batteryStatuses maps BatteryManager status codes to their names.
+var batteryStatuses = map[int]string{
+	1: "unknown",
+	2: "charging",
+	3: "discharging",
+	4: "not_charging",
+	5: "full",
+}
+
+// standbyBuckets maps app standby bucket names to their values.
+var standbyBuckets = map[string]int{
+	"active":      10,
+	"working_set": 20,
+	"frequent":    30,
+	"rare":        40,
+	"restricted":  45,
+}
+
+// BatteryState describes the (possibly simulated) battery of the device.
+type BatteryState struct {
+	Level  *int   // Battery level 0-100, nil to keep
+	Source string // Charging source: none, ac, usb or wireless, empty to keep
+	Status string // Battery status, e.g. charging or discharging, empty to keep
+}
+
+// PowerStatus represents the power state of the device.
+type PowerStatus struct {
+	BatteryLevel  int    `json:"battery_level"`  // Battery percentage
+	BatteryStatus string `json:"battery_status"` // e.g. charging, discharging, full
+	PowerSource   string `json:"power_source"`   // none, ac, usb or wireless
+	DeepIdle      string `json:"deep_idle"`      // Deep doze state, e.g. ACTIVE or IDLE
+	LightIdle     string `json:"light_idle"`     // Light doze state, e.g. ACTIVE or IDLE
+}
+
+// PowerStatus returns the battery and doze state of the device.
+func (d *AndroidDevice) PowerStatus() (*PowerStatus, error) {
+	out, err := d.RunShellCommand("dumpsys battery")
+	if err != nil {
+		return nil, fmt.Errorf("dumpsys battery: %w", err)
+	}
+
+	status := &PowerStatus{BatteryStatus: batteryStatuses[1], PowerSource: PowerSourceNone}
+	if match := batteryLevelRegexp.FindStringSubmatch(out); match != nil {
+		status.BatteryLevel, _ = strconv.Atoi(match[1])
+	}
+	if match := batteryStatusRegexp.FindStringSubmatch(out); match != nil {
+		code, _ := strconv.Atoi(match[1])
+		if name, ok := batteryStatuses[code]; ok {
+			status.BatteryStatus = name
+		}
+	}
+	if match := batteryPluggedRegexp.FindStringSubmatch(out); match != nil {
+		status.PowerSource = strings.ToLower(match[1])
+	}
+
+	if status.DeepIdle, err = d.deviceIdle("get", "deep"); err != nil {
+		return nil, err
+	}
+	if status.LightIdle, err = d.deviceIdle("get", "light"); err != nil {
+		return nil, err
+	}
+
+	return status, nil
+}
+
+// SetBattery simulates the battery level, charging source and status. The
+// real battery state is restored when the device is closed.
+func (d *AndroidDevice) SetBattery(state BatteryState) error {
+	var cmds []string
+
+	if state.Level != nil {
+		if *state.Level < 0 || *state.Level > 100 {
+			return fmt.Errorf("invalid battery level %d", *state.Level)
+		}
+		cmds = append(cmds, shellquote.Command("dumpsys battery set level", strconv.Itoa(*state.Level)))
+	}
+
+	switch state.Source {
+	case "":
+	case PowerSourceNone:
+		cmds = append(cmds, "dumpsys battery unplug")
+	case PowerSourceAC, PowerSourceUSB, PowerSourceWireless:
+		for _, source := range []string{PowerSourceAC, PowerSourceUSB, PowerSourceWireless} {
+			value := "0"
+			if source == state.Source {
+				value = "1"
+			}
+			cmds = append(cmds, shellquote.Command("dumpsys battery set", source, value))
+		}
+	default:
+		return fmt.Errorf("invalid power source %q, expected none, ac, usb or wireless", state.Source)
+	}
+
+	if state.Status != "" {
+		code := 0
+		for c, name := range batteryStatuses {
+			if name == state.Status {
+				code = c
+			}
+		}
+		if code == 0 {
+			return fmt.Errorf("invalid battery status %q", state.Status)
+		}
+		cmds = append(cmds, shellquote.Command("dumpsys battery set status", strconv.Itoa(code)))
+	}
+
+	d.onCloseOnce("reset battery", (*AndroidDevice).ResetBattery)
+
+	for _, cmd := range cmds {
+		if err := d.runChecked(cmd); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ResetBattery stops simulating the battery and reports the real state again.
+func (d *AndroidDevice) ResetBattery() error {
+	return d.runChecked("dumpsys battery reset")
+}
+
+// SetDoze forces the device into deep doze, or leaves it. Forcing doze
+// unplugs the simulated battery since the device does not idle while charging.
+// Leaving doze reverts only what forcing it changed.
+func (d *AndroidDevice) SetDoze(enabled bool) error {
+	if !enabled {
+		if err := d.runChecked("dumpsys deviceidle unforce"); err != nil {
+			return err
+		}
+
+		d.mu.Lock()
+		changes := d.doze
+		d.doze = nil
+		d.mu.Unlock()
+
+		if changes == nil {
+			return nil
+		}
+		for _, mode := range changes.disabled {
+			if err := d.runChecked(shellquote.Command("dumpsys deviceidle disable", mode)); err != nil {
+				return err
+			}
+		}
+		if changes.source == "" {
+			return d.ResetBattery()
+		}
+		return d.SetBattery(BatteryState{Source: changes.source})
+	}
+
+	d.onCloseOnce("leave doze", func(d *AndroidDevice) error {
+		return d.SetDoze(false)
+	})
+
+	if err := d.recordDoze(); err != nil {
+		return err
+	}
+
+	if err := d.SetBattery(BatteryState{Source: PowerSourceNone}); err != nil {
+		return err
+	}
+	if err := d.runChecked("dumpsys deviceidle enable"); err != nil {
+		return err
+	}
+
+	// Output format: "Now forced in to deep idle mode"
+	out, err := d.RunShellCommand("dumpsys deviceidle force-idle")
+	if err != nil {
+		return fmt.Errorf("force idle: %w", err)
+	}
+	if !strings.Contains(out, "idle mode") {
+		return fmt.Errorf("force idle: %s", strings.TrimSpace(out))
+	}
+
+	return nil
+}
+
+// dozeChanges records what forcing doze changed, so leaving it restores
+// only that.
+type dozeChanges struct {
+	source   string   // Charging source of a battery already simulated, empty if the battery was real
+	disabled []string // Doze modes that were disabled, e.g. deep, and are disabled again
+}
+
+// recordDoze records the state changed by forcing doze, unless doze is
+// already forced.
+func (d *AndroidDevice) recordDoze() error {
+	d.mu.Lock()
+	forced := d.doze != nil
+	d.mu.Unlock()
+	if forced {
+		return nil
+	}
+
+	changes := &dozeChanges{}
+
+	// SetBattery registers the reset of a battery it simulates
+	if d.hasCleanup("reset battery") {
+		out, err := d.RunShellCommand("dumpsys battery")
+		if err != nil {
+			return fmt.Errorf("dumpsys battery: %w", err)
+		}
+		changes.source = PowerSourceNone
+		if match := batteryPluggedRegexp.FindStringSubmatch(out); match != nil {
+			changes.source = strings.ToLower(match[1])
+		}
+	}
+
+	// Output format: "1" or "0"
+	for _, mode := range []string{"deep", "light"} {
+		out, err := d.deviceIdle("enabled", mode)
+		if err != nil {
+			return err
+		}
+		if out == "0" {
+			changes.disabled = append(changes.disabled, mode)
+		}
+	}
+
+	d.mu.Lock()
+	d.doze = changes
+	d.mu.Unlock()
+	return nil
+}
+
+// AppInactive reports whether App Standby considers an app inactive.
+func (d *AndroidDevice) AppInactive(packageName string) (bool, error) {
+	out, err := d.shell("am get-inactive", packageName)
+	if err != nil {
+		return false, fmt.Errorf("get inactive: %w", err)
+	}
+
+	match := inactiveRegexp.FindStringSubmatch(out)
+	if match == nil {
+		return false, fmt.Errorf("get inactive: %s", strings.TrimSpace(out))
+	}
+
+	return match[1] == "true", nil
+}
+
+// SetAppInactive marks an app as inactive or active for App Standby. The
+// original state is restored when the device is closed.
+func (d *AndroidDevice) SetAppInactive(packageName string, inactive bool) error {
+	original, err := d.AppInactive(packageName)
+	if err != nil {
+		return err
+	}
+
+	d.onCloseOnce("restore inactive "+packageName, func(d *AndroidDevice) error {
+		return d.setAppInactive(packageName, original)
+	})
+
+	return d.setAppInactive(packageName, inactive)
+}
+
+// StandbyBucket returns the App Standby bucket of an app, e.g. rare.
+func (d *AndroidDevice) StandbyBucket(packageName string) (string, error) {
+	out, err := d.shell("am get-standby-bucket", packageName)
+	if err != nil {
+		return "", fmt.Errorf("get standby bucket: %w", err)
+	}
+
+	value, err := strconv.Atoi(strings.TrimSpace(out))
+	if err != nil {
+		return "", fmt.Errorf("get standby bucket: %s", strings.TrimSpace(out))
+	}
+
+	for name, bucket := range standbyBuckets {
+		if bucket == value {
+			return name, nil
+		}
+	}
+
+	return strconv.Itoa(value), nil
+}
+
+// SetStandbyBucket moves an app to an App Standby bucket: active,
+// working_set, frequent, rare or restricted. The original bucket is restored
+// when the device is closed.
+func (d *AndroidDevice) SetStandbyBucket(packageName, bucket string) error {
+	if _, ok := standbyBuckets[bucket]; !ok {
+		return fmt.Errorf("invalid standby bucket %q, expected active, working_set, frequent, rare or restricted", bucket)
+	}
+
+	original, err := d.StandbyBucket(packageName)
+	if err != nil {
+		return err
+	}
+
+	d.onCloseOnce("restore standby bucket "+packageName, func(d *AndroidDevice) error {
+		return d.setStandbyBucket(packageName, original)
+	})
+
+	return d.setStandbyBucket(packageName, bucket)
+}
+
+// setAppInactive runs `am set-inactive`.
+func (d *AndroidDevice) setAppInactive(packageName string, inactive bool) error {
+	return d.runChecked(shellquote.Command("am set-inactive", packageName, strconv.FormatBool(inactive)))
+}
+
+// setStandbyBucket runs `am set-standby-bucket`.
+func (d *AndroidDevice) setStandbyBucket(packageName, bucket string) error {
+	return d.runChecked(shellquote.Command("am set-standby-bucket", packageName, bucket))
+}
+
+// deviceIdle runs a `dumpsys deviceidle` query and returns its trimmed output.
+func (d *AndroidDevice) deviceIdle(query ...string) (string, error) {
+	out, err := d.shell("dumpsys deviceidle", query...)
+	if err != nil {
+		return "", fmt.Errorf("dumpsys deviceidle: %w", err)
+	}
+
+	return strings.TrimSpace(out), nil
+}
@@ -0,0 +1,169 @@
+package device_test
+
+import (
+	"mcp-android-adb-server/device"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const dumpsysBattery = `Current Battery Service state:
+  (UPDATES STOPPED -- use 'reset' to restart)
+  AC powered: false
+  USB powered: true
+  Wireless powered: false
+  Max charging current: 500000
+  status: 2
+  health: 2
+  present: true
+  level: 15
+  scale: 100
+`
+
+// TestPowerStatus tests parsing the battery and doze state
+func TestPowerStatus(t *testing.T) {
+	fake := newFakeTransport()
+	fake.reply("dumpsys battery", dumpsysBattery)
+	fake.reply("dumpsys deviceidle get deep", "IDLE\n")
+	fake.reply("dumpsys deviceidle get light", "OVERRIDE\n")
+	d := device.NewTestDevice("fake", fake)
+
+	status, err := d.PowerStatus()
+	if err != nil {
+		t.Fatalf("Failed to get power status: %v", err)
+	}
+
+	expected := &device.PowerStatus{
+		BatteryLevel:  15,
+		BatteryStatus: "charging",
+		PowerSource:   device.PowerSourceUSB,
+		DeepIdle:      "IDLE",
+		LightIdle:     "OVERRIDE",
+	}
+	if !reflect.DeepEqual(status, expected) {
+		t.Errorf("Power status does not match expected: expected %+v, got %+v", expected, status)
+	}
+}
+
+// TestSetBatteryReset tests simulating the battery and resetting it on close
+func TestSetBatteryReset(t *testing.T) {
+	fake := newFakeTransport()
+	d := device.NewTestDevice("fake", fake)
+
+	level := 5
+	if err := d.SetBattery(device.BatteryState{Level: &level, Source: device.PowerSourceAC, Status: "charging"}); err != nil {
+		t.Fatalf("Failed to set battery: %v", err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatalf("Failed to close device: %v", err)
+	}
+
+	expected := []string{
+		"dumpsys battery set level 5",
+		"dumpsys battery set ac 1",
+		"dumpsys battery set usb 0",
+		"dumpsys battery set wireless 0",
+		"dumpsys battery set status 2",
+		"dumpsys battery reset",
+	}
+	if history := fake.history(); !reflect.DeepEqual(history, expected) {
+		t.Errorf("Commands do not match expected:\nexpected %q\ngot      %q", expected, history)
+	}
+
+	level = 101
+	if err := d.SetBattery(device.BatteryState{Level: &level}); err == nil {
+		t.Error("Should return error for invalid battery level")
+	}
+}
+
+// TestSetDozeRestore tests that leaving doze reverts only what forcing it changed
+func TestSetDozeRestore(t *testing.T) {
+	tests := []struct {
+		name      string
+		simulated bool   // The battery is simulated before forcing doze
+		deep      string // Output of `dumpsys deviceidle enabled deep`
+		expected  []string
+	}{
+		{"real battery", false, "1", []string{
+			"dumpsys deviceidle unforce",
+			"dumpsys battery reset",
+		}},
+		{"simulated battery", true, "0", []string{
+			"dumpsys deviceidle unforce",
+			"dumpsys deviceidle disable deep",
+			"dumpsys battery set ac 0",
+			"dumpsys battery set usb 1",
+			"dumpsys battery set wireless 0",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeTransport()
+			fake.reply("dumpsys deviceidle enabled deep", tt.deep+"\n")
+			fake.reply("dumpsys deviceidle enabled light", "1\n")
+			fake.reply("dumpsys deviceidle force-idle", "Now forced in to deep idle mode\n")
+			fake.reply("dumpsys battery", dumpsysBattery)
+			d := device.NewTestDevice("fake", fake)
+
+			if tt.simulated {
+				if err := d.SetBattery(device.BatteryState{Source: device.PowerSourceUSB}); err != nil {
+					t.Fatalf("Failed to set battery: %v", err)
+				}
+			}
+			if err := d.SetDoze(true); err != nil {
+				t.Fatalf("Failed to force doze: %v", err)
+			}
+			forced := len(fake.history())
+			if err := d.SetDoze(false); err != nil {
+				t.Fatalf("Failed to leave doze: %v", err)
+			}
+
+			if history := fake.history()[forced:]; !reflect.DeepEqual(history, tt.expected) {
+				t.Errorf("Commands do not match expected:\nexpected %q\ngot      %q", tt.expected, history)
+			}
+		})
+	}
+}
+
+// TestSetStandbyBucketRestore tests restoring the original standby bucket on close
+func TestSetStandbyBucketRestore(t *testing.T) {
+	fake := newFakeTransport()
+	fake.reply("am get-standby-bucket com.example.app", "10\n")
+	d := device.NewTestDevice("fake", fake)
+
+	if err := d.SetStandbyBucket("com.example.app", "rare"); err != nil {
+		t.Fatalf("Failed to set standby bucket: %v", err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatalf("Failed to close device: %v", err)
+	}
+
+	expected := []string{
+		"am get-standby-bucket com.example.app",
+		"am set-standby-bucket com.example.app rare",
+		"am set-standby-bucket com.example.app active",
+	}
+	if history := fake.history(); !reflect.DeepEqual(history, expected) {
+		t.Errorf("Commands do not match expected:\nexpected %q\ngot      %q", expected, history)
+	}
+}
+
+// TestSetStandbyBucketFailure tests that failures are detected by exit code
+// rather than by the words in the output
+func TestSetStandbyBucketFailure(t *testing.T) {
+	fake := newFakeTransport()
+	fake.reply("am get-standby-bucket", "10\n")
+	fake.handle("am set-standby-bucket com.example.missing", func(string) (string, error) {
+		return "", &fakeExit{code: 255, stderr: "Unknown package: com.example.missing\n"}
+	})
+	fake.reply("am set-standby-bucket com.example.Errorless", "No Error here\n")
+	d := device.NewTestDevice("fake", fake)
+
+	if err := d.SetStandbyBucket("com.example.missing", "rare"); err == nil || !strings.Contains(err.Error(), "Unknown package") {
+		t.Errorf("Expected the stderr of the failed command, got %v", err)
+	}
+	if err := d.SetStandbyBucket("com.example.Errorless", "rare"); err != nil {
+		t.Errorf("Expected success with exit status 0, got %v", err)
+	}
+}
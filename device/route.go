@@ -0,0 +1,301 @@
+package device
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// earthRadius is the mean radius of the earth in meters.
+const earthRadius = 6371000.0
+
+// Waypoint is a point of a route.
+type Waypoint struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Altitude  float64 `json:"altitude"`
+}
+
+// RouteInfo describes a route being played.
+type RouteInfo struct {
+	Waypoints int           `json:"waypoints"`       // Points in the route
+	Steps     int           `json:"steps"`           // Location updates sent to the device
+	Distance  float64       `json:"distance_meters"` // Length of the route
+	Duration  time.Duration `json:"duration"`        // Time needed to play the route
+}
+
+// routePlayer is a route being played in the background.
+type routePlayer struct {
+	stop chan struct{}
+	done chan struct{}
+	err  error
+}
+
+// ParseRoute parses the track, route or way points of a GPX file, or the
+// coordinates of a KML file.
+func ParseRoute(data []byte) ([]Waypoint, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+
+	var (
+		points []Waypoint
+		root   string
+		text   strings.Builder
+		inText bool
+	)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse route: %w", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if root == "" {
+				root = t.Name.Local
+			}
+			switch t.Name.Local {
+			case "trkpt", "rtept", "wpt":
+				p, err := parseGPXPoint(t)
+				if err != nil {
+					return nil, err
+				}
+				points = append(points, p)
+			case "ele", "coordinates", "coord":
+				text.Reset()
+				inText = true
+			}
+		case xml.CharData:
+			if inText {
+				text.Write(t)
+			}
+		case xml.EndElement:
+			if !inText {
+				continue
+			}
+			inText = false
+
+			switch t.Name.Local {
+			case "ele":
+				// Elevation of the last GPX point
+				if len(points) > 0 {
+					points[len(points)-1].Altitude, _ = strconv.ParseFloat(strings.TrimSpace(text.String()), 64)
+				}
+			case "coordinates":
+				// KML: "lon,lat[,alt]" tuples separated by whitespace
+				for _, tuple := range strings.Fields(text.String()) {
+					p, err := parseKMLCoordinate(strings.Split(tuple, ","))
+					if err != nil {
+						return nil, err
+					}
+					points = append(points, p)
+				}
+			case "coord":
+				// KML gx:Track: "lon lat alt"
+				p, err := parseKMLCoordinate(strings.Fields(text.String()))
+				if err != nil {
+					return nil, err
+				}
+				points = append(points, p)
+			}
+		}
+	}
+
+	if root != "gpx" && root != "kml" {
+		return nil, fmt.Errorf("parse route: expected a GPX or KML document, got <%s>", root)
+	}
+	if len(points) == 0 {
+		return nil, fmt.Errorf("parse route: no points found")
+	}
+
+	return points, nil
+}
+
+// PlayRoute moves the device location along points at speed meters per
+// second, sending a location update every interval. The route plays in the
+// background until it ends, StopRoute is called or the device is closed.
+func (d *AndroidDevice) PlayRoute(points []Waypoint, speed float64, interval time.Duration) (*RouteInfo, error) {
+	if len(points) == 0 {
+		return nil, fmt.Errorf("route has no points")
+	}
+	if speed <= 0 || interval <= 0 {
+		return nil, fmt.Errorf("speed and interval must be positive")
+	}
+
+	steps := interpolateRoute(points, speed*interval.Seconds())
+	info := &RouteInfo{
+		Waypoints: len(points),
+		Steps:     len(steps),
+		Distance:  routeDistance(points),
+		Duration:  time.Duration(len(steps)-1) * interval,
+	}
+
+	// Fail early, e.g. when the mock location helper is missing
+	if err := d.SetLocation(steps[0].Latitude, steps[0].Longitude, steps[0].Altitude); err != nil {
+		return nil, err
+	}
+
+	d.StopRoute()
+
+	player := &routePlayer{stop: make(chan struct{}), done: make(chan struct{})}
+	d.mu.Lock()
+	d.route = player
+	d.mu.Unlock()
+	d.onCloseOnce("stop route", func(d *AndroidDevice) error {
+		d.StopRoute()
+		return nil
+	})
+
+	// The route outlives the call that started it
+	d = d.WithContext(context.Background())
+
+	go func() {
+		defer close(player.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for _, p := range steps[1:] {
+			select {
+			case <-player.stop:
+				return
+			case <-ticker.C:
+			}
+
+			if err := d.SetLocation(p.Latitude, p.Longitude, p.Altitude); err != nil {
+				player.err = err
+				return
+			}
+		}
+	}()
+
+	return info, nil
+}
+
+// StopRoute stops the route being played and returns the error that ended
+// it early, if any.
+func (d *AndroidDevice) StopRoute() error {
+	d.mu.Lock()
+	player := d.route
+	d.route = nil
+	d.mu.Unlock()
+
+	if player == nil {
+		return nil
+	}
+
+	select {
+	case <-player.done:
+	default:
+		close(player.stop)
+		<-player.done
+	}
+
+	return player.err
+}
+
+// parseGPXPoint parses the lat and lon attributes of a GPX point.
+func parseGPXPoint(t xml.StartElement) (Waypoint, error) {
+	var (
+		p        Waypoint
+		lat, lon bool
+		err      error
+	)
+	for _, attr := range t.Attr {
+		switch attr.Name.Local {
+		case "lat":
+			p.Latitude, err = strconv.ParseFloat(attr.Value, 64)
+			lat = err == nil
+		case "lon":
+			p.Longitude, err = strconv.ParseFloat(attr.Value, 64)
+			lon = err == nil
+		}
+	}
+
+	if !lat || !lon {
+		return p, fmt.Errorf("parse route: invalid <%s> point", t.Name.Local)
+	}
+
+	return p, nil
+}
+
+// parseKMLCoordinate parses a longitude, latitude and optional altitude.
+func parseKMLCoordinate(fields []string) (Waypoint, error) {
+	if len(fields) < 2 {
+		return Waypoint{}, fmt.Errorf("parse route: invalid coordinate %q", strings.Join(fields, ","))
+	}
+
+	values := make([]float64, len(fields))
+	for i, f := range fields {
+		v, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return Waypoint{}, fmt.Errorf("parse route: invalid coordinate %q", strings.Join(fields, ","))
+		}
+		values[i] = v
+	}
+
+	p := Waypoint{Longitude: values[0], Latitude: values[1]}
+	if len(values) > 2 {
+		p.Altitude = values[2]
+	}
+
+	return p, nil
+}
+
+// interpolateRoute returns the points along the route spaced step meters apart.
+func interpolateRoute(points []Waypoint, step float64) []Waypoint {
+	steps := []Waypoint{points[0]}
+
+	// Distance travelled since the last emitted point
+	travelled := 0.0
+	for i := 1; i < len(points); i++ {
+		from, to := points[i-1], points[i]
+		length := distance(from, to)
+
+		offset := step - travelled
+		for ; offset <= length; offset += step {
+			f := offset / length
+			steps = append(steps, Waypoint{
+				Latitude:  from.Latitude + (to.Latitude-from.Latitude)*f,
+				Longitude: from.Longitude + (to.Longitude-from.Longitude)*f,
+				Altitude:  from.Altitude + (to.Altitude-from.Altitude)*f,
+			})
+		}
+		travelled = step - (offset - length)
+	}
+
+	// Always finish on the last point
+	if last := points[len(points)-1]; steps[len(steps)-1] != last {
+		steps = append(steps, last)
+	}
+
+	return steps
+}
+
+// routeDistance returns the length of the route in meters.
+func routeDistance(points []Waypoint) float64 {
+	total := 0.0
+	for i := 1; i < len(points); i++ {
+		total += distance(points[i-1], points[i])
+	}
+
+	return total
+}
+
+// distance returns the great-circle distance between a and b in meters.
+func distance(a, b Waypoint) float64 {
+	lat1, lat2 := a.Latitude*math.Pi/180, b.Latitude*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (b.Longitude - a.Longitude) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(h))
+}
@@ -0,0 +1,119 @@
+package device_test
+
+import (
+	"mcp-android-adb-server/device"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const gpxRoute = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="test" xmlns="http://www.topografix.com/GPX/1/1">
+  <trk><name>Walk</name><trkseg>
+    <trkpt lat="31.2304" lon="121.4737"><ele>4.5</ele></trkpt>
+    <trkpt lat="31.2314" lon="121.4737"><ele>6</ele></trkpt>
+  </trkseg></trk>
+</gpx>`
+
+const kmlRoute = `<?xml version="1.0" encoding="UTF-8"?>
+<kml xmlns="http://www.opengis.net/kml/2.2" xmlns:gx="http://www.google.com/kml/ext/2.2">
+  <Document><Placemark><LineString><coordinates>
+    121.4737,31.2304,0 121.4737,31.2314
+  </coordinates></LineString></Placemark>
+  <Placemark><gx:Track><gx:coord>121.4747 31.2314 10</gx:coord></gx:Track></Placemark></Document>
+</kml>`
+
+// TestParseRoute tests parsing GPX and KML routes
+func TestParseRoute(t *testing.T) {
+	points, err := device.ParseRoute([]byte(gpxRoute))
+	if err != nil {
+		t.Fatalf("Failed to parse GPX: %v", err)
+	}
+	expected := []device.Waypoint{
+		{Latitude: 31.2304, Longitude: 121.4737, Altitude: 4.5},
+		{Latitude: 31.2314, Longitude: 121.4737, Altitude: 6},
+	}
+	if !reflect.DeepEqual(points, expected) {
+		t.Errorf("GPX points do not match expected: expected %+v, got %+v", expected, points)
+	}
+
+	points, err = device.ParseRoute([]byte(kmlRoute))
+	if err != nil {
+		t.Fatalf("Failed to parse KML: %v", err)
+	}
+	expected = []device.Waypoint{
+		{Latitude: 31.2304, Longitude: 121.4737},
+		{Latitude: 31.2314, Longitude: 121.4737},
+		{Latitude: 31.2314, Longitude: 121.4747, Altitude: 10},
+	}
+	if !reflect.DeepEqual(points, expected) {
+		t.Errorf("KML points do not match expected: expected %+v, got %+v", expected, points)
+	}
+
+	if _, err := device.ParseRoute([]byte(`<html><body/></html>`)); err == nil {
+		t.Error("Should reject documents that are not GPX or KML")
+	}
+}
+
+// TestPlayRoute tests playing a route through the mock location helper
+func TestPlayRoute(t *testing.T) {
+	fake := newFakeTransport()
+	fake.reply("getprop ro.build.version.sdk", "30\n")
+	fake.reply("pm path io.appium.settings", "package:/data/app/io.appium.settings/base.apk\n")
+	fake.reply("appops get io.appium.settings android:mock_location", "No operations.\n")
+	fake.reply("am start-foreground-service", "Starting service: Intent { cmp=io.appium.settings/.LocationService }\n")
+	d := device.NewTestDevice("fake", fake)
+
+	points, _ := device.ParseRoute([]byte(gpxRoute))
+
+	// The route is about 111 meters long, 50 meters per update gives four updates
+	info, err := d.PlayRoute(points, 50000, time.Millisecond)
+	if err != nil {
+		t.Fatalf("Failed to play route: %v", err)
+	}
+	if info.Steps != 4 || info.Distance < 110 || info.Distance > 112 {
+		t.Errorf("Unexpected route info: %+v", info)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for countPrefix(fake.history(), "am start-foreground-service") < info.Steps {
+		if time.Now().After(deadline) {
+			t.Fatalf("Route did not finish: %q", fake.history())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := d.StopRoute(); err != nil {
+		t.Fatalf("Failed to stop route: %v", err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatalf("Failed to close device: %v", err)
+	}
+
+	history := fake.history()
+	last := history[len(history)-3]
+	if !strings.Contains(last, "--es latitude 31.2314 --es longitude 121.4737 --es altitude 6") {
+		t.Errorf("Route should end on the last point, got %q", last)
+	}
+
+	restore := history[len(history)-2:]
+	expected := []string{
+		"am stopservice -n io.appium.settings/.LocationService",
+		"appops set io.appium.settings android:mock_location default",
+	}
+	if !reflect.DeepEqual(restore, expected) {
+		t.Errorf("Restore commands do not match expected:\nexpected %q\ngot      %q", expected, restore)
+	}
+}
+
+// countPrefix counts the commands starting with prefix.
+func countPrefix(commands []string, prefix string) int {
+	n := 0
+	for _, cmd := range commands {
+		if strings.HasPrefix(cmd, prefix) {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,215 @@
+package device
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SettingsNamespace is a namespace of the Android settings provider.
+type SettingsNamespace string
+
+const (
+	SettingsSystem SettingsNamespace = "system"
+	SettingsSecure SettingsNamespace = "secure"
+	SettingsGlobal SettingsNamespace = "global"
+)
+
+// settingNull is printed by `settings get` for keys that are not set.
+const settingNull = "null"
+
+// Setting represents a single key/value pair of the settings provider.
+type Setting struct {
+	Namespace SettingsNamespace `json:"namespace"`
+	Key       string            `json:"key"`
+	Value     string            `json:"value"`
+}
+
+// settingKey identifies a setting in the session snapshot.
+type settingKey struct {
+	namespace SettingsNamespace
+	key       string
+}
+
+// ParseSettingsNamespace validates a settings namespace name.
+func ParseSettingsNamespace(name string) (SettingsNamespace, error) {
+	switch ns := SettingsNamespace(strings.ToLower(strings.TrimSpace(name))); ns {
+	case SettingsSystem, SettingsSecure, SettingsGlobal:
+		return ns, nil
+	}
+
+	return "", fmt.Errorf("invalid settings namespace %q, expected system, secure or global", name)
+}
+
+// GetSetting returns the value of a setting, or "null" if it is not set.
+func (d *AndroidDevice) GetSetting(namespace SettingsNamespace, key string) (string, error) {
+	if key == "" {
+		return "", fmt.Errorf("setting key is empty")
+	}
+
+	out, err := d.shell("settings get", string(namespace), key)
+	if err != nil {
+		return "", fmt.Errorf("get setting: %w", err)
+	}
+
+	return strings.TrimRight(out, "\r\n"), nil
+}
+
+// PutSetting changes the value of a setting. The original value is recorded
+// the first time a setting is changed so that it can be restored later.
+func (d *AndroidDevice) PutSetting(namespace SettingsNamespace, key, value string) error {
+	if err := d.snapshotSetting(namespace, key); err != nil {
+		return err
+	}
+
+	return d.putSetting(namespace, key, value)
+}
+
+// ListSettings lists all settings of a namespace sorted by key.
+func (d *AndroidDevice) ListSettings(namespace SettingsNamespace) ([]Setting, error) {
+	out, err := d.shell("settings list", string(namespace))
+	if err != nil {
+		return nil, fmt.Errorf("list settings: %w", err)
+	}
+
+	settings := parseSettingsList(namespace, out)
+	sort.Slice(settings, func(i, j int) bool {
+		return settings[i].Key < settings[j].Key
+	})
+
+	return settings, nil
+}
+
+// ChangedSettings returns the original values of the settings changed during the session.
+func (d *AndroidDevice) ChangedSettings() []Setting {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	settings := make([]Setting, 0, len(d.settings))
+	for k, v := range d.settings {
+		settings = append(settings, Setting{Namespace: k.namespace, Key: k.key, Value: v})
+	}
+
+	sort.Slice(settings, func(i, j int) bool {
+		if settings[i].Namespace != settings[j].Namespace {
+			return settings[i].Namespace < settings[j].Namespace
+		}
+		return settings[i].Key < settings[j].Key
+	})
+
+	return settings
+}
+
+// RestoreSettings restores every setting changed during the session to its original value.
+func (d *AndroidDevice) RestoreSettings() error {
+	var failed []string
+	for _, s := range d.ChangedSettings() {
+		var err error
+		if s.Value == settingNull {
+			err = d.deleteSetting(s.Namespace, s.Key)
+		} else {
+			err = d.putSetting(s.Namespace, s.Key, s.Value)
+		}
+
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s/%s: %v", s.Namespace, s.Key, err))
+			continue
+		}
+
+		d.mu.Lock()
+		delete(d.settings, settingKey{s.Namespace, s.Key})
+		d.mu.Unlock()
+	}
+
+	if len(failed) != 0 {
+		return fmt.Errorf("restore settings: %s", strings.Join(failed, "; "))
+	}
+
+	return nil
+}
+
+// snapshotSetting records the current value of a setting unless it is already recorded.
+func (d *AndroidDevice) snapshotSetting(namespace SettingsNamespace, key string) error {
+	k := settingKey{namespace, key}
+
+	d.mu.Lock()
+	_, ok := d.settings[k]
+	d.mu.Unlock()
+	if ok {
+		return nil
+	}
+
+	value, err := d.GetSetting(namespace, key)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.settings == nil {
+		d.settings = make(map[settingKey]string)
+	}
+	if _, ok := d.settings[k]; !ok {
+		d.settings[k] = value
+	}
+
+	return nil
+}
+
+// putSetting writes a setting without recording it in the snapshot.
+func (d *AndroidDevice) putSetting(namespace SettingsNamespace, key, value string) error {
+	if key == "" {
+		return fmt.Errorf("setting key is empty")
+	}
+
+	out, err := d.shell("settings put", string(namespace), key, value)
+	if err != nil {
+		return fmt.Errorf("put setting: %w", err)
+	}
+
+	// settings put prints nothing on success
+	if out = strings.TrimSpace(out); out != "" {
+		return fmt.Errorf("put setting: %s", out)
+	}
+
+	return nil
+}
+
+// deleteSetting removes a setting without recording it in the snapshot.
+func (d *AndroidDevice) deleteSetting(namespace SettingsNamespace, key string) error {
+	out, err := d.shell("settings delete", string(namespace), key)
+	if err != nil {
+		return fmt.Errorf("delete setting: %w", err)
+	}
+
+	// Output format: "Deleted 1 rows"
+	if strings.Contains(out, "Exception") || strings.HasPrefix(strings.TrimSpace(out), "Error") {
+		return fmt.Errorf("delete setting: %s", strings.TrimSpace(out))
+	}
+
+	return nil
+}
+
+// parseSettingsList parses the output of `settings list`.
+func parseSettingsList(namespace SettingsNamespace, out string) []Setting {
+	lines := strings.Split(out, "\n")
+	settings := make([]Setting, 0, len(lines))
+
+	for _, line := range lines {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			continue
+		}
+
+		// Format: key=value
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+
+		settings = append(settings, Setting{Namespace: namespace, Key: key, Value: value})
+	}
+
+	return settings
+}
@@ -0,0 +1,96 @@
+package device_test
+
+import (
+	"mcp-android-adb-server/device"
+	"reflect"
+	"testing"
+)
+
+// TestParseSettingsNamespace tests validating settings namespaces
+func TestParseSettingsNamespace(t *testing.T) {
+	for _, name := range []string{"system", "Secure", " global "} {
+		if _, err := device.ParseSettingsNamespace(name); err != nil {
+			t.Errorf("ParseSettingsNamespace(%q) failed: %v", name, err)
+		}
+	}
+
+	if _, err := device.ParseSettingsNamespace("vendor"); err == nil {
+		t.Error("Should return error for unknown namespace")
+	}
+}
+
+// TestListSettings tests parsing the settings list
+func TestListSettings(t *testing.T) {
+	fake := newFakeTransport()
+	fake.reply("settings list system", "screen_off_timeout=60000\nfont_scale=1.0\nname=a=b\n")
+	d := device.NewTestDevice("fake", fake)
+
+	settings, err := d.ListSettings(device.SettingsSystem)
+	if err != nil {
+		t.Fatalf("Failed to list settings: %v", err)
+	}
+
+	expected := []device.Setting{
+		{Namespace: device.SettingsSystem, Key: "font_scale", Value: "1.0"},
+		{Namespace: device.SettingsSystem, Key: "name", Value: "a=b"},
+		{Namespace: device.SettingsSystem, Key: "screen_off_timeout", Value: "60000"},
+	}
+	if !reflect.DeepEqual(settings, expected) {
+		t.Errorf("Settings do not match expected: expected %v, got %v", expected, settings)
+	}
+}
+
+// TestRestoreSettings tests restoring settings changed during the session on close
+func TestRestoreSettings(t *testing.T) {
+	fake := newFakeTransport()
+	fake.reply("settings get system screen_off_timeout", "60000\n")
+	fake.reply("settings get global new_key", "null\n")
+	d := device.NewTestDevice("fake", fake)
+
+	if err := d.PutSetting(device.SettingsSystem, "screen_off_timeout", "10000"); err != nil {
+		t.Fatalf("Failed to put setting: %v", err)
+	}
+	if err := d.PutSetting(device.SettingsSystem, "screen_off_timeout", "20000"); err != nil {
+		t.Fatalf("Failed to put setting: %v", err)
+	}
+	if err := d.PutSetting(device.SettingsGlobal, "new_key", "hello world"); err != nil {
+		t.Fatalf("Failed to put setting: %v", err)
+	}
+
+	if changed := d.ChangedSettings(); len(changed) != 2 {
+		t.Fatalf("Expected 2 changed settings, got %v", changed)
+	}
+
+	if err := d.Close(); err != nil {
+		t.Fatalf("Failed to close device: %v", err)
+	}
+
+	expected := []string{
+		"settings get system screen_off_timeout",
+		"settings put system screen_off_timeout 10000",
+		"settings put system screen_off_timeout 20000",
+		"settings get global new_key",
+		"settings put global new_key 'hello world'",
+		"settings delete global new_key",
+		"settings put system screen_off_timeout 60000",
+	}
+	if history := fake.history(); !reflect.DeepEqual(history, expected) {
+		t.Errorf("Commands do not match expected:\nexpected %q\ngot      %q", expected, history)
+	}
+
+	if changed := d.ChangedSettings(); len(changed) != 0 {
+		t.Errorf("Expected no changed settings after restore, got %v", changed)
+	}
+}
+
+// TestPutSettingError tests reporting errors printed by the settings command
+func TestPutSettingError(t *testing.T) {
+	fake := newFakeTransport()
+	fake.reply("settings get", "1\n")
+	fake.reply("settings put", "java.lang.SecurityException: Permission denial\n")
+	d := device.NewTestDevice("fake", fake)
+
+	if err := d.PutSetting(device.SettingsSecure, "adb_enabled", "0"); err == nil {
+		t.Error("Should return error when settings put prints an exception")
+	}
+}
@@ -0,0 +1,42 @@
+package device
+
+import (
+	"fmt"
+	"mcp-android-adb-server/shellquote"
+	"strings"
+)
+
+// shell runs a trusted command with quoted arguments.
+// e.g. shell("pm path", packageName)
+func (d *AndroidDevice) shell(cmd string, args ...string) (string, error) {
+	return d.RunShellCommand(shellquote.Command(cmd, args...))
+}
+
+// runChecked runs a framework command and fails if it exits with a
+// non-zero status.
+func (d *AndroidDevice) runChecked(cmd string) error {
+	result, err := d.Exec(cmd, ExecOptions{})
+	if err != nil {
+		return fmt.Errorf("%s: %w", cmd, err)
+	}
+
+	if result.ExitCode != 0 {
+		return fmt.Errorf("%s: %s", cmd, result.failure())
+	}
+
+	return nil
+}
+
+// runFirst runs each command in turn until one succeeds.
+func (d *AndroidDevice) runFirst(cmds []string) error {
+	var errs []string
+	for _, cmd := range cmds {
+		err := d.runChecked(cmd)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err.Error())
+	}
+
+	return fmt.Errorf("%s", strings.Join(errs, "; "))
+}
@@ -0,0 +1,185 @@
+package device_test
+
+import (
+	"mcp-android-adb-server/device"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// shellWords splits a command line into words the way the device shell
+// does, and fails if the line has unquoted operators, substitutions or
+// unterminated quotes, i.e. anything that could start another command.
+func shellWords(line string) ([]string, bool) {
+	var (
+		words  []string
+		word   strings.Builder
+		inWord bool
+	)
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == ' ' || c == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case c == '\'':
+			end := strings.IndexByte(line[i+1:], '\'')
+			if end < 0 {
+				return nil, false
+			}
+			word.WriteString(line[i+1 : i+1+end])
+			i += end + 1
+			inWord = true
+		case c == '\\':
+			if i+1 == len(line) {
+				return nil, false
+			}
+			i++
+			word.WriteByte(line[i])
+			inWord = true
+		case strings.IndexByte("\"$`;&|<>()\n*?[#~", c) >= 0:
+			return nil, false
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words, true
+}
+
+// lastCommand runs fn on a device over a fake transport and returns the
+// words of the last shell command it ran, or false if fn rejected its
+// input without running any.
+func lastCommand(t *testing.T, fn func(d *device.AndroidDevice) error) ([]string, bool) {
+	fake := newFakeTransport()
+	d := device.NewTestDevice("fake", fake)
+
+	err := fn(d)
+
+	history := fake.history()
+	if len(history) == 0 {
+		if err == nil {
+			t.Fatal("Expected a shell command")
+		}
+		return nil, false
+	}
+
+	line := history[len(history)-1]
+	words, ok := shellWords(line)
+	if !ok {
+		t.Fatalf("Command line %q escapes its arguments", line)
+	}
+	return words, true
+}
+
+// fuzzSeeds are inputs trying to break out of a quoted argument.
+var fuzzSeeds = []string{
+	"com.example.app",
+	"",
+	"com.example.app; reboot",
+	"a && rm -rf /sdcard",
+	"$(reboot)",
+	"`id`",
+	"it's",
+	"'\\''",
+	"a\nreboot",
+	"你好 world",
+	"*",
+	"#comment",
+}
+
+// FuzzInstalledApp tests that package names stay a single pm path argument
+func FuzzInstalledApp(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, pkg string) {
+		if strings.ContainsRune(pkg, 0) {
+			t.Skip("the device shell cannot receive NUL bytes")
+		}
+
+		words, _ := lastCommand(t, func(d *device.AndroidDevice) error {
+			_, err := d.InstalledApp(pkg)
+			return err
+		})
+		if want := []string{"pm", "path", pkg}; !reflect.DeepEqual(words, want) {
+			t.Errorf("Expected %q, got %q", want, words)
+		}
+	})
+}
+
+// FuzzInputText tests that text stays a single input text argument
+func FuzzInputText(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, text string) {
+		if strings.ContainsRune(text, 0) {
+			t.Skip("the device shell cannot receive NUL bytes")
+		}
+
+		words, _ := lastCommand(t, func(d *device.AndroidDevice) error {
+			return d.InputText(text)
+		})
+		if want := []string{"input", "text", strings.ReplaceAll(text, " ", "%s")}; !reflect.DeepEqual(words, want) {
+			t.Errorf("Expected %q, got %q", want, words)
+		}
+	})
+}
+
+// FuzzGetSetting tests that setting keys stay a single argument
+func FuzzGetSetting(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, key string) {
+		if strings.ContainsRune(key, 0) {
+			t.Skip("the device shell cannot receive NUL bytes")
+		}
+
+		words, ran := lastCommand(t, func(d *device.AndroidDevice) error {
+			_, err := d.GetSetting(device.SettingsSystem, key)
+			return err
+		})
+		if !ran {
+			return
+		}
+		if want := []string{"settings", "get", "system", key}; !reflect.DeepEqual(words, want) {
+			t.Errorf("Expected %q, got %q", want, words)
+		}
+	})
+}
+
+// TestInstalledApp tests the exact match of installed packages
+func TestInstalledApp(t *testing.T) {
+	fake := newFakeTransport()
+	fake.reply("pm path com.example.app", "package:/data/app/com.example.app-1/base.apk\npackage:/data/app/com.example.app-1/split_config.arm64_v8a.apk\n")
+	d := device.NewTestDevice("fake", fake)
+
+	tests := map[string]bool{
+		"com.example.app": true,
+		"com.example":     false,
+		"example":         false,
+	}
+
+	for pkg, want := range tests {
+		installed, err := d.InstalledApp(pkg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if installed != want {
+			t.Errorf("InstalledApp(%q) = %v, expected %v", pkg, installed, want)
+		}
+	}
+}
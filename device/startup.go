@@ -0,0 +1,266 @@
+package device
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mcp-android-adb-server/perf"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	// Fields printed by `am start -W`
+	launchStateRegexp    = regexp.MustCompile(`LaunchState: (\w+)`)
+	launchActivityRegexp = regexp.MustCompile(`Activity: (\S+)`)
+	totalTimeRegexp      = regexp.MustCompile(`TotalTime: (\d+)`)
+	waitTimeRegexp       = regexp.MustCompile(`WaitTime: (\d+)`)
+)
+
+// Startup modes accepted by MeasureStartup.
+const (
+	StartupCold = "cold" // Process killed before each launch
+	StartupWarm = "warm" // Activity destroyed, process kept alive
+	StartupHot  = "hot"  // Activity moved to the background
+)
+
+// LaunchResult is the timing of an activity launch reported by `am start -W`.
+type LaunchResult struct {
+	Activity    string `json:"activity"`     // Launched component
+	LaunchState string `json:"launch_state"` // COLD, WARM or HOT (Android 10+)
+	TotalTimeMs int    `json:"total_time_ms"`
+	WaitTimeMs  int    `json:"wait_time_ms"`
+}
+
+// StartupStats summarizes the launch times of several runs.
+type StartupStats struct {
+	Min    float64 `json:"min"`
+	Median float64 `json:"median"`
+	P90    float64 `json:"p90"`
+	Mean   float64 `json:"mean"`
+	Max    float64 `json:"max"`
+}
+
+// alwaysFinishActivities is the "Don't keep activities" developer option.
+const alwaysFinishActivities = "always_finish_activities"
+
+// startupMethods describe how each startup mode prepares the app.
+var startupMethods = map[string]string{
+	StartupCold: "am force-stop before each launch",
+	StartupWarm: "input keyevent HOME with always_finish_activities=1, destroying the activity but not the process",
+	StartupHot:  "input keyevent HOME, keeping the activity",
+}
+
+// StartupReport is the result of MeasureStartup.
+type StartupReport struct {
+	Package   string         `json:"package"`
+	Mode      string         `json:"mode"`
+	Method    string         `json:"method"` // How the app was prepared before each launch
+	Runs      []LaunchResult `json:"runs"`
+	TotalTime StartupStats   `json:"total_time_ms"`
+	WaitTime  StartupStats   `json:"wait_time_ms"`
+	Notes     []string       `json:"notes,omitempty"`
+}
+
+// LaunchActivity returns the launcher activity of a package, e.g. com.example.app/.MainActivity.
+func (d *AndroidDevice) LaunchActivity(packageName string) (string, error) {
+	out, err := d.shell("cmd package resolve-activity --brief -c android.intent.category.LAUNCHER", packageName)
+	if err != nil {
+		return "", fmt.Errorf("resolve activity: %w", err)
+	}
+
+	// Output format: the resolved priority line followed by the component
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	component := strings.TrimSpace(lines[len(lines)-1])
+	if !strings.Contains(component, "/") {
+		return "", fmt.Errorf("no launcher activity found for %s: %s", packageName, component)
+	}
+
+	return component, nil
+}
+
+// LaunchAppMeasured launches an app with `am start -W` and returns the launch timing.
+func (d *AndroidDevice) LaunchAppMeasured(packageName string) (*LaunchResult, error) {
+	component, err := d.LaunchActivity(packageName)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := d.shell("am start -W -n", component)
+	if err != nil {
+		return nil, fmt.Errorf("app launch: %w", err)
+	}
+
+	return parseLaunchResult(out)
+}
+
+// MeasureStartup launches an app runs times in the given mode and returns
+// the timing of each run with aggregate statistics. For cold starts the page
+// cache can be dropped before each run, which requires root.
+func (d *AndroidDevice) MeasureStartup(packageName string, runs int, mode string, dropCaches bool) (report *StartupReport, err error) {
+	if runs <= 0 {
+		return nil, fmt.Errorf("invalid number of runs %d", runs)
+	}
+
+	method, ok := startupMethods[mode]
+	if !ok {
+		return nil, fmt.Errorf("invalid startup mode %q, expected cold, warm or hot", mode)
+	}
+	report = &StartupReport{Package: packageName, Mode: mode, Method: method, Runs: make([]LaunchResult, 0, runs)}
+
+	if mode == StartupWarm {
+		// BACK only moves a root launcher activity to the background on
+		// Android 12+, which measures a hot start. Don't keep activities
+		// destroys it when HOME is pressed while the process stays alive.
+		original, err := d.GetSetting(SettingsGlobal, alwaysFinishActivities)
+		if err != nil {
+			return nil, err
+		}
+		if err := d.PutSetting(SettingsGlobal, alwaysFinishActivities, "1"); err != nil {
+			return nil, err
+		}
+		restore := func(d *AndroidDevice) error {
+			if original == settingNull {
+				return d.deleteSetting(SettingsGlobal, alwaysFinishActivities)
+			}
+			return d.putSetting(SettingsGlobal, alwaysFinishActivities, original)
+		}
+		defer func() {
+			// The measurement may have been cancelled, so the setting is
+			// restored without the context, and again on Close if that fails
+			rerr := restore(d.WithContext(context.Background()))
+			if rerr == nil {
+				return
+			}
+			d.onCloseOnce("restore "+alwaysFinishActivities, restore)
+			err = errors.Join(err, fmt.Errorf("restore %s, retried when the device is closed: %w", alwaysFinishActivities, rerr))
+		}()
+	}
+
+	if mode != StartupCold {
+		// Start the process once so that every measured run finds it alive
+		if _, err := d.LaunchAppMeasured(packageName); err != nil {
+			return nil, err
+		}
+		d.Sleep()
+	}
+
+	for i := 0; i < runs; i++ {
+		if err := d.prepareStartup(packageName, mode, dropCaches, report); err != nil {
+			return nil, err
+		}
+
+		result, err := d.LaunchAppMeasured(packageName)
+		if err != nil {
+			return nil, fmt.Errorf("run %d: %w", i+1, err)
+		}
+		report.Runs = append(report.Runs, *result)
+		d.Sleep()
+	}
+
+	// The launch state reported on Android 10+ shows whether the method worked
+	mismatched := 0
+	for _, run := range report.Runs {
+		if run.LaunchState != "" && !strings.EqualFold(run.LaunchState, mode) {
+			mismatched++
+		}
+	}
+	if mismatched > 0 {
+		report.addNote(fmt.Sprintf("%d of %d runs were not %s starts, see launch_state", mismatched, len(report.Runs), mode))
+	}
+
+	total := make([]float64, len(report.Runs))
+	wait := make([]float64, len(report.Runs))
+	for i, run := range report.Runs {
+		total[i] = float64(run.TotalTimeMs)
+		wait[i] = float64(run.WaitTimeMs)
+	}
+	report.TotalTime = newStartupStats(total)
+	report.WaitTime = newStartupStats(wait)
+
+	return report, nil
+}
+
+// prepareStartup puts the app in the state required by the startup mode.
+func (d *AndroidDevice) prepareStartup(packageName, mode string, dropCaches bool, report *StartupReport) error {
+	switch mode {
+	case StartupCold:
+		if err := d.TerminateApp(packageName); err != nil {
+			return err
+		}
+		if dropCaches {
+			if _, err := d.runAsRoot("sync; echo 3 > /proc/sys/vm/drop_caches"); err != nil {
+				report.addNote(fmt.Sprintf("page cache not dropped: %v", err))
+			}
+		}
+	case StartupWarm, StartupHot:
+		if err := d.InputKey(KeycodeHome); err != nil {
+			return err
+		}
+	}
+
+	d.Sleep()
+	return nil
+}
+
+// addNote records a note once.
+func (r *StartupReport) addNote(note string) {
+	for _, n := range r.Notes {
+		if n == note {
+			return
+		}
+	}
+	r.Notes = append(r.Notes, note)
+}
+
+// parseLaunchResult parses the output of `am start -W`.
+func parseLaunchResult(out string) (*LaunchResult, error) {
+	if strings.Contains(out, "Error") {
+		return nil, fmt.Errorf("app launch: %s", strings.TrimSpace(out))
+	}
+
+	match := totalTimeRegexp.FindStringSubmatch(out)
+	if match == nil {
+		return nil, fmt.Errorf("failed to parse launch time: %s", strings.TrimSpace(out))
+	}
+
+	result := &LaunchResult{}
+	result.TotalTimeMs, _ = strconv.Atoi(match[1])
+
+	if match := waitTimeRegexp.FindStringSubmatch(out); match != nil {
+		result.WaitTimeMs, _ = strconv.Atoi(match[1])
+	}
+	if match := launchStateRegexp.FindStringSubmatch(out); match != nil {
+		result.LaunchState = match[1]
+	}
+	if match := launchActivityRegexp.FindStringSubmatch(out); match != nil {
+		result.Activity = match[1]
+	}
+
+	return result, nil
+}
+
+// newStartupStats computes the statistics of launch times.
+func newStartupStats(values []float64) StartupStats {
+	if len(values) == 0 {
+		return StartupStats{}
+	}
+
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	var sum float64
+	for _, v := range sorted {
+		sum += v
+	}
+
+	return StartupStats{
+		Min:    sorted[0],
+		Median: perf.Percentile(sorted, 50),
+		P90:    perf.Percentile(sorted, 90),
+		Mean:   sum / float64(len(sorted)),
+		Max:    sorted[len(sorted)-1],
+	}
+}
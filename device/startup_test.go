@@ -0,0 +1,132 @@
+package device_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mcp-android-adb-server/device"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestMeasureStartup tests measuring cold launches and aggregating their times
+func TestMeasureStartup(t *testing.T) {
+	times := []int{500, 300, 400, 900, 600}
+	run := 0
+
+	fake := newFakeTransport()
+	fake.reply("cmd package resolve-activity", "priority=0 preferredOrder=0 match=0x108000 specificIndex=-1 isDefault=true\ncom.example.app/.MainActivity\n")
+	fake.handle("am start -W", func(string) (string, error) {
+		total := times[run]
+		run++
+		return fmt.Sprintf("Starting: Intent { cmp=com.example.app/.MainActivity }\nStatus: ok\nLaunchState: COLD\nActivity: com.example.app/.MainActivity\nTotalTime: %d\nWaitTime: %d\nComplete\n", total, total+10), nil
+	})
+	fake.reply("id -u", "2000\n")
+	fake.reply("su ", "/system/bin/sh: su: not found\n")
+	d := device.NewTestDevice("fake", fake, device.WithSleepDuration(0))
+
+	report, err := d.MeasureStartup("com.example.app", len(times), device.StartupCold, true)
+	if err != nil {
+		t.Fatalf("Failed to measure startup: %v", err)
+	}
+
+	if len(report.Runs) != len(times) || report.Runs[0].LaunchState != "COLD" || report.Runs[0].Activity != "com.example.app/.MainActivity" {
+		t.Fatalf("Unexpected runs: %+v", report.Runs)
+	}
+
+	expected := device.StartupStats{Min: 300, Median: 500, P90: 900, Mean: 540, Max: 900}
+	if report.TotalTime != expected {
+		t.Errorf("Total time stats do not match expected: expected %+v, got %+v", expected, report.TotalTime)
+	}
+	if report.WaitTime.Min != 310 {
+		t.Errorf("Unexpected wait time stats: %+v", report.WaitTime)
+	}
+	if len(report.Notes) != 1 || !strings.Contains(report.Notes[0], "page cache") {
+		t.Errorf("Expected a single note about the page cache, got %v", report.Notes)
+	}
+
+	stops := 0
+	for _, cmd := range fake.history() {
+		if cmd == "am force-stop com.example.app" {
+			stops++
+		}
+	}
+	if stops != len(times) {
+		t.Errorf("Expected %d force-stops, got %d", len(times), stops)
+	}
+}
+
+// TestLaunchAppMeasuredError tests reporting launch errors
+func TestLaunchAppMeasuredError(t *testing.T) {
+	fake := newFakeTransport()
+	fake.reply("cmd package resolve-activity", "No activity found\n")
+	d := device.NewTestDevice("fake", fake)
+
+	if _, err := d.LaunchAppMeasured("com.example.missing"); err == nil {
+		t.Error("Should return error when no launcher activity is found")
+	}
+}
+
+// TestMeasureWarmStartup tests destroying the activity without the process
+// and restoring the developer option afterwards
+func TestMeasureWarmStartup(t *testing.T) {
+	fake := newFakeTransport()
+	fake.reply("cmd package resolve-activity", "com.example.app/.MainActivity\n")
+	fake.reply("am start -W", "Status: ok\nLaunchState: WARM\nTotalTime: 200\nWaitTime: 210\nComplete\n")
+	fake.reply("settings get global always_finish_activities", "0\n")
+	d := device.NewTestDevice("fake", fake, device.WithSleepDuration(0))
+
+	report, err := d.MeasureStartup("com.example.app", 2, device.StartupWarm, false)
+	if err != nil {
+		t.Fatalf("Failed to measure startup: %v", err)
+	}
+	if !strings.Contains(report.Method, "always_finish_activities") || len(report.Notes) != 0 {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+
+	var commands []string
+	for _, cmd := range fake.history() {
+		if strings.HasPrefix(cmd, "settings put") || strings.HasPrefix(cmd, "input keyevent") {
+			commands = append(commands, cmd)
+		}
+	}
+	expected := []string{
+		"settings put global always_finish_activities 1",
+		"input keyevent 3",
+		"input keyevent 3",
+		"settings put global always_finish_activities 0",
+	}
+	if strings.Join(commands, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Commands do not match expected:\nexpected %q\ngot      %q", expected, commands)
+	}
+}
+
+// TestMeasureWarmStartupCancelled tests that Don't keep activities is
+// restored when the measurement is cancelled
+func TestMeasureWarmStartupCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fake := newFakeTransport()
+	// Commands of a device with a context are wrapped to record their PID
+	fake.handle("", func(cmd string) (string, error) {
+		switch {
+		case strings.Contains(cmd, "cmd package resolve-activity"):
+			return "com.example.app/.MainActivity\n", nil
+		case strings.Contains(cmd, "settings get global always_finish_activities"):
+			return "0\n", nil
+		case strings.Contains(cmd, "am start -W"):
+			cancel()
+		}
+		return "", nil
+	})
+	d := device.NewTestDevice("fake", fake, device.WithSleepDuration(0))
+
+	if _, err := d.WithContext(ctx).MeasureStartup("com.example.app", 2, device.StartupWarm, false); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected a cancelled measurement, got %v", err)
+	}
+	if !slices.Contains(fake.history(), "settings put global always_finish_activities 0") {
+		t.Errorf("Setting not restored: %q", fake.history())
+	}
+}
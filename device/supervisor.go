@@ -0,0 +1,386 @@
+package device
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/electricbubble/gadb"
+)
+
+// Connection states reported by ConnectionStatus.
+const (
+	StateConnected    = "connected"
+	StateReconnecting = "reconnecting"
+	StateDisconnected = "disconnected"
+)
+
+// ErrDeviceReconnecting is returned while the connection to the device is
+// being restored and the operation could not be retried.
+var ErrDeviceReconnecting = errors.New("device reconnecting")
+
+// readOnlyCommand matches a shell command that is safe to run twice. Only
+// subcommands that never change state are listed, e.g. dumpsys battery but
+// not dumpsys battery set.
+var readOnlyCommand = regexp.MustCompile(`^(` + strings.Join([]string{
+	`(getprop|cat|ls|ps|df|id|stat|uname|grep|head|tail|wc)( \S+)*`,
+	`(pm|cmd package) (list|path|resolve-activity)( \S+)*`,
+	`settings (get|list)( \S+)*`,
+	`wm (size|density)`,
+	`date( \+\S+)?`,
+	`ip( -\S+)* (addr|address|route|link)( show( \S+)*)?`,
+	`dumpsys( -\S+)*( (battery|connectivity|wifi|window|power|input|display|notification|meminfo|gfxinfo|SurfaceFlinger|sensorservice|cpuinfo)( -\S+| '?[a-zA-Z]\w*(\.\w+)+'?| displays| framestats)*)?`,
+	`dumpsys deviceidle( (get|enabled) \w+)?`,
+	`uiautomator dump( \S+)*`,
+	`appops get( \S+)*`,
+	`am get-\S+( \S+)*`,
+	`cmd clipboard get( \S+)*`,
+	`service (check|list)( \S+)*`,
+}, "|") + `)$`)
+
+// devNull matches output discarded with 2>/dev/null or >/dev/null.
+var devNull = regexp.MustCompile(`\s*[0-9]?>\s*/dev/null`)
+
+// ConnectionStatus describes the connection to the device.
+type ConnectionStatus struct {
+	Serial     string    `json:"serial"`
+	State      string    `json:"state"`                // connected, reconnecting or disconnected
+	Reconnects int       `json:"reconnects"`           // Successful reconnects during the session
+	LastError  string    `json:"last_error,omitempty"` // Error that caused the last reconnect
+	Since      time.Time `json:"since"`                // Time of the last state change
+}
+
+// supervisor wraps the device transport, re-resolving the device with
+// backoff when the connection drops and retrying idempotent operations.
+type supervisor struct {
+	resolve  func() (transport, error)
+	attempts int
+	backoff  time.Duration
+
+	// reconnecting serializes reconnects so concurrent failures share one
+	reconnecting sync.Mutex
+
+	mu         sync.Mutex
+	inner      transport
+	generation int       // Incremented each time inner is replaced
+	retryAt    time.Time // Reconnects fail fast until then after giving up
+	status     ConnectionStatus
+}
+
+// WithReconnect sets how many times a dropped connection is re-resolved and
+// the delay before the first attempt, which doubles after each attempt.
+func WithReconnect(attempts int, backoff time.Duration) Option {
+	return func(d *AndroidDevice) {
+		d.reconnectAttempts = attempts
+		d.reconnectBackoff = backoff
+	}
+}
+
+// supervise resolves the device and wraps its transport in a supervisor.
+func (d *AndroidDevice) supervise(resolve func() (transport, error)) error {
+	s := &supervisor{
+		resolve:  resolve,
+		attempts: d.reconnectAttempts,
+		backoff:  d.reconnectBackoff,
+		status:   ConnectionStatus{Serial: d.id, State: StateConnected, Since: time.Now()},
+	}
+
+	inner, err := resolve()
+	if err != nil {
+		return err
+	}
+	s.inner = inner
+	d.adb = s
+
+	return nil
+}
+
+// supervisor returns the supervisor of the device transport, if any.
+func (d *AndroidDevice) supervisor() (*supervisor, bool) {
+	switch t := d.adb.(type) {
+	case *supervisor:
+		return t, true
+	case *boundSupervisor:
+		return t.supervisor, true
+	default:
+		return nil, false
+	}
+}
+
+// ConnectionStatus returns the state of the connection to the device.
+func (d *AndroidDevice) ConnectionStatus() ConnectionStatus {
+	s, ok := d.supervisor()
+	if !ok {
+		return ConnectionStatus{Serial: d.id, State: StateConnected}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.status
+}
+
+// setState records a state change.
+func (s *supervisor) setState(state string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if state == StateConnected && s.status.State != StateConnected {
+		s.status.Reconnects++
+	}
+	if err != nil {
+		s.status.LastError = err.Error()
+	}
+	if s.status.State != state {
+		s.status.State = state
+		s.status.Since = time.Now()
+	}
+}
+
+// current returns the transport in use and its generation.
+func (s *supervisor) current() (transport, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.inner, s.generation
+}
+
+// reconnect re-resolves the device unless another caller already replaced
+// the transport of the failed generation. It stops waiting between attempts
+// when ctx is done.
+func (s *supervisor) reconnect(ctx context.Context, failed int, cause error) error {
+	s.reconnecting.Lock()
+	defer s.reconnecting.Unlock()
+
+	if _, generation := s.current(); generation != failed {
+		return nil
+	}
+
+	// Callers queued behind a failed reconnect would each wait for the whole
+	// backoff again
+	s.mu.Lock()
+	wait := time.Until(s.retryAt)
+	s.mu.Unlock()
+	if wait > 0 {
+		return fmt.Errorf("%w: device disconnected, next reconnect in %s", ErrDeviceReconnecting, wait.Round(time.Millisecond))
+	}
+
+	s.setState(StateReconnecting, cause)
+
+	delay := s.backoff
+	var err error
+	for i := 0; i < s.attempts; i++ {
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			// The next caller reconnects without waiting for a cool-down
+			s.setState(StateDisconnected, nil)
+			return fmt.Errorf("%w: reconnect stopped: %w", ErrDeviceReconnecting, ctx.Err())
+		}
+		delay *= 2
+
+		var inner transport
+		if inner, err = s.resolve(); err == nil {
+			s.mu.Lock()
+			s.inner = inner
+			s.generation++
+			s.mu.Unlock()
+			s.setState(StateConnected, nil)
+			return nil
+		}
+	}
+
+	s.mu.Lock()
+	s.retryAt = time.Now().Add(delay)
+	s.mu.Unlock()
+	s.setState(StateDisconnected, nil)
+	return fmt.Errorf("%w: gave up after %d attempts: %v", ErrDeviceReconnecting, s.attempts, err)
+}
+
+// do runs fn, reconnecting when the connection dropped. The operation is
+// retried if it never reached the device or is idempotent.
+func (s *supervisor) do(ctx context.Context, idempotent bool, fn func(t transport) error) error {
+	inner, generation := s.current()
+
+	err := fn(inner)
+	if err == nil || !isDisconnected(err) {
+		return err
+	}
+
+	if rerr := s.reconnect(ctx, generation, err); rerr != nil {
+		return fmt.Errorf("%w (%v)", rerr, err)
+	}
+
+	if !idempotent && !notDelivered(err) {
+		return fmt.Errorf("%w: connection dropped while running the operation, it may have partly run: %v", ErrDeviceReconnecting, err)
+	}
+
+	inner, _ = s.current()
+	return fn(inner)
+}
+
+// supervised runs fn, an operation that opens its own connection to the ADB
+// server, reconnecting and retrying it like the operations of the transport.
+func (d *AndroidDevice) supervised(idempotent bool, fn func() error) error {
+	s, ok := d.supervisor()
+	if !ok {
+		return fn()
+	}
+
+	return s.do(d.ctx, idempotent, func(transport) error {
+		return fn()
+	})
+}
+
+// boundSupervisor runs the operations of a supervisor for a view of the
+// device, reconnecting only until the context of the view is done.
+type boundSupervisor struct {
+	*supervisor
+	ctx context.Context
+}
+
+// bind returns the transport of a device view with the given context.
+func (s *supervisor) bind(ctx context.Context) transport {
+	return &boundSupervisor{supervisor: s, ctx: ctx}
+}
+
+func (b *boundSupervisor) RunShellCommand(cmd string, args ...string) (string, error) {
+	return b.runShellCommand(b.ctx, cmd, args...)
+}
+
+func (b *boundSupervisor) PushFile(local *os.File, remotePath string, modification ...time.Time) error {
+	return b.pushFile(b.ctx, local, remotePath, modification...)
+}
+
+func (b *boundSupervisor) Pull(remotePath string, dest io.Writer) error {
+	return b.pull(b.ctx, remotePath, dest)
+}
+
+func (s *supervisor) RunShellCommand(cmd string, args ...string) (string, error) {
+	return s.runShellCommand(context.Background(), cmd, args...)
+}
+
+func (s *supervisor) PushFile(local *os.File, remotePath string, modification ...time.Time) error {
+	return s.pushFile(context.Background(), local, remotePath, modification...)
+}
+
+func (s *supervisor) Pull(remotePath string, dest io.Writer) error {
+	return s.pull(context.Background(), remotePath, dest)
+}
+
+func (s *supervisor) runShellCommand(ctx context.Context, cmd string, args ...string) (out string, err error) {
+	full := unwrapCommand(strings.TrimSpace(strings.Join(append([]string{cmd}, args...), " ")))
+	err = s.do(ctx, isReadOnlyCommand(full), func(t transport) (err error) {
+		out, err = t.RunShellCommand(cmd, args...)
+		return err
+	})
+	return out, err
+}
+
+func (s *supervisor) pushFile(ctx context.Context, local *os.File, remotePath string, modification ...time.Time) error {
+	return s.do(ctx, true, func(t transport) error {
+		if _, err := local.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		return t.PushFile(local, remotePath, modification...)
+	})
+}
+
+func (s *supervisor) pull(ctx context.Context, remotePath string, dest io.Writer) error {
+	counter := &countingWriter{w: dest}
+	return s.do(ctx, true, func(t transport) error {
+		// A partial download cannot be retried without corrupting dest
+		if counter.n > 0 {
+			return fmt.Errorf("pull %s interrupted after %d bytes", remotePath, counter.n)
+		}
+		return t.Pull(remotePath, counter)
+	})
+}
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// isReadOnlyCommand reports whether every command of a shell command line
+// only reads state.
+func isReadOnlyCommand(cmd string) bool {
+	cmd = devNull.ReplaceAllString(cmd, "")
+	if strings.ContainsAny(cmd, "<>&`$\n") {
+		return false
+	}
+
+	for _, part := range strings.FieldsFunc(cmd, func(r rune) bool { return r == '|' || r == ';' }) {
+		if !readOnlyCommand.MatchString(strings.Join(strings.Fields(part), " ")) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isDisconnected reports whether err means the device or ADB server went
+// away. A cancelled operation did not lose the connection.
+func isDisconnected(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if msg, ok := adbFailure(err); ok {
+		return deviceGone(msg)
+	}
+
+	var opErr *net.OpError
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, gadb.ErrConnBroken) || errors.As(err, &opErr)
+}
+
+// notDelivered reports whether err happened before the ADB server handed
+// the request to the device, so the operation did not run.
+func notDelivered(err error) bool {
+	if msg, ok := adbFailure(err); ok {
+		return deviceGone(msg)
+	}
+
+	return errors.Is(err, errNotStarted) || errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// adbFailure returns the message of a FAIL status of the ADB server, read
+// by adbConn or by gadb, which reports it as "command failed: <message>".
+func adbFailure(err error) (string, bool) {
+	var failure *adbError
+	if errors.As(err, &failure) {
+		return failure.msg, true
+	}
+
+	return strings.CutPrefix(err.Error(), "command failed: ")
+}
+
+// deviceGone reports whether a FAIL status of the ADB server means the
+// device is not available.
+func deviceGone(msg string) bool {
+	for _, s := range []string{"not found", "offline", "no devices"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+
+	return false
+}
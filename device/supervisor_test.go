@@ -0,0 +1,228 @@
+package device_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mcp-android-adb-server/device"
+	"net"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestSupervisorRetry tests reconnecting and retrying read-only commands
+func TestSupervisorRetry(t *testing.T) {
+	offline := newFakeTransport()
+	offline.handle("", func(string) (string, error) {
+		return "", fmt.Errorf("command failed: device offline")
+	})
+	online := newFakeTransport()
+	online.reply("getprop ro.product.model", "Pixel 5\n")
+
+	// The device is missing on the first reconnect attempt
+	var resolved int
+	resolve := func() (device.Transport, error) {
+		resolved++
+		switch resolved {
+		case 1:
+			return offline, nil
+		case 2:
+			return nil, fmt.Errorf("device 'fake' not found")
+		default:
+			return online, nil
+		}
+	}
+
+	d, err := device.NewTestSupervisedDevice("fake", resolve, device.WithReconnect(3, time.Millisecond))
+	if err != nil {
+		t.Fatalf("Failed to create device: %v", err)
+	}
+
+	out, err := d.RunShellCommand("getprop ro.product.model")
+	if err != nil || out != "Pixel 5\n" {
+		t.Fatalf("Expected the command to be retried, got %q %v", out, err)
+	}
+
+	status := d.ConnectionStatus()
+	if status.State != device.StateConnected || status.Reconnects != 1 || status.LastError == "" {
+		t.Errorf("Unexpected connection status: %+v", status)
+	}
+}
+
+// TestSupervisorNoRetry tests that mutating commands are not run twice
+func TestSupervisorNoRetry(t *testing.T) {
+	dropped := newFakeTransport()
+	dropped.handle("", func(string) (string, error) {
+		return "", &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+	})
+	online := newFakeTransport()
+
+	transports := []*fakeTransport{dropped, online}
+	var resolved int
+	resolve := func() (device.Transport, error) {
+		resolved++
+		return transports[resolved-1], nil
+	}
+
+	d, err := device.NewTestSupervisedDevice("fake", resolve, device.WithReconnect(3, time.Millisecond))
+	if err != nil {
+		t.Fatalf("Failed to create device: %v", err)
+	}
+
+	if _, err := d.RunShellCommand("input tap 100 200"); !errors.Is(err, device.ErrDeviceReconnecting) {
+		t.Fatalf("Expected ErrDeviceReconnecting, got %v", err)
+	}
+	if len(online.history()) != 0 {
+		t.Errorf("Mutating command should not be retried: %q", online.history())
+	}
+
+	// The next command uses the new connection
+	if _, err := d.RunShellCommand("input tap 100 200"); err != nil {
+		t.Errorf("Failed to run command after reconnecting: %v", err)
+	}
+}
+
+// TestSupervisorGiveUp tests the status after reconnecting fails
+func TestSupervisorGiveUp(t *testing.T) {
+	offline := newFakeTransport()
+	offline.handle("", func(string) (string, error) {
+		return "", fmt.Errorf("command failed: device offline")
+	})
+
+	var mu sync.Mutex
+	var resolved int
+	resolve := func() (device.Transport, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		resolved++
+		if resolved == 1 {
+			return offline, nil
+		}
+		return nil, fmt.Errorf("device 'fake' not found")
+	}
+
+	d, err := device.NewTestSupervisedDevice("fake", resolve, device.WithReconnect(2, time.Millisecond))
+	if err != nil {
+		t.Fatalf("Failed to create device: %v", err)
+	}
+
+	// Concurrent callers all report the reconnecting error
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := d.RunShellCommand("dumpsys battery"); !errors.Is(err, device.ErrDeviceReconnecting) {
+				t.Errorf("Expected ErrDeviceReconnecting, got %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if status := d.ConnectionStatus(); status.State != device.StateDisconnected {
+		t.Errorf("Expected disconnected state, got %+v", status)
+	}
+
+	// Callers queued behind the failed reconnect fail fast during the cool-down
+	mu.Lock()
+	defer mu.Unlock()
+	if resolved != 3 {
+		t.Errorf("Expected one reconnect of 2 attempts, resolved %d times", resolved)
+	}
+}
+
+// TestIsReadOnlyCommand tests which commands are retried after reconnecting
+func TestIsReadOnlyCommand(t *testing.T) {
+	tests := map[string]bool{
+		"getprop ro.product.model":                     true,
+		"dumpsys battery":                              true,
+		"dumpsys window | grep mDreamingLockscreen=":   true,
+		"dumpsys gfxinfo 'com.example.app' framestats": true,
+		"dumpsys deviceidle get deep":                  true,
+		"dumpsys deviceidle enabled light":             true,
+		"ip -o -4 addr show wlan0":                     true,
+		"cat /proc/net/xt_qtaguid/stats 2>/dev/null":   true,
+		"lsof":                                           false,
+		"pstree; reboot":                                 false,
+		"dumpsys battery set level 5":                    false,
+		"dumpsys battery unplug":                         false,
+		"dumpsys gfxinfo com.example.app reset":          false,
+		"dumpsys deviceidle force-idle":                  false,
+		"ip link set wlan0 down":                         false,
+		"date -s 20200101":                               false,
+		"cat /sdcard/a > /sdcard/b":                      false,
+		"ls $(rm -rf /sdcard)":                           false,
+		"settings put global always_finish_activities 1": false,
+	}
+
+	for cmd, want := range tests {
+		if got := device.IsReadOnlyCommand(cmd); got != want {
+			t.Errorf("IsReadOnlyCommand(%q) = %v, expected %v", cmd, got, want)
+		}
+	}
+}
+
+// TestSupervisorNotDisconnected tests that errors naming the command, e.g.
+// a cancelled command or a shell error, do not cause a reconnect
+func TestSupervisorNotDisconnected(t *testing.T) {
+	fake := newFakeTransport()
+	fake.handle("cat /sdcard/closed.txt", func(string) (string, error) {
+		return "", fmt.Errorf("cat /sdcard/closed.txt: %w", context.Canceled)
+	})
+	fake.handle("cat /sdcard/missing", func(string) (string, error) {
+		return "", errors.New("cat: /sdcard/missing: No such file, command not found, EOF")
+	})
+
+	var resolved int
+	resolve := func() (device.Transport, error) {
+		resolved++
+		return fake, nil
+	}
+	d, err := device.NewTestSupervisedDevice("fake", resolve, device.WithReconnect(3, time.Millisecond))
+	if err != nil {
+		t.Fatalf("Failed to create device: %v", err)
+	}
+
+	if _, err := d.RunShellCommand("cat /sdcard/closed.txt"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the cancellation, got %v", err)
+	}
+	if _, err := d.RunShellCommand("cat /sdcard/missing"); err == nil || errors.Is(err, device.ErrDeviceReconnecting) {
+		t.Errorf("Expected the shell error, got %v", err)
+	}
+	if resolved != 1 || d.ConnectionStatus().Reconnects != 0 {
+		t.Errorf("Expected no reconnect, resolved %d times", resolved)
+	}
+}
+
+// TestSupervisorReconnectCancelled tests that the backoff between reconnect
+// attempts ends with the context of the caller
+func TestSupervisorReconnectCancelled(t *testing.T) {
+	offline := newFakeTransport()
+	offline.handle("", func(string) (string, error) {
+		return "", fmt.Errorf("command failed: device offline")
+	})
+	resolve := func() (device.Transport, error) {
+		return offline, nil
+	}
+	d, err := device.NewTestSupervisedDevice("fake", resolve, device.WithReconnect(3, time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to create device: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := d.WithContext(ctx).Exec("getprop ro.product.model", device.ExecOptions{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the deadline of the caller, got %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for d.ConnectionStatus().State != device.StateDisconnected {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the reconnect to stop, got %+v", d.ConnectionStatus())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
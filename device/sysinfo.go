@@ -0,0 +1,605 @@
+package device
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	// e.g. "[ro.product.model]: [Pixel 7]"
+	getpropRegexp = regexp.MustCompile(`(?m)^\[(.+?)\]: \[(.*)\]\s*$`)
+
+	// Fields of `dumpsys battery`
+	batteryHealthRegexp      = regexp.MustCompile(`(?m)^\s*health: (\d+)`)
+	batteryTemperatureRegexp = regexp.MustCompile(`(?m)^\s*temperature: (-?\d+)`)
+	batteryVoltageRegexp     = regexp.MustCompile(`(?m)^\s*voltage: (\d+)`)
+
+	// e.g. `WifiInfo: SSID: "Office", BSSID: 02:00:00:00:00:00, ..., RSSI: -55, ...`
+	// The SSID is unquoted when it is not valid UTF-8
+	wifiInfoRegexp = regexp.MustCompile(`(?m)^.*(?:mWifiInfo|WifiInfo:) SSID: (".*?"|[^,]*), BSSID.*$`)
+	wifiRSSIRegexp = regexp.MustCompile(`RSSI: (-?\d+)`)
+
+	// e.g. "MemTotal:        7756348 kB"
+	meminfoRegexp = regexp.MustCompile(`(?m)^(\w+):\s+(\d+) kB`)
+
+	// e.g. "12.0G" in the output of the toolbox df before Android 7
+	humanSizeRegexp = regexp.MustCompile(`^([\d.]+)([KMGT]?)$`)
+
+	// e.g. "GLES: Qualcomm, Adreno (TM) 640, OpenGL ES 3.2 V@0502.0"
+	glesRegexp = regexp.MustCompile(`GLES: ([^,]+), ([^,]+), (.+)`)
+
+	// e.g. "0x0000000b) BMI160 Accelerometer | Bosch | ver: 1 | type: android.sensor.accelerometer(1) | ..."
+	sensorRegexp = regexp.MustCompile(`(?m)^\s*0x[0-9a-fA-F]+\) (.+?)\s*\| (.+?)\s*\| ver: \d+ \| type: ([\w.]+)\(\d+\)`)
+
+	// e.g. "Result: Parcel(0x00000000: 00000000 0000000f 00350033 ... '........3.5.')"
+	parcelTextRegexp = regexp.MustCompile(`'(.*?)'`)
+)
+
+// System information groups accepted by SystemInfo.
+const (
+	InfoBuild    = "build"
+	InfoBattery  = "battery"
+	InfoDisplay  = "display"
+	InfoNetwork  = "network"
+	InfoLocation = "location"
+	InfoMemory   = "memory"
+	InfoStorage  = "storage"
+	InfoCPU      = "cpu"
+	InfoGPU      = "gpu"
+	InfoSensors  = "sensors"
+)
+
+// SystemInfo represents the system information of the device. Groups that
+// were not requested are omitted; groups that failed are reported in Errors.
+type SystemInfo struct {
+	Build    *BuildInfo        `json:"build,omitempty"`
+	Battery  *BatteryInfo      `json:"battery,omitempty"`
+	Display  *DisplayInfo      `json:"display,omitempty"`
+	Network  *NetworkInfo      `json:"network,omitempty"`
+	Location *LocationInfo     `json:"location,omitempty"`
+	Memory   *MemoryInfo       `json:"memory,omitempty"`
+	Storage  *StorageInfo      `json:"storage,omitempty"`
+	CPU      *CPUInfo          `json:"cpu,omitempty"`
+	GPU      *GPUInfo          `json:"gpu,omitempty"`
+	Sensors  []Sensor          `json:"sensors,omitempty"`
+	Errors   map[string]string `json:"errors,omitempty"` // Error per group or group.field
+}
+
+// BuildInfo describes the device and its software build.
+type BuildInfo struct {
+	Model          string `json:"model"`           // Device model
+	Brand          string `json:"brand"`           // Device brand
+	Manufacturer   string `json:"manufacturer"`    // Manufacturer
+	Device         string `json:"device"`          // Device code name
+	AndroidVersion string `json:"android_version"` // Android version
+	SDK            int    `json:"sdk"`             // SDK version
+	SecurityPatch  string `json:"security_patch"`  // Security patch level
+	BuildID        string `json:"build_id"`        // Build ID
+	Fingerprint    string `json:"fingerprint"`     // Build fingerprint
+	SerialNumber   string `json:"serial_number"`   // Serial number
+	IMEI           string `json:"imei,omitempty"`  // IMEI, only readable before Android 10
+}
+
+// BatteryInfo describes the battery of the device.
+type BatteryInfo struct {
+	Level       int     `json:"level"`        // Battery percentage
+	Status      string  `json:"status"`       // e.g. charging, discharging, full
+	PowerSource string  `json:"power_source"` // none, ac, usb or wireless
+	Health      int     `json:"health"`       // BatteryManager health code, 2 is good
+	Temperature float64 `json:"temperature"`  // Temperature in degrees Celsius
+	VoltageMV   int     `json:"voltage_mv"`   // Voltage in millivolts
+}
+
+// NetworkInfo describes the connectivity of the device including Wi-Fi details.
+type NetworkInfo struct {
+	NetworkStatus
+	WifiSSID   string `json:"wifi_ssid"`   // WiFi SSID
+	WifiSignal int    `json:"wifi_signal"` // WiFi signal strength (RSSI)
+}
+
+// LocationInfo describes the location service.
+type LocationInfo struct {
+	Enabled bool `json:"enabled"` // Location service enabled
+}
+
+// MemoryInfo describes the RAM of the device.
+type MemoryInfo struct {
+	TotalRAM     int64 `json:"total_ram"`     // Total RAM (bytes)
+	AvailableRAM int64 `json:"available_ram"` // Available RAM (bytes)
+	TotalSwap    int64 `json:"total_swap"`    // Total swap (bytes)
+	FreeSwap     int64 `json:"free_swap"`     // Free swap (bytes)
+}
+
+// StorageInfo describes the data partition of the device.
+type StorageInfo struct {
+	TotalStorage     int64 `json:"total_storage"`     // Total storage (bytes)
+	AvailableStorage int64 `json:"available_storage"` // Available storage (bytes)
+}
+
+// CPUInfo describes the processor of the device.
+type CPUInfo struct {
+	ABIs       []string `json:"abis"`         // Supported ABIs, preferred first
+	Cores      int      `json:"cores"`        // Number of cores
+	Platform   string   `json:"platform"`     // SoC platform, e.g. sm8150
+	MaxFreqKHz []int    `json:"max_freq_khz"` // Maximum frequency of each core
+}
+
+// GPUInfo describes the graphics processor of the device.
+type GPUInfo struct {
+	Vendor   string `json:"vendor"`   // e.g. Qualcomm
+	Renderer string `json:"renderer"` // e.g. Adreno (TM) 640
+	Version  string `json:"version"`  // OpenGL ES version string
+}
+
+// Sensor describes a hardware or virtual sensor.
+type Sensor struct {
+	Name   string `json:"name"`
+	Vendor string `json:"vendor"`
+	Type   string `json:"type"` // e.g. android.sensor.accelerometer
+}
+
+// infoProbe collects one group of system information.
+type infoProbe struct {
+	name    string
+	collect func(c *infoCollector) error
+}
+
+// infoProbes lists every group in the order they are collected.
+var infoProbes = []infoProbe{
+	{InfoBuild, (*infoCollector).build},
+	{InfoBattery, (*infoCollector).battery},
+	{InfoDisplay, (*infoCollector).display},
+	{InfoNetwork, (*infoCollector).network},
+	{InfoLocation, (*infoCollector).location},
+	{InfoMemory, (*infoCollector).memory},
+	{InfoStorage, (*infoCollector).storage},
+	{InfoCPU, (*infoCollector).cpu},
+	{InfoGPU, (*infoCollector).gpu},
+	{InfoSensors, (*infoCollector).sensors},
+}
+
+// SystemInfoGroups returns the names of every system information group.
+func SystemInfoGroups() []string {
+	names := make([]string, len(infoProbes))
+	for i, p := range infoProbes {
+		names[i] = p.name
+	}
+
+	return names
+}
+
+// infoCollector holds the state shared by the probes of one SystemInfo call.
+type infoCollector struct {
+	d     *AndroidDevice
+	info  *SystemInfo
+	props map[string]string
+	sdk   int
+}
+
+// SystemInfo returns the system information of the device. When groups is
+// empty every group is collected. A failing group does not fail the call,
+// its error is reported in SystemInfo.Errors instead.
+func (d *AndroidDevice) SystemInfo(groups ...string) (*SystemInfo, error) {
+	selected := make(map[string]bool, len(groups))
+	for _, g := range groups {
+		selected[strings.ToLower(strings.TrimSpace(g))] = true
+	}
+	for g := range selected {
+		if !validInfoGroup(g) {
+			return nil, fmt.Errorf("unknown system info group %q, expected one of %s", g, strings.Join(SystemInfoGroups(), ", "))
+		}
+	}
+
+	c := &infoCollector{d: d, info: &SystemInfo{}}
+
+	// Every group needs the SDK version to pick a parser
+	out, err := d.RunShellCommand("getprop")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get device properties: %w", err)
+	}
+	c.props = parseGetprop(out)
+	c.sdk, _ = strconv.Atoi(c.props["ro.build.version.sdk"])
+
+	for _, p := range infoProbes {
+		if len(selected) != 0 && !selected[p.name] {
+			continue
+		}
+
+		if err := p.collect(c); err != nil {
+			c.fail(p.name, err)
+		}
+	}
+
+	return c.info, nil
+}
+
+// fail records the error of a group or field.
+func (c *infoCollector) fail(field string, err error) {
+	if c.info.Errors == nil {
+		c.info.Errors = make(map[string]string)
+	}
+
+	c.info.Errors[field] = err.Error()
+}
+
+// build collects the device and build properties.
+func (c *infoCollector) build() error {
+	b := &BuildInfo{
+		Model:          c.props["ro.product.model"],
+		Brand:          c.props["ro.product.brand"],
+		Manufacturer:   c.props["ro.product.manufacturer"],
+		Device:         c.props["ro.product.device"],
+		AndroidVersion: c.props["ro.build.version.release"],
+		SDK:            c.sdk,
+		SecurityPatch:  c.props["ro.build.version.security_patch"],
+		BuildID:        c.props["ro.build.id"],
+		Fingerprint:    c.props["ro.build.fingerprint"],
+		SerialNumber:   c.props["ro.serialno"],
+	}
+	c.info.Build = b
+
+	if b.SerialNumber == "" {
+		b.SerialNumber = c.props["ro.boot.serialno"]
+	}
+
+	// Android 10 restricts device identifiers to privileged apps
+	if c.sdk >= 29 {
+		return nil
+	}
+
+	// IPhoneSubInfo.getDeviceId
+	out, err := c.d.RunShellCommand("service call iphonesubinfo 1")
+	if err != nil {
+		c.fail("build.imei", err)
+		return nil
+	}
+	if strings.Contains(out, "Exception") {
+		c.fail("build.imei", fmt.Errorf("%s", firstLine(out)))
+		return nil
+	}
+
+	b.IMEI = parseParcelString(out)
+	return nil
+}
+
+// battery collects the battery state.
+func (c *infoCollector) battery() error {
+	out, err := c.d.RunShellCommand("dumpsys battery")
+	if err != nil {
+		return err
+	}
+
+	match := batteryLevelRegexp.FindStringSubmatch(out)
+	if match == nil {
+		return fmt.Errorf("failed to parse battery: %s", firstLine(out))
+	}
+
+	b := &BatteryInfo{Status: batteryStatuses[1], PowerSource: PowerSourceNone}
+	b.Level, _ = strconv.Atoi(match[1])
+
+	if match := batteryStatusRegexp.FindStringSubmatch(out); match != nil {
+		code, _ := strconv.Atoi(match[1])
+		if name, ok := batteryStatuses[code]; ok {
+			b.Status = name
+		}
+	}
+	if match := batteryPluggedRegexp.FindStringSubmatch(out); match != nil {
+		b.PowerSource = strings.ToLower(match[1])
+	}
+	if match := batteryHealthRegexp.FindStringSubmatch(out); match != nil {
+		b.Health, _ = strconv.Atoi(match[1])
+	}
+	if match := batteryTemperatureRegexp.FindStringSubmatch(out); match != nil {
+		tenths, _ := strconv.Atoi(match[1])
+		b.Temperature = float64(tenths) / 10
+	}
+	if match := batteryVoltageRegexp.FindStringSubmatch(out); match != nil {
+		b.VoltageMV, _ = strconv.Atoi(match[1])
+	}
+
+	c.info.Battery = b
+	return nil
+}
+
+// display collects the display size, density and rotation.
+func (c *infoCollector) display() (err error) {
+	c.info.Display, err = c.d.Display()
+	return err
+}
+
+// network collects the connectivity state and the connected Wi-Fi network.
+func (c *infoCollector) network() error {
+	status, err := c.d.NetworkStatus()
+	if err != nil {
+		return err
+	}
+
+	n := &NetworkInfo{NetworkStatus: *status}
+	c.info.Network = n
+
+	if status.ActiveNetwork == nil || status.ActiveNetwork.Transport != "WIFI" {
+		return nil
+	}
+
+	// `cmd wifi status` is available from Android 11 and much smaller than `dumpsys wifi`
+	cmd := "dumpsys wifi"
+	if c.sdk >= 30 {
+		cmd = "cmd wifi status"
+	}
+
+	out, err := c.d.RunShellCommand(cmd)
+	if err != nil {
+		c.fail("network.wifi", err)
+		return nil
+	}
+
+	line := wifiInfoRegexp.FindStringSubmatch(out)
+	if line == nil {
+		c.fail("network.wifi", fmt.Errorf("no connected Wi-Fi network in %s", cmd))
+		return nil
+	}
+
+	n.WifiSSID = strings.Trim(line[1], `"`)
+	if match := wifiRSSIRegexp.FindStringSubmatch(line[0]); match != nil {
+		n.WifiSignal, _ = strconv.Atoi(match[1])
+	}
+
+	return nil
+}
+
+// location collects the state of the location service.
+func (c *infoCollector) location() error {
+	var enabled bool
+
+	switch {
+	case c.sdk >= 30:
+		out, err := c.d.RunShellCommand("cmd location is-location-enabled")
+		if err != nil {
+			return err
+		}
+		enabled = strings.TrimSpace(out) == "true"
+	case c.sdk >= 19:
+		// LOCATION_MODE_OFF is 0
+		mode, err := c.d.GetSetting(SettingsSecure, "location_mode")
+		if err != nil {
+			return err
+		}
+		enabled = mode != "0" && mode != settingNull
+	default:
+		providers, err := c.d.GetSetting(SettingsSecure, "location_providers_allowed")
+		if err != nil {
+			return err
+		}
+		enabled = providers != "" && providers != settingNull
+	}
+
+	c.info.Location = &LocationInfo{Enabled: enabled}
+	return nil
+}
+
+// memory collects RAM and swap usage.
+func (c *infoCollector) memory() error {
+	out, err := c.d.RunShellCommand("cat /proc/meminfo")
+	if err != nil {
+		return err
+	}
+
+	kb := make(map[string]int64)
+	for _, match := range meminfoRegexp.FindAllStringSubmatch(out, -1) {
+		kb[match[1]], _ = strconv.ParseInt(match[2], 10, 64)
+	}
+	if kb["MemTotal"] == 0 {
+		return fmt.Errorf("failed to parse /proc/meminfo")
+	}
+
+	// MemAvailable is missing before Linux 3.14
+	available, ok := kb["MemAvailable"]
+	if !ok {
+		available = kb["MemFree"] + kb["Cached"]
+	}
+
+	c.info.Memory = &MemoryInfo{
+		TotalRAM:     kb["MemTotal"] * 1024,
+		AvailableRAM: available * 1024,
+		TotalSwap:    kb["SwapTotal"] * 1024,
+		FreeSwap:     kb["SwapFree"] * 1024,
+	}
+	return nil
+}
+
+// storage collects the size of the data partition.
+func (c *infoCollector) storage() error {
+	// toybox df replaced toolbox df, which prints human readable sizes, in Android 7
+	cmd := "df -k /data"
+	if c.sdk < 24 {
+		cmd = "df /data"
+	}
+
+	out, err := c.d.RunShellCommand(cmd)
+	if err != nil {
+		return err
+	}
+
+	s, err := parseDf(out, c.sdk >= 24)
+	if err != nil {
+		return err
+	}
+
+	c.info.Storage = s
+	return nil
+}
+
+// cpu collects the processor ABIs, cores and frequencies.
+func (c *infoCollector) cpu() error {
+	cpu := &CPUInfo{Platform: c.props["ro.board.platform"]}
+	if cpu.Platform == "" {
+		cpu.Platform = c.props["ro.hardware"]
+	}
+
+	// ro.product.cpu.abilist replaced abi/abi2 in Android 5
+	if list := c.props["ro.product.cpu.abilist"]; list != "" {
+		cpu.ABIs = strings.Split(list, ",")
+	} else {
+		for _, prop := range []string{"ro.product.cpu.abi", "ro.product.cpu.abi2"} {
+			if abi := c.props[prop]; abi != "" {
+				cpu.ABIs = append(cpu.ABIs, abi)
+			}
+		}
+	}
+	c.info.CPU = cpu
+
+	out, err := c.d.RunShellCommand("cat /sys/devices/system/cpu/cpu[0-9]*/cpufreq/cpuinfo_max_freq 2>/dev/null; ls -d /sys/devices/system/cpu/cpu[0-9]*")
+	if err != nil {
+		return err
+	}
+
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "/sys/") {
+			cpu.Cores++
+		} else if freq, err := strconv.Atoi(line); err == nil {
+			cpu.MaxFreqKHz = append(cpu.MaxFreqKHz, freq)
+		}
+	}
+
+	return nil
+}
+
+// gpu collects the OpenGL ES renderer.
+func (c *infoCollector) gpu() error {
+	out, err := c.d.RunShellCommand("dumpsys SurfaceFlinger | grep GLES")
+	if err != nil {
+		return err
+	}
+
+	match := glesRegexp.FindStringSubmatch(out)
+	if match == nil {
+		// Fall back to the EGL driver name, e.g. "adreno" or "mali"
+		if egl := c.props["ro.hardware.egl"]; egl != "" {
+			c.info.GPU = &GPUInfo{Renderer: egl}
+			return nil
+		}
+		return fmt.Errorf("failed to parse GLES renderer")
+	}
+
+	c.info.GPU = &GPUInfo{
+		Vendor:   strings.TrimSpace(match[1]),
+		Renderer: strings.TrimSpace(match[2]),
+		Version:  strings.TrimSpace(match[3]),
+	}
+	return nil
+}
+
+// sensors collects the sensors registered with the sensor service.
+func (c *infoCollector) sensors() error {
+	out, err := c.d.RunShellCommand("dumpsys sensorservice")
+	if err != nil {
+		return err
+	}
+
+	sensors := []Sensor{}
+	seen := make(map[Sensor]bool)
+	for _, match := range sensorRegexp.FindAllStringSubmatch(out, -1) {
+		s := Sensor{Name: match[1], Vendor: match[2], Type: match[3]}
+		if !seen[s] {
+			seen[s] = true
+			sensors = append(sensors, s)
+		}
+	}
+
+	sort.Slice(sensors, func(i, j int) bool {
+		return sensors[i].Type < sensors[j].Type
+	})
+
+	c.info.Sensors = sensors
+	return nil
+}
+
+// validInfoGroup reports whether name is a system information group.
+func validInfoGroup(name string) bool {
+	for _, p := range infoProbes {
+		if p.name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseGetprop parses the output of `getprop`.
+func parseGetprop(out string) map[string]string {
+	props := make(map[string]string)
+	for _, match := range getpropRegexp.FindAllStringSubmatch(out, -1) {
+		props[match[1]] = match[2]
+	}
+
+	return props
+}
+
+// parseParcelString extracts a string16 from the output of `service call`.
+func parseParcelString(out string) string {
+	var s strings.Builder
+	for _, match := range parcelTextRegexp.FindAllStringSubmatch(out, -1) {
+		s.WriteString(match[1])
+	}
+
+	return strings.Trim(strings.ReplaceAll(s.String(), ".", ""), " ")
+}
+
+// parseDf parses the data partition line of `df`. Sizes are in 1K blocks
+// for toybox, or human readable for the older toolbox.
+func parseDf(out string, blocks bool) (*StorageInfo, error) {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) < 2 {
+		return nil, fmt.Errorf("failed to parse df: %s", firstLine(out))
+	}
+
+	// toybox: Filesystem 1K-blocks Used Available Use% Mounted on
+	// toolbox: Filesystem Size Used Free Blksize
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("failed to parse df: %s", lines[len(lines)-1])
+	}
+
+	if blocks {
+		total, err1 := strconv.ParseInt(fields[1], 10, 64)
+		available, err2 := strconv.ParseInt(fields[3], 10, 64)
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("failed to parse df: %s", lines[len(lines)-1])
+		}
+		return &StorageInfo{TotalStorage: total * 1024, AvailableStorage: available * 1024}, nil
+	}
+
+	total, err1 := parseHumanSize(fields[1])
+	available, err2 := parseHumanSize(fields[3])
+	if err1 != nil || err2 != nil {
+		return nil, fmt.Errorf("failed to parse df: %s", lines[len(lines)-1])
+	}
+
+	return &StorageInfo{TotalStorage: total, AvailableStorage: available}, nil
+}
+
+// parseHumanSize parses sizes like "12.0G" into bytes.
+func parseHumanSize(s string) (int64, error) {
+	match := humanSizeRegexp.FindStringSubmatch(s)
+	if match == nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+
+	v, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, err
+	}
+
+	unit := map[string]float64{"": 1, "K": 1 << 10, "M": 1 << 20, "G": 1 << 30, "T": 1 << 40}[match[2]]
+	return int64(v * unit), nil
+}
+
+// firstLine returns the first line of s for error messages.
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	return line
+}
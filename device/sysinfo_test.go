@@ -0,0 +1,129 @@
+package device_test
+
+import (
+	"mcp-android-adb-server/device"
+	"reflect"
+	"testing"
+)
+
+const getpropOutput = `[ro.build.fingerprint]: [google/sdk_gphone/generic:9/PSR1.180720.122/6736742:userdebug/dev-keys]
+[ro.build.id]: [PSR1.180720.122]
+[ro.build.version.release]: [9]
+[ro.build.version.sdk]: [28]
+[ro.build.version.security_patch]: [2018-08-05]
+[ro.product.brand]: [google]
+[ro.product.cpu.abilist]: [x86_64,x86,arm64-v8a]
+[ro.product.manufacturer]: [Google]
+[ro.product.model]: [Android SDK built for x86]
+[ro.serialno]: [EMULATOR29X2X1X0]
+`
+
+const iphonesubinfo = `Result: Parcel(
+  0x00000000: 00000000 0000000f 00350033 00300038 '........3.5.8.0.'
+  0x00000010: 00300030 00390039 00310030 00330032 '0.0.9.9.0.1.2.3.'
+  0x00000020: 00350034 00000036                   '4.5.6...        ')
+`
+
+const meminfo = `MemTotal:        2043416 kB
+MemFree:          208420 kB
+Cached:           812300 kB
+SwapTotal:        524284 kB
+SwapFree:         400000 kB
+`
+
+const dumpsysSensorservice = `Sensor List:
+0x00000001) Goldfish 3-axis Accelerometer | The Android Open Source Project | ver: 1 | type: android.sensor.accelerometer(1) | perm: n/a
+0x00000002) Goldfish 3-axis Gyroscope     | The Android Open Source Project | ver: 1 | type: android.sensor.gyroscope(4) | perm: n/a
+Fusion States:
+0x00000001) Goldfish 3-axis Accelerometer | The Android Open Source Project | ver: 1 | type: android.sensor.accelerometer(1) | perm: n/a
+`
+
+// TestSystemInfoGroups tests collecting selected groups with Android 9 parsers
+func TestSystemInfoGroups(t *testing.T) {
+	fake := newFakeTransport()
+	fake.reply("getprop", getpropOutput)
+	fake.reply("service call iphonesubinfo 1", iphonesubinfo)
+	fake.reply("cat /proc/meminfo", meminfo)
+	fake.reply("df -k /data", "Filesystem     1K-blocks    Used Available Use% Mounted on\n/dev/block/dm-0   2031440 1233024    798416  61% /data\n")
+	fake.reply("settings get secure location_mode", "3\n")
+	fake.reply("dumpsys SurfaceFlinger", "GLES: Google (Intel), Android Emulator OpenGL ES Translator (Mesa), OpenGL ES 3.0 (4.5)\n")
+	fake.reply("dumpsys sensorservice", dumpsysSensorservice)
+	d := device.NewTestDevice("fake", fake)
+
+	info, err := d.SystemInfo("build", "memory", "storage", "location", "gpu", "sensors")
+	if err != nil {
+		t.Fatalf("Failed to get system information: %v", err)
+	}
+
+	expected := &device.SystemInfo{
+		Build: &device.BuildInfo{
+			Model:          "Android SDK built for x86",
+			Brand:          "google",
+			Manufacturer:   "Google",
+			AndroidVersion: "9",
+			SDK:            28,
+			SecurityPatch:  "2018-08-05",
+			BuildID:        "PSR1.180720.122",
+			Fingerprint:    "google/sdk_gphone/generic:9/PSR1.180720.122/6736742:userdebug/dev-keys",
+			SerialNumber:   "EMULATOR29X2X1X0",
+			IMEI:           "358000990123456",
+		},
+		Location: &device.LocationInfo{Enabled: true},
+		Memory: &device.MemoryInfo{
+			TotalRAM:     2043416 * 1024,
+			AvailableRAM: (208420 + 812300) * 1024,
+			TotalSwap:    524284 * 1024,
+			FreeSwap:     400000 * 1024,
+		},
+		Storage: &device.StorageInfo{TotalStorage: 2031440 * 1024, AvailableStorage: 798416 * 1024},
+		GPU: &device.GPUInfo{
+			Vendor:   "Google (Intel)",
+			Renderer: "Android Emulator OpenGL ES Translator (Mesa)",
+			Version:  "OpenGL ES 3.0 (4.5)",
+		},
+		Sensors: []device.Sensor{
+			{Name: "Goldfish 3-axis Accelerometer", Vendor: "The Android Open Source Project", Type: "android.sensor.accelerometer"},
+			{Name: "Goldfish 3-axis Gyroscope", Vendor: "The Android Open Source Project", Type: "android.sensor.gyroscope"},
+		},
+	}
+	if !reflect.DeepEqual(info, expected) {
+		t.Errorf("System information does not match expected:\nexpected %+v\ngot      %+v", expected, info)
+	}
+
+	if _, err := d.SystemInfo("bogus"); err == nil {
+		t.Error("Should return error for unknown group")
+	}
+}
+
+// TestSystemInfoPartial tests that failing groups are reported without failing the call
+func TestSystemInfoPartial(t *testing.T) {
+	fake := newFakeTransport()
+	fake.reply("getprop", "[ro.build.version.sdk]: [30]\n[ro.product.model]: [Pixel 5]\n")
+	fake.reply("cmd location is-location-enabled", "false\n")
+	fake.reply("cat /proc/meminfo", "")
+	fake.reply("dumpsys connectivity", dumpsysConnectivity)
+	fake.reply("ip -o addr show", ipAddr)
+	fake.reply("cmd wifi status", "Wifi is enabled\nWifiInfo: SSID: \"Office, 2F\", BSSID: 02:00:00:00:00:00, MAC: 02:00:00:00:00:00, Security type: 2, Supplicant state: COMPLETED, Wi-Fi standard: 5, RSSI: -58, Link speed: 433Mbps\n")
+	d := device.NewTestDevice("fake", fake)
+
+	info, err := d.SystemInfo("build", "network", "location", "memory")
+	if err != nil {
+		t.Fatalf("Failed to get system information: %v", err)
+	}
+
+	if info.Build.Model != "Pixel 5" || info.Build.IMEI != "" {
+		t.Errorf("Unexpected build information: %+v", info.Build)
+	}
+	if info.Location.Enabled {
+		t.Error("Location should be disabled")
+	}
+	if info.Network.WifiSSID != "Office, 2F" || info.Network.WifiSignal != -58 {
+		t.Errorf("Unexpected Wi-Fi information: %q %d", info.Network.WifiSSID, info.Network.WifiSignal)
+	}
+	if info.Memory != nil || info.Errors["memory"] == "" {
+		t.Errorf("Memory should fail with an error, got %+v %v", info.Memory, info.Errors)
+	}
+	if info.Battery != nil {
+		t.Error("Battery should not be collected")
+	}
+}
@@ -0,0 +1,90 @@
+package device
+
+import (
+	"encoding/xml"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// e.g. "[0,210][1080,420]"
+var boundsRegexp = regexp.MustCompile(`^\[(-?\d+),(-?\d+)\]\[(-?\d+),(-?\d+)\]$`)
+
+// UINode is an element of the UI hierarchy dumped by uiautomator.
+type UINode struct {
+	Text        string   `xml:"text,attr"`
+	ResourceID  string   `xml:"resource-id,attr"`
+	Class       string   `xml:"class,attr"`
+	Package     string   `xml:"package,attr"`
+	ContentDesc string   `xml:"content-desc,attr"`
+	Clickable   bool     `xml:"clickable,attr"`
+	Bounds      string   `xml:"bounds,attr"`
+	Children    []UINode `xml:"node"`
+}
+
+// uiHierarchy is the root element of a uiautomator dump.
+type uiHierarchy struct {
+	Nodes []UINode `xml:"node"`
+}
+
+// Center returns the center of the node bounds.
+func (n UINode) Center() (int, int, error) {
+	match := boundsRegexp.FindStringSubmatch(n.Bounds)
+	if match == nil {
+		return 0, 0, fmt.Errorf("invalid node bounds %q", n.Bounds)
+	}
+
+	var v [4]int
+	for i := range v {
+		v[i], _ = strconv.Atoi(match[i+1])
+	}
+
+	return (v[0] + v[2]) / 2, (v[1] + v[3]) / 2, nil
+}
+
+// DumpUI dumps the UI hierarchy of the current screen.
+func (d *AndroidDevice) DumpUI() ([]UINode, error) {
+	remotePath := path.Join(TempPath, "window_dump.xml")
+
+	// Output format: "UI hierchary dumped to: /data/local/tmp/window_dump.xml"
+	out, err := d.shell("uiautomator dump", remotePath)
+	if err != nil {
+		return nil, fmt.Errorf("uiautomator dump: %w", err)
+	}
+	if !strings.Contains(out, "dumped to") {
+		return nil, fmt.Errorf("uiautomator dump: %s", strings.TrimSpace(out))
+	}
+
+	data, err := d.shell("cat", remotePath)
+	if err != nil {
+		return nil, fmt.Errorf("read ui dump: %w", err)
+	}
+	_, _ = d.shell("rm -f", remotePath)
+
+	return parseUIHierarchy(data)
+}
+
+// FindUINodes returns every node in the hierarchy matching fn, depth first.
+func FindUINodes(nodes []UINode, fn func(UINode) bool) []UINode {
+	var found []UINode
+	for _, n := range nodes {
+		if fn(n) {
+			found = append(found, n)
+		}
+		found = append(found, FindUINodes(n.Children, fn)...)
+	}
+
+	return found
+}
+
+// parseUIHierarchy parses the XML written by `uiautomator dump`.
+func parseUIHierarchy(data string) ([]UINode, error) {
+	var root uiHierarchy
+	if err := xml.Unmarshal([]byte(data), &root); err != nil {
+		return nil, fmt.Errorf("failed to parse ui dump: %w", err)
+	}
+
+	return root.Nodes, nil
+}
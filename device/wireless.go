@@ -0,0 +1,164 @@
+package device
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// DefaultTcpipPort is the port adbd listens on after AdbTcpip.
+const DefaultTcpipPort = 5555
+
+// IsNetworkSerial reports whether serial is the host:port of a device
+// connected over the network.
+func IsNetworkSerial(serial string) bool {
+	host, port, err := net.SplitHostPort(serial)
+	if err != nil || host == "" {
+		return false
+	}
+
+	_, err = strconv.Atoi(port)
+	return err == nil
+}
+
+// AdbConnect connects the ADB server to a device listening on addr, e.g.
+// 192.168.1.23:5555. The port defaults to 5555.
+func (d *AndroidDevice) AdbConnect(addr string) (string, error) {
+	addr, err := networkAddress(addr)
+	if err != nil {
+		return "", err
+	}
+
+	out, err := d.adbHostString("host:connect:" + addr)
+	if err != nil {
+		return "", fmt.Errorf("connect: %w", err)
+	}
+
+	// The ADB server reports connection failures in an OKAY reply
+	if !strings.HasPrefix(out, "connected to") && !strings.HasPrefix(out, "already connected to") {
+		return "", fmt.Errorf("connect: %s", out)
+	}
+
+	return out, nil
+}
+
+// AdbDisconnect disconnects the ADB server from a network device.
+func (d *AndroidDevice) AdbDisconnect(addr string) (string, error) {
+	addr, err := networkAddress(addr)
+	if err != nil {
+		return "", err
+	}
+
+	out, err := d.adbHostString("host:disconnect:" + addr)
+	if err != nil {
+		return "", fmt.Errorf("disconnect: %w", err)
+	}
+
+	return out, nil
+}
+
+// AdbPair pairs the ADB server with a device using the six digit code shown
+// in Developer options > Wireless debugging (Android 11+). The pairing
+// address differs from the address used by AdbConnect.
+func (d *AndroidDevice) AdbPair(addr, code string) (string, error) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return "", fmt.Errorf("invalid pairing address %q, expected host:port", addr)
+	}
+	if _, err := strconv.Atoi(code); err != nil || len(code) != 6 {
+		return "", fmt.Errorf("invalid pairing code %q, expected six digits", code)
+	}
+
+	out, err := d.adbHostString(fmt.Sprintf("host:pair:%s:%s", code, addr))
+	if err != nil {
+		return "", fmt.Errorf("pair: %w", err)
+	}
+	if !strings.HasPrefix(out, "Successfully paired") {
+		return "", fmt.Errorf("pair: %s", out)
+	}
+
+	return out, nil
+}
+
+// AdbTcpip restarts adbd on the device listening on port and returns the
+// address to pass to AdbConnect, based on the Wi-Fi address of the device.
+func (d *AndroidDevice) AdbTcpip(port int) (string, error) {
+	if port <= 0 || port > 65535 {
+		return "", fmt.Errorf("invalid port %d", port)
+	}
+
+	// The Wi-Fi address is not reachable once adbd restarts over USB
+	ipOutput, err := d.RunShellCommand("ip -o -4 addr show wlan0")
+	if err != nil {
+		return "", fmt.Errorf("tcpip: %w", err)
+	}
+
+	c, err := d.adbService(fmt.Sprintf("tcpip:%d", port))
+	if err != nil {
+		return "", fmt.Errorf("tcpip: %w", err)
+	}
+	out, _ := io.ReadAll(c.conn)
+	c.Close()
+
+	if !strings.Contains(string(out), "restarting") {
+		return "", fmt.Errorf("tcpip: %s", strings.TrimSpace(string(out)))
+	}
+
+	for _, iface := range parseIPAddr(ipOutput) {
+		for _, addr := range iface.Addresses {
+			ip, _, _ := strings.Cut(addr, "/")
+			return net.JoinHostPort(ip, strconv.Itoa(port)), nil
+		}
+	}
+
+	return "", fmt.Errorf("tcpip: adbd listens on port %d but the device has no Wi-Fi address", port)
+}
+
+// adbHostString runs a host request answered with a string.
+func (d *AndroidDevice) adbHostString(request string) (string, error) {
+	c, err := d.adbHost(request)
+	if err != nil {
+		return "", err
+	}
+	defer c.Close()
+
+	out, err := c.readString()
+	if err != nil {
+		return "", fmt.Errorf("adb server: %w", err)
+	}
+
+	return strings.TrimSpace(out), nil
+}
+
+// networkAddress validates a network device address, adding the default port.
+func networkAddress(addr string) (string, error) {
+	addr = strings.TrimSpace(addr)
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, strconv.Itoa(DefaultTcpipPort))
+	}
+
+	if !IsNetworkSerial(addr) {
+		return "", fmt.Errorf("invalid address %q, expected host[:port]", addr)
+	}
+
+	return addr, nil
+}
+
+// newWirelessDevice connects to a network device and creates an
+// AndroidDevice that connects again when the Wi-Fi link drops.
+func newWirelessDevice(id string, opts ...Option) (*AndroidDevice, error) {
+	d := newAndroidDevice(id, nil, opts...)
+
+	err := d.supervise(func() (transport, error) {
+		if _, err := d.AdbConnect(d.id); err != nil {
+			return nil, err
+		}
+		return resolveAdb(d.adbAddress, d.id)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
@@ -0,0 +1,114 @@
+package device_test
+
+import (
+	"mcp-android-adb-server/device"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const devicesList = "192.168.1.23:5555 device product:sdk_gphone model:Pixel_5 device:redfin transport_id:3\n"
+
+// TestWirelessReconnect tests reconnecting a network device when the link drops
+func TestWirelessReconnect(t *testing.T) {
+	adb := newFakeAdbServer(t)
+	adb.reply("host:connect:192.168.1.23:5555", "OKAY"+adbString("connected to 192.168.1.23:5555"))
+	adb.reply("host:devices-l", "OKAY"+adbString(devicesList))
+
+	var calls atomic.Int32
+	adb.handle("shell:getprop ro.product.model", func(string) (string, error) {
+		if calls.Add(1) == 1 {
+			return adbFail("device offline"), nil
+		}
+		return "OKAYPixel 5\n", nil
+	})
+
+	d, err := device.NewTestWirelessDevice("192.168.1.23:5555", device.WithAdbAddress(adb.addr()), device.WithReconnect(3, time.Millisecond))
+	if err != nil {
+		t.Fatalf("Failed to create wireless device: %v", err)
+	}
+
+	out, err := d.RunShellCommand("getprop ro.product.model")
+	if err != nil || strings.TrimSpace(out) != "Pixel 5" {
+		t.Fatalf("Expected the command to succeed after reconnecting, got %q %v", out, err)
+	}
+
+	var connects int
+	for _, request := range adb.history() {
+		if strings.HasPrefix(request, "host:connect:") {
+			connects++
+		}
+	}
+	if connects != 2 {
+		t.Errorf("Expected to connect twice, got %d: %q", connects, adb.history())
+	}
+}
+
+// TestAdbWireless tests the connect, pair, disconnect and tcpip requests
+func TestAdbWireless(t *testing.T) {
+	adb := newFakeAdbServer(t)
+	adb.reply("host:connect:10.0.0.9:5555", "OKAY"+adbString("failed to connect to '10.0.0.9:5555': Connection refused"))
+	adb.reply("host:connect:", "OKAY"+adbString("connected to 192.168.1.23:5555"))
+	adb.reply("host:disconnect:", "OKAY"+adbString("disconnected 192.168.1.23:5555"))
+	adb.reply("host:pair:123456:", "OKAY"+adbString("Successfully paired to 192.168.1.23:37099 [guid=adb-1234]"))
+	adb.reply("host:pair:", "OKAY"+adbString("Failed: Wrong password or connection was dropped."))
+	adb.reply("tcpip:5555", "OKAYrestarting in TCP mode port: 5555\n")
+
+	fake := newFakeTransport()
+	fake.reply("ip -o -4 addr show wlan0", "21: wlan0    inet 192.168.1.23/24 brd 192.168.1.255 scope global wlan0\\       valid_lft forever preferred_lft forever\n")
+	d := device.NewTestDevice("fake", fake, device.WithAdbAddress(adb.addr()))
+
+	if _, err := d.AdbConnect("192.168.1.23"); err != nil {
+		t.Errorf("Failed to connect: %v", err)
+	}
+	if _, err := d.AdbConnect("10.0.0.9"); err == nil || !strings.Contains(err.Error(), "Connection refused") {
+		t.Errorf("Expected connection failure, got %v", err)
+	}
+	if _, err := d.AdbPair("192.168.1.23:37099", "123456"); err != nil {
+		t.Errorf("Failed to pair: %v", err)
+	}
+	if _, err := d.AdbPair("192.168.1.23:37099", "654321"); err == nil {
+		t.Error("Should fail with a wrong pairing code")
+	}
+	if _, err := d.AdbPair("192.168.1.23:37099", "12ab"); err == nil {
+		t.Error("Should reject a malformed pairing code")
+	}
+	if _, err := d.AdbDisconnect("192.168.1.23:5555"); err != nil {
+		t.Errorf("Failed to disconnect: %v", err)
+	}
+
+	addr, err := d.AdbTcpip(device.DefaultTcpipPort)
+	if err != nil || addr != "192.168.1.23:5555" {
+		t.Errorf("Unexpected tcpip result: %q %v", addr, err)
+	}
+
+	expected := []string{
+		"host:connect:192.168.1.23:5555",
+		"host:connect:10.0.0.9:5555",
+		"host:pair:123456:192.168.1.23:37099",
+		"host:pair:654321:192.168.1.23:37099",
+		"host:disconnect:192.168.1.23:5555",
+		"host:transport:fake",
+		"tcpip:5555",
+	}
+	if history := adb.history(); !reflect.DeepEqual(history, expected) {
+		t.Errorf("Requests do not match expected:\nexpected %q\ngot      %q", expected, history)
+	}
+}
+
+// TestIsNetworkSerial tests detecting network serials
+func TestIsNetworkSerial(t *testing.T) {
+	for serial, expected := range map[string]bool{
+		"192.168.1.23:5555":              true,
+		"[fe80::1]:5555":                 true,
+		"emulator-5554":                  false,
+		"E6EDU20723063683":               false,
+		"adb-1234._adb-tls-connect._tcp": false,
+	} {
+		if device.IsNetworkSerial(serial) != expected {
+			t.Errorf("IsNetworkSerial(%q) should be %v", serial, expected)
+		}
+	}
+}
@@ -0,0 +1,324 @@
+// Package emulator controls Android emulators through the emulator console,
+// the telnet interface listening on the even port of each emulator serial.
+package emulator
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout bounds each console command.
+var DefaultTimeout = 10 * time.Second
+
+// AuthTokenFile is the name of the file holding the console auth token in
+// the home directory of the user running the emulator.
+const AuthTokenFile = ".emulator_console_auth_token"
+
+// e.g. "emulator-5554"
+var serialRegexp = regexp.MustCompile(`^emulator-(\d+)$`)
+
+// Network speed profiles accepted by SetNetworkSpeed.
+var NetworkSpeeds = []string{"gsm", "hscsd", "gprs", "edge", "umts", "hsdpa", "lte", "evdo", "full"}
+
+// Network latency profiles accepted by SetNetworkDelay.
+var NetworkDelays = []string{"gsm", "edge", "umts", "none"}
+
+// Call actions accepted by Call.
+var CallActions = []string{"call", "accept", "busy", "cancel", "hold"}
+
+// Battery states accepted by SetBattery.
+var BatteryStatuses = []string{"unknown", "charging", "discharging", "not-charging", "full"}
+
+// Snapshot actions accepted by Snapshot.
+var SnapshotActions = []string{"save", "load", "delete", "list"}
+
+// Console is a connection to the emulator console.
+type Console struct {
+	conn    net.Conn
+	r       *bufio.Reader
+	timeout time.Duration
+}
+
+// Port returns the console port of an emulator serial.
+func Port(serial string) (int, bool) {
+	match := serialRegexp.FindStringSubmatch(serial)
+	if match == nil {
+		return 0, false
+	}
+
+	port, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0, false
+	}
+
+	return port, true
+}
+
+// IsEmulator reports whether serial belongs to a local emulator.
+func IsEmulator(serial string) bool {
+	_, ok := Port(serial)
+	return ok
+}
+
+// AuthToken reads the console auth token of the current user. An empty
+// token means the console does not require authentication.
+func AuthToken() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(filepath.Join(home, AuthTokenFile))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read console auth token: %w", err)
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// DialSerial connects to the console of a local emulator by its serial,
+// authenticating with the token of the current user.
+func DialSerial(serial string) (*Console, error) {
+	port, ok := Port(serial)
+	if !ok {
+		return nil, fmt.Errorf("%q is not an emulator serial", serial)
+	}
+
+	token, err := AuthToken()
+	if err != nil {
+		return nil, err
+	}
+
+	return Dial(net.JoinHostPort("127.0.0.1", strconv.Itoa(port)), token)
+}
+
+// Dial connects to the console at addr and authenticates with token when
+// the console asks for it.
+func Dial(addr, token string) (*Console, error) {
+	conn, err := net.DialTimeout("tcp", addr, DefaultTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to emulator console: %w", err)
+	}
+
+	c := &Console{conn: conn, r: bufio.NewReader(conn), timeout: DefaultTimeout}
+
+	banner, err := c.readReply()
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("failed to read emulator console banner: %w", err)
+	}
+
+	if strings.Contains(banner, "Authentication required") {
+		if token == "" {
+			c.Close()
+			return nil, fmt.Errorf("emulator console requires the auth token in ~/%s", AuthTokenFile)
+		}
+		if _, err := c.Command("auth " + token); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("failed to authenticate with emulator console: %w", err)
+		}
+	}
+
+	return c, nil
+}
+
+// Close closes the console connection.
+func (c *Console) Close() error {
+	return c.conn.Close()
+}
+
+// Command runs a console command and returns its output without the
+// trailing OK. A KO reply is returned as an error.
+func (c *Console) Command(cmd string) (string, error) {
+	if strings.ContainsAny(cmd, "\r\n") {
+		return "", fmt.Errorf("console command must be a single line")
+	}
+
+	_ = c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	if _, err := fmt.Fprintf(c.conn, "%s\r\n", cmd); err != nil {
+		return "", fmt.Errorf("failed to send %q: %w", cmd, err)
+	}
+
+	return c.readReply()
+}
+
+// readReply reads lines until the OK or KO line terminating a reply.
+func (c *Console) readReply() (string, error) {
+	_ = c.conn.SetReadDeadline(time.Now().Add(c.timeout))
+
+	var lines []string
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case line == "OK":
+			return strings.Join(lines, "\n"), nil
+		case strings.HasPrefix(line, "KO"):
+			return "", fmt.Errorf("%s", strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(line, "KO"), ":")))
+		}
+		lines = append(lines, line)
+	}
+}
+
+// GeoFix sets the GPS location. Altitude is in meters.
+func (c *Console) GeoFix(latitude, longitude, altitude float64) error {
+	if latitude < -90 || latitude > 90 || longitude < -180 || longitude > 180 {
+		return fmt.Errorf("invalid coordinates %g,%g", latitude, longitude)
+	}
+
+	// The console takes the longitude first
+	_, err := c.Command(fmt.Sprintf("geo fix %s %s %s", formatFloat(longitude), formatFloat(latitude), formatFloat(altitude)))
+	return err
+}
+
+// SendSMS delivers an incoming SMS from the given number.
+func (c *Console) SendSMS(from, text string) error {
+	if from == "" || text == "" {
+		return fmt.Errorf("sender and text are required")
+	}
+
+	// Line breaks would end the command, the console keeps escaped ones
+	text = strings.NewReplacer("\r", "", "\n", `\n`).Replace(text)
+	_, err := c.Command(fmt.Sprintf("sms send %s %s", from, text))
+	return err
+}
+
+// Call simulates an incoming call or acts on an existing one.
+func (c *Console) Call(action, number string) error {
+	if !contains(CallActions, action) {
+		return fmt.Errorf("invalid call action %q, expected one of %s", action, strings.Join(CallActions, ", "))
+	}
+	if number == "" && action != "hold" {
+		return fmt.Errorf("phone number is required")
+	}
+
+	_, err := c.Command(strings.TrimSpace("gsm " + action + " " + number))
+	return err
+}
+
+// SetNetworkSpeed applies a network speed profile.
+func (c *Console) SetNetworkSpeed(profile string) error {
+	if !contains(NetworkSpeeds, profile) {
+		return fmt.Errorf("invalid network speed %q, expected one of %s", profile, strings.Join(NetworkSpeeds, ", "))
+	}
+
+	_, err := c.Command("network speed " + profile)
+	return err
+}
+
+// SetNetworkDelay applies a network latency profile.
+func (c *Console) SetNetworkDelay(profile string) error {
+	if !contains(NetworkDelays, profile) {
+		return fmt.Errorf("invalid network delay %q, expected one of %s", profile, strings.Join(NetworkDelays, ", "))
+	}
+
+	_, err := c.Command("network delay " + profile)
+	return err
+}
+
+// Sensors returns the names of the sensors that can be set.
+func (c *Console) Sensors() ([]string, error) {
+	out, err := c.Command("sensor status")
+	if err != nil {
+		return nil, err
+	}
+
+	// e.g. "acceleration: enabled."
+	var names []string
+	for _, line := range strings.Split(out, "\n") {
+		if name, _, ok := strings.Cut(line, ":"); ok && !strings.Contains(name, " ") {
+			names = append(names, strings.TrimSpace(name))
+		}
+	}
+
+	return names, nil
+}
+
+// SetSensor sets the values of a sensor, e.g. acceleration 0 9.8 0.
+func (c *Console) SetSensor(name string, values ...float64) error {
+	if name == "" || len(values) == 0 || len(values) > 3 {
+		return fmt.Errorf("sensor name and one to three values are required")
+	}
+
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = formatFloat(v)
+	}
+
+	_, err := c.Command(fmt.Sprintf("sensor set %s %s", name, strings.Join(parts, ":")))
+	return err
+}
+
+// SetBattery sets the battery level, charger and status. A negative level
+// or empty status leaves the value unchanged.
+func (c *Console) SetBattery(level int, ac *bool, status string) error {
+	if level > 100 {
+		return fmt.Errorf("invalid battery level %d", level)
+	}
+	if status != "" && !contains(BatteryStatuses, status) {
+		return fmt.Errorf("invalid battery status %q, expected one of %s", status, strings.Join(BatteryStatuses, ", "))
+	}
+
+	var cmds []string
+	if level >= 0 {
+		cmds = append(cmds, fmt.Sprintf("power capacity %d", level))
+	}
+	if ac != nil {
+		cmds = append(cmds, "power ac "+map[bool]string{true: "on", false: "off"}[*ac])
+	}
+	if status != "" {
+		cmds = append(cmds, "power status "+status)
+	}
+
+	for _, cmd := range cmds {
+		if _, err := c.Command(cmd); err != nil {
+			return fmt.Errorf("%s: %w", cmd, err)
+		}
+	}
+
+	return nil
+}
+
+// Snapshot saves, loads, deletes or lists AVD snapshots. The output of the
+// command is returned, which is the snapshot table for list.
+func (c *Console) Snapshot(action, name string) (string, error) {
+	if !contains(SnapshotActions, action) {
+		return "", fmt.Errorf("invalid snapshot action %q, expected one of %s", action, strings.Join(SnapshotActions, ", "))
+	}
+	if action == "list" {
+		return c.Command("avd snapshot list")
+	}
+	if name == "" || strings.ContainsAny(name, " \t") {
+		return "", fmt.Errorf("invalid snapshot name %q", name)
+	}
+
+	return c.Command(fmt.Sprintf("avd snapshot %s %s", action, name))
+}
+
+// formatFloat formats v without trailing zeros.
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func contains(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
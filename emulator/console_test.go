@@ -0,0 +1,191 @@
+package emulator_test
+
+import (
+	"bufio"
+	"mcp-android-adb-server/emulator"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeConsole is a local server speaking the emulator console protocol.
+type fakeConsole struct {
+	ln       net.Listener
+	token    string
+	mu       sync.Mutex
+	commands []string
+}
+
+func newFakeConsole(t *testing.T, token string) *fakeConsole {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	f := &fakeConsole{ln: ln, token: token}
+	go f.serve()
+	return f
+}
+
+func (f *fakeConsole) addr() string {
+	return f.ln.Addr().String()
+}
+
+func (f *fakeConsole) history() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]string(nil), f.commands...)
+}
+
+func (f *fakeConsole) serve() {
+	for {
+		conn, err := f.ln.Accept()
+		if err != nil {
+			return
+		}
+		go f.handle(conn)
+	}
+}
+
+func (f *fakeConsole) handle(conn net.Conn) {
+	defer conn.Close()
+
+	authed := f.token == ""
+	if authed {
+		conn.Write([]byte("Android Console: type 'help' for a list of commands\r\nOK\r\n"))
+	} else {
+		conn.Write([]byte("Android Console: Authentication required\r\nAndroid Console: type 'auth <auth_token>' to authenticate\r\nOK\r\n"))
+	}
+
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.TrimRight(line, "\r\n")
+
+		if !authed {
+			if cmd != "auth "+f.token {
+				conn.Write([]byte("KO: authentication token does not match ~/.emulator_console_auth_token\r\n"))
+				continue
+			}
+			authed = true
+			conn.Write([]byte("Android Console: type 'help' for a list of commands\r\nOK\r\n"))
+			continue
+		}
+
+		f.mu.Lock()
+		f.commands = append(f.commands, cmd)
+		f.mu.Unlock()
+
+		switch {
+		case cmd == "sensor status":
+			conn.Write([]byte("acceleration: enabled.\r\nmagnetic-field: enabled.\r\nOK\r\n"))
+		case cmd == "avd snapshot list":
+			conn.Write([]byte("List of snapshots present on all disks:\r\nID        TAG                 VM SIZE                DATE       VM CLOCK\r\n--        default_boot           178M 2024-01-01 10:00:00   00:10:00.000\r\nOK\r\n"))
+		case strings.HasPrefix(cmd, "network speed bogus"):
+			conn.Write([]byte("KO: bad speed\r\n"))
+		default:
+			conn.Write([]byte("OK\r\n"))
+		}
+	}
+}
+
+// TestPort tests detecting emulator serials
+func TestPort(t *testing.T) {
+	if port, ok := emulator.Port("emulator-5556"); !ok || port != 5556 {
+		t.Errorf("Expected port 5556, got %d %v", port, ok)
+	}
+	if emulator.IsEmulator("E6EDU20723063683") || emulator.IsEmulator("192.168.1.2:5555") {
+		t.Error("Physical devices should not be detected as emulators")
+	}
+}
+
+// TestConsoleAuth tests authenticating with the console token
+func TestConsoleAuth(t *testing.T) {
+	f := newFakeConsole(t, "s3cret")
+
+	if _, err := emulator.Dial(f.addr(), ""); err == nil {
+		t.Error("Should fail without token")
+	}
+	if _, err := emulator.Dial(f.addr(), "wrong"); err == nil {
+		t.Error("Should fail with a wrong token")
+	}
+
+	c, err := emulator.Dial(f.addr(), "s3cret")
+	if err != nil {
+		t.Fatalf("Failed to dial console: %v", err)
+	}
+	defer c.Close()
+
+	if err := c.GeoFix(37.422, -122.084, 5); err != nil {
+		t.Fatalf("Failed to set location: %v", err)
+	}
+	if history := f.history(); !reflect.DeepEqual(history, []string{"geo fix -122.084 37.422 5"}) {
+		t.Errorf("Unexpected commands: %q", history)
+	}
+}
+
+// TestConsoleCommands tests the commands sent for each simulation
+func TestConsoleCommands(t *testing.T) {
+	f := newFakeConsole(t, "")
+
+	c, err := emulator.Dial(f.addr(), "")
+	if err != nil {
+		t.Fatalf("Failed to dial console: %v", err)
+	}
+	defer c.Close()
+
+	ac := true
+	steps := []func() error{
+		func() error { return c.SendSMS("5551234", "Your code is 1234\nThanks") },
+		func() error { return c.Call("call", "5551234") },
+		func() error { return c.SetNetworkSpeed("edge") },
+		func() error { return c.SetNetworkDelay("umts") },
+		func() error { return c.SetSensor("acceleration", 0, 9.81, 0.5) },
+		func() error { return c.SetBattery(42, &ac, "charging") },
+		func() error { _, err := c.Snapshot("save", "before_login"); return err },
+	}
+	for i, step := range steps {
+		if err := step(); err != nil {
+			t.Fatalf("Step %d failed: %v", i, err)
+		}
+	}
+
+	expected := []string{
+		`sms send 5551234 Your code is 1234\nThanks`,
+		"gsm call 5551234",
+		"network speed edge",
+		"network delay umts",
+		"sensor set acceleration 0:9.81:0.5",
+		"power capacity 42",
+		"power ac on",
+		"power status charging",
+		"avd snapshot save before_login",
+	}
+	if history := f.history(); !reflect.DeepEqual(history, expected) {
+		t.Errorf("Commands do not match expected:\nexpected %q\ngot      %q", expected, history)
+	}
+
+	sensors, err := c.Sensors()
+	if err != nil || !reflect.DeepEqual(sensors, []string{"acceleration", "magnetic-field"}) {
+		t.Errorf("Unexpected sensors: %q %v", sensors, err)
+	}
+
+	list, err := c.Snapshot("list", "")
+	if err != nil || !strings.Contains(list, "default_boot") {
+		t.Errorf("Unexpected snapshot list: %q %v", list, err)
+	}
+
+	if _, err := c.Command("network speed bogus"); err == nil || err.Error() != "bad speed" {
+		t.Errorf("Expected KO error, got %v", err)
+	}
+	if err := c.SetNetworkSpeed("5g"); err == nil {
+		t.Error("Should reject unknown speed profile")
+	}
+}
@@ -3,13 +3,15 @@ module mcp-android-adb-server
 go 1.23.4
 
 require (
+	github.com/BurntSushi/toml v1.6.0
 	github.com/electricbubble/gadb v0.1.0
 	github.com/mark3labs/mcp-go v0.17.0
+	github.com/sashabaranov/go-openai v1.38.1
+	gopkg.in/natefinch/lumberjack.v2 v2.2.1
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/google/uuid v1.6.0 // indirect
-	github.com/sashabaranov/go-openai v1.38.1 // indirect
 	github.com/yosida95/uritemplate/v3 v3.0.2 // indirect
-	gopkg.in/natefinch/lumberjack.v2 v2.2.1 // indirect
 )
@@ -1,179 +1,312 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"io"
 	"log/slog"
+	"mcp-android-adb-server/auth"
+	"mcp-android-adb-server/config"
 	"mcp-android-adb-server/device"
+	"mcp-android-adb-server/emulator"
+	"mcp-android-adb-server/serve"
 	"mcp-android-adb-server/tools"
 	"mcp-android-adb-server/vision"
+	"net/http"
 	"os"
+	"os/signal"
 	"path"
+	"strings"
+	"syscall"
+	"time"
 
-	"github.com/mark3labs/mcp-go/mcp"
 
 	"gopkg.in/natefinch/lumberjack.v2"
 
 	"github.com/mark3labs/mcp-go/server"
 )
 
-func init() {
-	baseDir := getBaseDir()
-	rotateWriter := &lumberjack.Logger{
-		Filename:   path.Join(baseDir, "mcp-android-adb-server.log"),
-		MaxSize:    10,
-		MaxBackups: 5,
-		MaxAge:     30,
-		Compress:   true,
-	}
+// version is the server version, set at build time with
+// -ldflags "-X main.version=..."
+var version = "1.0.0"
 
-	logger := slog.New(slog.NewJSONHandler(rotateWriter, &slog.HandlerOptions{
-		Level: slog.LevelDebug,
-	}))
+// command is a subcommand of the server
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}
 
-	slog.SetDefault(logger)
+// commands lists the subcommands, serve runs when none is given
+var commands = []command{
+	{"serve", "run the MCP server", runServe},
+	{"devices", "list the attached devices and their state", runDevices},
+	{"doctor", "check the ADB server, the device and the configuration", runDoctor},
+	{"tools", "print the schemas of the registered tools as JSON", runTools},
+	{"version", "print the version", runVersion},
 }
 
 func main() {
-	wd, _ := os.Getwd()
-	slog.Info("server start", "directory", wd)
+	args := os.Args[1:]
+	name := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+
+	if name == "help" {
+		usage(os.Stdout)
+		return
+	}
+
+	for _, c := range commands {
+		if c.name == name {
+			os.Exit(c.run(args))
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+	usage(os.Stderr)
+	os.Exit(2)
+}
+
+// usage prints the subcommands
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: %s <command> [flags]\n\nCommands:\n", path.Base(os.Args[0]))
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(w, "\nRun '%s <command> -h' for the flags of a command.\n", path.Base(os.Args[0]))
+}
+
+// newFlagSet returns the flags of a subcommand with the shared -config flag
+func newFlagSet(name string) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "path of a YAML, JSON or TOML configuration file")
+	return fs, configFile
+}
+
+// loadConfig loads the configuration, applies the flags overriding it and
+// reports errors on stderr
+func loadConfig(path string, overrides ...func(cfg *config.Config)) (*config.Config, bool) {
+	cfg, err := config.Load(path)
+	if err == nil && len(overrides) > 0 {
+		for _, override := range overrides {
+			override(cfg)
+		}
+		err = cfg.Validate()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		return nil, false
+	}
+	return cfg, true
+}
+
+// runServe runs the MCP server
+func runServe(args []string) int {
+	fs, configFile := newFlagSet("serve")
+	printConfig := fs.Bool("print-config", false, "print the effective configuration with secrets redacted and exit")
+	transport := fs.String("transport", "", "transport of the MCP server: "+strings.Join(config.Transports, ", ")+" (default from the configuration, stdio)")
+	addr := fs.String("addr", "", "listen address of the sse and http transports, e.g. :8080")
+	basePath := fs.String("base-path", "", "prefix of the endpoint paths of the sse and http transports, e.g. /android")
+	selectTools := toolFlags(fs)
+	_ = fs.Parse(args)
+
+	// Flags override the file and the environment
+	cfg, ok := loadConfig(*configFile, selectTools, func(cfg *config.Config) {
+		fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "transport":
+				cfg.Server.Transport = *transport
+			case "addr":
+				cfg.Server.Addr = *addr
+			case "base-path":
+				cfg.Server.BasePath = *basePath
+			}
+		})
+	})
+	if !ok {
+		return 1
+	}
 
-	deviceId := os.Getenv("DEVICE_ID")
-	screenLockPassword := os.Getenv("SCREEN_LOCK_PASSWORD")
+	if *printConfig {
+		if err := cfg.Write(os.Stdout, path.Ext(*configFile)); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
 
-	d, err := device.NewAndroidDevice(
-		deviceId,
-		device.WithScreenPassword(screenLockPassword),
-		device.WithScreenshotPath(path.Join(getBaseDir(), "screenshots")))
+	setupLogging(cfg.Log, cfg.LogLevel())
 
+	wd, _ := os.Getwd()
+	slog.Info("server start", "directory", wd, "config", *configFile, "transport", cfg.Server.Transport)
+
+	d, err := device.NewAndroidDevice(cfg.Device.ID, deviceOptions(cfg)...)
 	if err != nil {
 		slog.Error("error connect android device", "error", err)
-		return
+		return 1
 	}
+	defer func() {
+		if err := d.Close(); err != nil {
+			slog.Error("error restoring android device", "error", err)
+		}
+	}()
 
-	s := server.NewMCPServer(
-		"mcp-android-adb-server",
-		"1.0.0",
-		server.WithResourceCapabilities(true, true),
-		server.WithLogging(),
-		server.WithHooks(getHooks()),
-	)
+	s := newServer(cfg, d)
 
-	// Register all tools
-	registerTools(s, d)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	if err := server.ServeStdio(s); err != nil {
-		slog.Error("error serving stdio", "error", err)
+	opts := serve.HTTPOptions{
+		Addr:            cfg.Server.Addr,
+		BasePath:        cfg.Server.BasePath,
+		ShutdownTimeout: time.Duration(cfg.Server.ShutdownTimeout),
+		AllowedOrigins:  cfg.Server.AllowedOrigins,
 	}
-}
 
-// registerTools registers all Android device tools
-func registerTools(s *server.MCPServer, d *device.AndroidDevice) {
-	// Define all tool registration functions
-	toolList := []func(*server.MCPServer, *device.AndroidDevice){
-		tools.AddToolInstallApp,
-		tools.AddToolUninstallApp,
-		tools.AddToolTerminateApp,
-		tools.AddToolLaunchApp,
-		tools.AddToolListApp,
-		tools.AddToolInstalledApp,
-		tools.AddToolUnlockScreen,
-		tools.AddToolLockScreen,
-		tools.AddToolIsScreenLocked,
-		tools.AddToolIsScreenActive,
-		tools.AddToolInputText,
-		tools.AddToolInputKey,
-		tools.AddToolShellCommand,
-		tools.AddToolSwipeUp,
-		tools.AddToolSwipeDown,
-		tools.AddToolSwipeLeft,
-		tools.AddToolSwipeRight,
-		tools.AddToolScreenSize,
-		tools.AddToolScreenDpi,
-		//tools.AddToolScreenshot,
-		tools.AddToolTap,
-		tools.AddToolLongTap,
-		tools.AddToolBack,
-		tools.AddToolSystemInfo,
+	if cfg.Server.Transport != "stdio" {
+		if opts.TLS, opts.Auth, err = httpSecurity(cfg.Server); err != nil {
+			slog.Error("error configuring the HTTP transport", "error", err)
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if opts.Auth == nil {
+			slog.Warn("HTTP transport without authentication, anyone reaching the address can use the device", "address", opts.Addr)
+		}
 	}
 
-	// Register all tools
-	for _, registerTool := range toolList {
-		registerTool(s, d)
+	switch cfg.Server.Transport {
+	case "sse":
+		err = serve.SSE(ctx, s, opts)
+	case "http":
+		err = serve.StreamableHTTP(ctx, s, opts)
+	default:
+		err = serve.Stdio(ctx, s, os.Stdin, os.Stdout)
+	}
+	if err != nil && err != context.Canceled && err != http.ErrServerClosed {
+		slog.Error("error serving", "transport", cfg.Server.Transport, "error", err)
+		return 1
 	}
+	return 0
+}
 
-	// Register visual tools
-	visualModel := os.Getenv("VISUAL_MODEL_ON")
+// toolFlags declares the flags selecting the tools and returns the function
+// applying them to the configuration
+func toolFlags(fs *flag.FlagSet) func(cfg *config.Config) {
+	profile := fs.String("profile", "", "tool profile: "+strings.Join(tools.ProfileNames(), ", ")+" (default from the configuration, full)")
+	include := fs.String("include", "", "comma separated tools to register in addition to the profile")
+	exclude := fs.String("exclude", "", "comma separated tools not to register")
 
-	if visualModel == "true" {
-		visualModelApiKey := os.Getenv("VISUAL_MODEL_API_KEY")
-		visualModelBaseUrl := os.Getenv("VISUAL_MODEL_BASE_URL")
-		visualModelName := os.Getenv("VISUAL_MODEL_NAME")
-		m := vision.NewModel(visualModelApiKey, visualModelName, visualModelBaseUrl)
-		tools.AddToolScreenshotDescription(s, d, m)
+	return func(cfg *config.Config) {
+		if *profile != "" {
+			cfg.Tools.Profile = *profile
+		}
+		cfg.Tools.Include = append(cfg.Tools.Include, config.SplitList(*include)...)
+		cfg.Tools.Exclude = append(cfg.Tools.Exclude, config.SplitList(*exclude)...)
 	}
 }
 
-// getHooks returns all hooks
-func getHooks() *server.Hooks {
-	hooks := &server.Hooks{}
+// httpSecurity returns the TLS configuration and the authenticator of the
+// HTTP transports, nil when they are not configured
+func httpSecurity(c config.ServerConfig) (*tls.Config, *auth.Authenticator, error) {
+	var tlsConfig *tls.Config
+	if c.TLS.CertFile != "" {
+		var err error
+		if tlsConfig, err = serve.TLSConfig(c.TLS.CertFile, c.TLS.KeyFile, c.TLS.ClientCAFile); err != nil {
+			return nil, nil, err
+		}
+	}
 
-	hooks.AddBeforeAny(func(id any, method mcp.MCPMethod, message any) {
-		slog.Info("before any hook called",
-			"method", method,
-			"id", id,
-			"message", message)
-	})
+	if !c.AuthEnabled() {
+		return tlsConfig, nil, nil
+	}
+	authenticator, err := auth.New(c.Identities)
+	return tlsConfig, authenticator, err
+}
 
-	hooks.AddOnSuccess(func(id any, method mcp.MCPMethod, message any, result any) {
-		slog.Info("operation completed successfully",
-			"method", method,
-			"id", id,
-			"message", message,
-			"result", result)
-	})
+// deviceOptions returns the device options of the configuration
+func deviceOptions(cfg *config.Config) []device.Option {
+	return []device.Option{
+		device.WithScreenPassword(cfg.Device.ScreenLockPassword),
+		device.WithScreenshotPath(cfg.Output.Screenshots),
+		device.WithSwipeDuration(time.Duration(cfg.Device.SwipeDuration)),
+		device.WithLongTapDuration(time.Duration(cfg.Device.LongTapDuration)),
+		device.WithSleepDuration(time.Duration(cfg.Device.SleepDuration)),
+		device.WithReconnect(cfg.Device.ReconnectAttempts, time.Duration(cfg.Device.ReconnectBackoff)),
+	}
+}
 
-	hooks.AddOnError(func(id any, method mcp.MCPMethod, message any, err error) {
-		slog.Error("operation failed",
-			"method", method,
-			"id", id,
-			"message", message,
-			"error", err)
-	})
+// newServer creates the MCP server with the tools of the configuration
+func newServer(cfg *config.Config, d *device.AndroidDevice) *server.MCPServer {
+	timeouts := make(map[string]time.Duration, len(cfg.Tools.Timeouts))
+	for name, timeout := range cfg.Tools.Timeouts {
+		timeouts[name] = time.Duration(timeout)
+	}
+	tools.ConfigureTimeouts(time.Duration(cfg.Tools.Timeout), timeouts)
+	if err := tools.Select(cfg.Tools.Profile, cfg.Tools.Include, cfg.Tools.Exclude); err != nil {
+		slog.Error("error selecting tools", "error", err)
+	}
+	tools.Policy = &cfg.Policy
+	tools.ShellMaxOutput = cfg.Tools.ShellMaxOutput
 
-	hooks.AddBeforeInitialize(func(id any, message *mcp.InitializeRequest) {
-		slog.Info("initializing",
-			"id", id,
-			"message", message)
-	})
+	s := server.NewMCPServer(
+		"mcp-android-adb-server",
+		version,
+		server.WithResourceCapabilities(true, true),
+		server.WithLogging(),
+		server.WithHooks(tools.Hooks()),
+	)
 
-	hooks.AddAfterInitialize(func(id any, message *mcp.InitializeRequest, result *mcp.InitializeResult) {
-		slog.Info("initialization completed",
-			"id", id,
-			"message", message,
-			"result", result)
-	})
+	// Register all tools
+	registerTools(s, d, cfg.Vision)
 
-	hooks.AddAfterCallTool(func(id any, message *mcp.CallToolRequest, result *mcp.CallToolResult) {
-		slog.Info("tool call completed",
-			"id", id,
-			"message", message,
-			"result", result)
-	})
+	return s
+}
 
-	hooks.AddBeforeCallTool(func(id any, message *mcp.CallToolRequest) {
-		slog.Info("calling tool",
-			"id", id,
-			"message", message)
-	})
+// setupLogging logs to a rotated JSON file, and tool calls to the audit
+// file if it is set
+func setupLogging(c config.LogConfig, level slog.Level) {
+	rotated := func(file string, level slog.Level) *slog.Logger {
+		rotateWriter := &lumberjack.Logger{
+			Filename:   file,
+			MaxSize:    c.MaxSize,
+			MaxBackups: c.MaxBackups,
+			MaxAge:     c.MaxAge,
+			Compress:   c.Compress,
+		}
+
+		return slog.New(slog.NewJSONHandler(rotateWriter, &slog.HandlerOptions{
+			Level: level,
+		}))
+	}
 
-	return hooks
+	slog.SetDefault(rotated(c.File, level))
+
+	if c.AuditFile != "" {
+		tools.Audit = rotated(c.AuditFile, slog.LevelInfo)
+	}
+	tools.AuditRedact = append(tools.AuditRedact, c.AuditRedact...)
 }
 
-// getBaseDir returns the base directory
-func getBaseDir() string {
-	baseDir, _ := os.UserHomeDir()
-	if baseDir == "" {
-		baseDir = os.TempDir()
+// registerTools registers all Android device tools
+func registerTools(s *server.MCPServer, d *device.AndroidDevice, vc config.VisionConfig) {
+	for _, registerTool := range tools.DeviceTools {
+		registerTool(s, d)
 	}
 
-	return path.Join(baseDir, "mcp-android-adb-server")
+	// Register emulator console tools
+	if emulator.IsEmulator(d.Serial()) {
+		for _, registerTool := range tools.EmulatorTools {
+			registerTool(s, d)
+		}
+	}
+
+	// Register visual tools
+	if vc.Configured() {
+		m := vision.NewModel(vc.APIKey, vc.Model, vc.BaseURL)
+		tools.AddToolScreenshotDescription(s, d, m)
+	}
 }
@@ -0,0 +1,385 @@
+// Package perf samples the CPU, memory, frame and network usage of an
+// Android application over a time window.
+package perf
+
+import (
+	"fmt"
+	"math"
+	"mcp-android-adb-server/shellquote"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Network scopes reported in Summary.NetworkScope.
+const (
+	NetworkScopeApp    = "app"    // Bytes transferred by the package UID
+	NetworkScopeDevice = "device" // Bytes transferred by the whole device
+)
+
+// MaxDuration bounds a sampling session that is never stopped.
+var MaxDuration = 30 * time.Minute
+
+// Shell runs shell commands on a device.
+type Shell interface {
+	RunShellCommand(cmd string, args ...string) (string, error)
+}
+
+// Sample is a single measurement of a package.
+type Sample struct {
+	Time        time.Time `json:"time"`
+	CPU         float64   `json:"cpu"`          // CPU usage in percent of one core
+	PSSKB       int64     `json:"pss_kb"`       // Proportional set size in kB
+	FPS         float64   `json:"fps"`          // Frames rendered per second
+	Frames      int       `json:"frames"`       // Frames rendered since the previous sample
+	JankyFrames int       `json:"janky_frames"` // Janky frames since the previous sample
+	RxBytes     int64     `json:"rx_bytes"`     // Bytes received since the previous sample
+	TxBytes     int64     `json:"tx_bytes"`     // Bytes transmitted since the previous sample
+}
+
+// Stats summarizes a series of values.
+type Stats struct {
+	Min  float64 `json:"min"`
+	Max  float64 `json:"max"`
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P95  float64 `json:"p95"`
+}
+
+// Summary aggregates the samples of a session.
+type Summary struct {
+	CPU          Stats       `json:"cpu"`
+	PSSKB        Stats       `json:"pss_kb"`
+	FPS          Stats       `json:"fps"`
+	TotalFrames  int         `json:"total_frames"`
+	JankyFrames  int         `json:"janky_frames"`
+	JankPercent  float64     `json:"jank_percent"`
+	FrameTimeMs  map[int]int `json:"frame_time_ms"` // Frame time percentiles reported by gfxinfo
+	RxBytes      int64       `json:"rx_bytes"`
+	TxBytes      int64       `json:"tx_bytes"`
+	NetworkScope string      `json:"network_scope"` // app or device
+}
+
+// Report is the result of a sampling session.
+type Report struct {
+	Package  string    `json:"package"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Interval string    `json:"interval"`
+	Samples  []Sample  `json:"samples,omitempty"`
+	Summary  Summary   `json:"summary"`
+	Errors   []string  `json:"errors,omitempty"` // Distinct sampling errors
+}
+
+// Session samples a package in the background until stopped.
+type Session struct {
+	shell    Shell
+	pkg      string
+	uid      string
+	interval time.Duration
+
+	mu          sync.Mutex
+	start       time.Time
+	last        *counters
+	lastTime    time.Time
+	samples     []Sample
+	percentiles map[int]int
+	scope       string
+	errors      map[string]bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Start resets the frame statistics of a package and starts sampling it
+// every interval.
+func Start(shell Shell, pkg string, interval time.Duration) (*Session, error) {
+	if interval < 100*time.Millisecond {
+		return nil, fmt.Errorf("sampling interval %s is too short", interval)
+	}
+
+	ids, err := pids(shell, pkg)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("package %s is not running", pkg)
+	}
+
+	if _, err := shell.RunShellCommand(shellquote.Command("dumpsys gfxinfo", pkg, "reset")); err != nil {
+		return nil, fmt.Errorf("reset gfxinfo: %w", err)
+	}
+
+	s := &Session{
+		shell:    shell,
+		pkg:      pkg,
+		uid:      packageUID(shell, pkg),
+		interval: interval,
+		start:    time.Now(),
+		errors:   make(map[string]bool),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	s.sample()
+	go s.run()
+
+	return s, nil
+}
+
+// Stop stops sampling and returns the report.
+func (s *Session) Stop() *Report {
+	select {
+	case <-s.stop:
+	default:
+		close(s.stop)
+	}
+	<-s.done
+
+	return s.Report()
+}
+
+// Report returns the samples collected so far.
+func (s *Session) Report() *Report {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	end := s.lastTime
+	if end.IsZero() {
+		end = time.Now()
+	}
+
+	report := &Report{
+		Package:  s.pkg,
+		Start:    s.start,
+		End:      end,
+		Interval: s.interval.String(),
+		Samples:  append([]Sample(nil), s.samples...),
+		Summary:  summarize(s.samples),
+	}
+	report.Summary.FrameTimeMs = s.percentiles
+	report.Summary.NetworkScope = s.scope
+
+	for e := range s.errors {
+		report.Errors = append(report.Errors, e)
+	}
+	sort.Strings(report.Errors)
+
+	return report
+}
+
+// Monitor tracks one sampling session per package.
+type Monitor struct {
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+// NewMonitor creates a new Monitor instance.
+func NewMonitor() *Monitor {
+	return &Monitor{sessions: make(map[string]*Session)}
+}
+
+// Start starts sampling a package unless it is already being sampled.
+func (m *Monitor) Start(shell Shell, pkg string, interval time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.sessions[pkg]; ok {
+		return fmt.Errorf("package %s is already being sampled", pkg)
+	}
+
+	s, err := Start(shell, pkg, interval)
+	if err != nil {
+		return err
+	}
+
+	m.sessions[pkg] = s
+	return nil
+}
+
+// Stop stops sampling a package and returns the report.
+func (m *Monitor) Stop(pkg string) (*Report, error) {
+	m.mu.Lock()
+	s, ok := m.sessions[pkg]
+	delete(m.sessions, pkg)
+	m.mu.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("package %s is not being sampled", pkg)
+	}
+
+	return s.Stop(), nil
+}
+
+// StopAll stops all sampling sessions and discards their reports.
+func (m *Monitor) StopAll() {
+	m.mu.Lock()
+	sessions := m.sessions
+	m.sessions = make(map[string]*Session)
+	m.mu.Unlock()
+
+	for _, s := range sessions {
+		s.Stop()
+	}
+}
+
+// run samples until the session is stopped or MaxDuration elapses.
+func (s *Session) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	timeout := time.NewTimer(MaxDuration)
+	defer timeout.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-timeout.C:
+			return
+		case <-ticker.C:
+			s.sample()
+		}
+	}
+}
+
+// sample reads the counters of the package and records the difference to
+// the previous pass. The first pass only records the baseline.
+func (s *Session) sample() {
+	now := time.Now()
+	c := &counters{}
+	var pss int64
+
+	ids, err := pids(s.shell, s.pkg)
+	if err == nil {
+		err = readCPU(s.shell, ids, c)
+	}
+	s.record(err)
+
+	pss, err = readPSS(s.shell, s.pkg)
+	s.record(err)
+
+	percentiles, err := readFrames(s.shell, s.pkg, c)
+	s.record(err)
+
+	scope, err := readNetwork(s.shell, s.uid, c)
+	s.record(err)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if percentiles != nil {
+		s.percentiles = percentiles
+	}
+	if scope != "" {
+		s.scope = scope
+	}
+
+	prev, prevTime := s.last, s.lastTime
+	s.last, s.lastTime = c, now
+	if prev == nil {
+		return
+	}
+
+	sample := Sample{
+		Time:        now,
+		PSSKB:       pss,
+		Frames:      nonNegative(c.frames - prev.frames),
+		JankyFrames: nonNegative(c.jankyFrame - prev.jankyFrame),
+		RxBytes:     max(c.rxBytes-prev.rxBytes, 0),
+		TxBytes:     max(c.txBytes-prev.txBytes, 0),
+	}
+
+	if total := c.totalTicks - prev.totalTicks; total > 0 && c.cpus > 0 {
+		sample.CPU = float64(max(c.procTicks-prev.procTicks, 0)) / float64(total) * float64(c.cpus) * 100
+	}
+	if elapsed := now.Sub(prevTime).Seconds(); elapsed > 0 {
+		sample.FPS = float64(sample.Frames) / elapsed
+	}
+
+	s.samples = append(s.samples, sample)
+}
+
+// record keeps a distinct sampling error.
+func (s *Session) record(err error) {
+	if err == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.errors[err.Error()] = true
+}
+
+// nonNegative clamps counters that went backwards, e.g. after a process restart.
+func nonNegative(v int) int {
+	return max(v, 0)
+}
+
+// summarize aggregates samples.
+func summarize(samples []Sample) Summary {
+	var summary Summary
+	cpu := make([]float64, 0, len(samples))
+	pss := make([]float64, 0, len(samples))
+	fps := make([]float64, 0, len(samples))
+
+	for _, sample := range samples {
+		cpu = append(cpu, sample.CPU)
+		if sample.PSSKB > 0 {
+			pss = append(pss, float64(sample.PSSKB))
+		}
+		fps = append(fps, sample.FPS)
+		summary.TotalFrames += sample.Frames
+		summary.JankyFrames += sample.JankyFrames
+		summary.RxBytes += sample.RxBytes
+		summary.TxBytes += sample.TxBytes
+	}
+
+	summary.CPU = newStats(cpu)
+	summary.PSSKB = newStats(pss)
+	summary.FPS = newStats(fps)
+	if summary.TotalFrames > 0 {
+		summary.JankPercent = float64(summary.JankyFrames) / float64(summary.TotalFrames) * 100
+	}
+
+	return summary
+}
+
+// newStats computes the statistics of values using nearest-rank percentiles.
+func newStats(values []float64) Stats {
+	if len(values) == 0 {
+		return Stats{}
+	}
+
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	var sum float64
+	for _, v := range sorted {
+		sum += v
+	}
+
+	return Stats{
+		Min:  sorted[0],
+		Max:  sorted[len(sorted)-1],
+		Mean: sum / float64(len(sorted)),
+		P50:  Percentile(sorted, 50),
+		P90:  Percentile(sorted, 90),
+		P95:  Percentile(sorted, 95),
+	}
+}
+
+// Percentile returns the nearest-rank percentile p of sorted values.
+func Percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	rank = min(max(rank, 0), len(sorted)-1)
+	return sorted[rank]
+}
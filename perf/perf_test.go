@@ -0,0 +1,177 @@
+package perf_test
+
+import (
+	"fmt"
+	"mcp-android-adb-server/perf"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeShell simulates a package whose counters grow on every sampling pass
+type fakeShell struct {
+	mu    sync.Mutex
+	round int64
+}
+
+func (f *fakeShell) RunShellCommand(cmd string, args ...string) (string, error) {
+	cmd = strings.Join(append([]string{cmd}, args...), " ")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case strings.HasPrefix(cmd, "ps "):
+		return "  PID NAME\n 1234 com.example.app\n 1240 com.example.app:push\n 999 com.example.other\n", nil
+	case strings.HasPrefix(cmd, "cat /proc/stat"):
+		// Each pass: 400 device ticks on 4 CPUs, 20 ticks per process
+		f.round++
+		total := f.round * 400
+		proc := f.round * 10
+		stat := fmt.Sprintf("cpu  %d 0 0 0 0 0 0 0 0 0\ncpu0 1\ncpu1 1\ncpu2 1\ncpu3 1\n", total)
+		for _, pid := range []string{"1234", "1240"} {
+			stat += fmt.Sprintf("%s (com.example.app) S 1 1 0 0 -1 0 0 0 0 0 %d %d 0 0 20 0\n", pid, proc, proc)
+		}
+		return stat, nil
+	case strings.HasPrefix(cmd, "dumpsys meminfo"):
+		return fmt.Sprintf("** MEMINFO in pid 1234 [com.example.app] **\n           TOTAL PSS:    %d            TOTAL RSS:   200000\n", 100000+f.round*1000), nil
+	case strings.HasPrefix(cmd, "dumpsys gfxinfo com.example.app framestats"):
+		return fmt.Sprintf("Total frames rendered: %d\nJanky frames: %d (10.00%%)\n50th percentile: 8ms\n90th percentile: 14ms\n", f.round*10, f.round), nil
+	case strings.HasPrefix(cmd, "pm list packages -U"):
+		return "package:com.example.app uid:10123\n", nil
+	case strings.HasPrefix(cmd, "cat /proc/net/xt_qtaguid"):
+		return "", nil
+	case strings.HasPrefix(cmd, "cat /proc/net/dev"):
+		return fmt.Sprintf("Inter-|   Receive\n face |bytes\n    lo: 999 1 0 0 0 0 0 0 999 1 0 0 0 0 0 0\n wlan0: %d 1 0 0 0 0 0 0 %d 1 0 0 0 0 0 0\n", f.round*2048, f.round*1024), nil
+	}
+
+	return "", nil
+}
+
+// TestSession tests sampling a package and summarizing the samples
+func TestSession(t *testing.T) {
+	shell := &fakeShell{}
+	monitor := perf.NewMonitor()
+
+	if err := monitor.Start(shell, "com.example.app", 100*time.Millisecond); err != nil {
+		t.Fatalf("Failed to start sampling: %v", err)
+	}
+	if err := monitor.Start(shell, "com.example.app", 100*time.Millisecond); err == nil {
+		t.Error("Should return error when the package is already being sampled")
+	}
+
+	time.Sleep(350 * time.Millisecond)
+
+	report, err := monitor.Stop("com.example.app")
+	if err != nil {
+		t.Fatalf("Failed to stop sampling: %v", err)
+	}
+	if len(report.Samples) < 2 {
+		t.Fatalf("Expected at least 2 samples, got %d", len(report.Samples))
+	}
+	if len(report.Errors) != 0 {
+		t.Errorf("Unexpected sampling errors: %v", report.Errors)
+	}
+
+	sample := report.Samples[0]
+	// 2 processes x 20 ticks of 400 device ticks on 4 CPUs
+	if sample.CPU != 40 {
+		t.Errorf("Unexpected CPU usage: %v", sample.CPU)
+	}
+	if sample.Frames != 10 || sample.JankyFrames != 1 || sample.RxBytes != 2048 || sample.TxBytes != 1024 {
+		t.Errorf("Unexpected sample: %+v", sample)
+	}
+
+	summary := report.Summary
+	if summary.JankPercent != 10 || summary.NetworkScope != perf.NetworkScopeDevice || summary.FrameTimeMs[90] != 14 {
+		t.Errorf("Unexpected summary: %+v", summary)
+	}
+	if summary.CPU.P50 != 40 || summary.PSSKB.Min < 100000 {
+		t.Errorf("Unexpected summary stats: %+v", summary)
+	}
+
+	if _, err := monitor.Stop("com.example.app"); err == nil {
+		t.Error("Should return error when the package is not being sampled")
+	}
+}
+
+// TestStopAll tests that stopping all sessions stops sampling
+func TestStopAll(t *testing.T) {
+	shell := &fakeShell{}
+	monitor := perf.NewMonitor()
+
+	for _, pkg := range []string{"com.example.app", "com.example.other"} {
+		if err := monitor.Start(shell, pkg, 100*time.Millisecond); err != nil {
+			t.Fatalf("Failed to start sampling: %v", err)
+		}
+	}
+	monitor.StopAll()
+
+	shell.mu.Lock()
+	round := shell.round
+	shell.mu.Unlock()
+	time.Sleep(250 * time.Millisecond)
+
+	shell.mu.Lock()
+	defer shell.mu.Unlock()
+	if shell.round != round {
+		t.Errorf("Expected sampling to stop, %d more passes ran", shell.round-round)
+	}
+	if _, err := monitor.Stop("com.example.app"); err == nil {
+		t.Error("Should return error when the package is not being sampled")
+	}
+}
+
+// TestPercentile tests nearest-rank percentiles
+func TestPercentile(t *testing.T) {
+	values := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	testCases := map[float64]float64{0: 1, 50: 5, 90: 9, 95: 10, 100: 10}
+
+	for p, expected := range testCases {
+		if got := perf.Percentile(values, p); got != expected {
+			t.Errorf("Percentile(%v) = %v, expected %v", p, got, expected)
+		}
+	}
+
+	if got := perf.Percentile(nil, 50); got != 0 {
+		t.Errorf("Percentile of empty values = %v, expected 0", got)
+	}
+}
+
+// quotingShell records the commands sent for a package name with spaces
+type quotingShell struct {
+	fakeShell
+	commands []string
+}
+
+func (q *quotingShell) RunShellCommand(cmd string, args ...string) (string, error) {
+	q.mu.Lock()
+	q.commands = append(q.commands, strings.Join(append([]string{cmd}, args...), " "))
+	q.mu.Unlock()
+
+	if strings.HasPrefix(cmd, "ps ") {
+		return "  PID NAME\n 1234 com.example.app;reboot\n", nil
+	}
+	return "", nil
+}
+
+// TestStartQuotesPackage tests that the package name reaches the device
+// shell as a single word
+func TestStartQuotesPackage(t *testing.T) {
+	shell := &quotingShell{}
+	session, err := perf.Start(shell, "com.example.app;reboot", 100*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Failed to start sampling: %v", err)
+	}
+	time.Sleep(150 * time.Millisecond)
+	session.Stop()
+
+	shell.mu.Lock()
+	defer shell.mu.Unlock()
+	for _, cmd := range shell.commands {
+		if strings.Contains(cmd, "com.example.app;reboot") && !strings.Contains(cmd, "'com.example.app;reboot'") {
+			t.Errorf("Package name passed unquoted: %q", cmd)
+		}
+	}
+}
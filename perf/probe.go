@@ -0,0 +1,236 @@
+package perf
+
+import (
+	"fmt"
+	"mcp-android-adb-server/shellquote"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// e.g. "TOTAL PSS:   123456" (Android 10+) or "TOTAL   123456 ..." in the App Summary table
+	totalPSSRegexp   = regexp.MustCompile(`TOTAL PSS:\s+(\d+)`)
+	legacyPSSRegexp  = regexp.MustCompile(`(?m)^\s*TOTAL\s+(\d+)`)
+	totalFrameRegexp = regexp.MustCompile(`Total frames rendered: (\d+)`)
+	jankyFrameRegexp = regexp.MustCompile(`Janky frames: (\d+)`)
+
+	// e.g. "90th percentile: 12ms"
+	frameTimeRegexp = regexp.MustCompile(`(\d+)th percentile: (\d+)ms`)
+)
+
+// counters are the cumulative values read in one sampling pass.
+type counters struct {
+	procTicks  int64 // utime + stime of every process of the package
+	totalTicks int64 // total CPU time of the device
+	cpus       int   // number of CPUs
+	frames     int   // frames rendered since gfxinfo was reset
+	jankyFrame int   // janky frames since gfxinfo was reset
+	rxBytes    int64 // bytes received
+	txBytes    int64 // bytes transmitted
+}
+
+// pids returns the process IDs of a package, including its :service processes.
+func pids(shell Shell, pkg string) ([]string, error) {
+	out, err := shell.RunShellCommand("ps -A -o PID,NAME")
+	if err != nil {
+		return nil, fmt.Errorf("ps: %w", err)
+	}
+
+	var ids []string
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if fields[1] == pkg || strings.HasPrefix(fields[1], pkg+":") {
+			ids = append(ids, fields[0])
+		}
+	}
+
+	return ids, nil
+}
+
+// readCPU reads the CPU time of the given processes and of the whole device.
+func readCPU(shell Shell, ids []string, c *counters) error {
+	args := []string{"/proc/stat"}
+	for _, id := range ids {
+		args = append(args, "/proc/"+id+"/stat")
+	}
+
+	// Processes may exit between ps and cat, so errors for them are ignored
+	out, err := shell.RunShellCommand("cat", append(args, "2>/dev/null")...)
+	if err != nil {
+		return fmt.Errorf("read cpu: %w", err)
+	}
+
+	c.procTicks, c.totalTicks, c.cpus = parseCPU(out)
+	if c.totalTicks == 0 {
+		return fmt.Errorf("failed to parse /proc/stat")
+	}
+
+	return nil
+}
+
+// parseCPU parses /proc/stat followed by any number of /proc/<pid>/stat lines.
+func parseCPU(out string) (procTicks, totalTicks int64, cpus int) {
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+		case fields[0] == "cpu":
+			for _, f := range fields[1:] {
+				v, _ := strconv.ParseInt(f, 10, 64)
+				totalTicks += v
+			}
+		case strings.HasPrefix(fields[0], "cpu"):
+			cpus++
+		default:
+			// The process name may contain spaces, fields are counted after ")"
+			end := strings.LastIndex(line, ")")
+			if end < 0 {
+				continue
+			}
+			rest := strings.Fields(line[end+1:])
+			// utime and stime are fields 14 and 15, i.e. 11 and 12 after the name
+			if len(rest) > 12 {
+				utime, _ := strconv.ParseInt(rest[11], 10, 64)
+				stime, _ := strconv.ParseInt(rest[12], 10, 64)
+				procTicks += utime + stime
+			}
+		}
+	}
+
+	return procTicks, totalTicks, cpus
+}
+
+// readPSS returns the total PSS of a package in kB.
+func readPSS(shell Shell, pkg string) (int64, error) {
+	out, err := shell.RunShellCommand(shellquote.Command("dumpsys meminfo", pkg))
+	if err != nil {
+		return 0, fmt.Errorf("dumpsys meminfo: %w", err)
+	}
+
+	match := totalPSSRegexp.FindStringSubmatch(out)
+	if match == nil {
+		match = legacyPSSRegexp.FindStringSubmatch(out)
+	}
+	if match == nil {
+		return 0, fmt.Errorf("failed to parse meminfo: %s", firstLine(out))
+	}
+
+	return strconv.ParseInt(match[1], 10, 64)
+}
+
+// readFrames reads the cumulative frame counters of a package.
+func readFrames(shell Shell, pkg string, c *counters) (map[int]int, error) {
+	out, err := shell.RunShellCommand(shellquote.Command("dumpsys gfxinfo", pkg, "framestats"))
+	if err != nil {
+		return nil, fmt.Errorf("dumpsys gfxinfo: %w", err)
+	}
+
+	match := totalFrameRegexp.FindStringSubmatch(out)
+	if match == nil {
+		return nil, fmt.Errorf("failed to parse gfxinfo: %s", firstLine(out))
+	}
+	c.frames, _ = strconv.Atoi(match[1])
+
+	if match := jankyFrameRegexp.FindStringSubmatch(out); match != nil {
+		c.jankyFrame, _ = strconv.Atoi(match[1])
+	}
+
+	percentiles := make(map[int]int)
+	for _, m := range frameTimeRegexp.FindAllStringSubmatch(out, -1) {
+		p, _ := strconv.Atoi(m[1])
+		ms, _ := strconv.Atoi(m[2])
+		percentiles[p] = ms
+	}
+
+	return percentiles, nil
+}
+
+// readNetwork reads the bytes transferred by a package UID from
+// xt_qtaguid, which is only available before Android 10. On newer releases
+// the totals of every interface but loopback are used instead.
+func readNetwork(shell Shell, uid string, c *counters) (string, error) {
+	if uid != "" {
+		out, err := shell.RunShellCommand("cat /proc/net/xt_qtaguid/stats 2>/dev/null")
+		if err == nil && strings.Contains(out, "uid_tag_int") {
+			c.rxBytes, c.txBytes = parseQtaguid(out, uid)
+			return NetworkScopeApp, nil
+		}
+	}
+
+	out, err := shell.RunShellCommand("cat /proc/net/dev")
+	if err != nil {
+		return "", fmt.Errorf("read network: %w", err)
+	}
+
+	c.rxBytes, c.txBytes = parseNetDev(out)
+	return NetworkScopeDevice, nil
+}
+
+// parseQtaguid sums the bytes of a UID in /proc/net/xt_qtaguid/stats.
+func parseQtaguid(out, uid string) (rx, tx int64) {
+	// Format: idx iface acct_tag_hex uid_tag_int cnt_set rx_bytes rx_packets tx_bytes ...
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 8 || fields[3] != uid || fields[1] == "lo" {
+			continue
+		}
+
+		r, _ := strconv.ParseInt(fields[5], 10, 64)
+		t, _ := strconv.ParseInt(fields[7], 10, 64)
+		rx += r
+		tx += t
+	}
+
+	return rx, tx
+}
+
+// parseNetDev sums the bytes of every interface but loopback in /proc/net/dev.
+func parseNetDev(out string) (rx, tx int64) {
+	// Format: iface: rx_bytes rx_packets errs drop fifo frame compressed multicast tx_bytes ...
+	for _, line := range strings.Split(out, "\n") {
+		name, stats, ok := strings.Cut(line, ":")
+		if !ok || strings.TrimSpace(name) == "lo" {
+			continue
+		}
+
+		fields := strings.Fields(stats)
+		if len(fields) < 9 {
+			continue
+		}
+
+		r, _ := strconv.ParseInt(fields[0], 10, 64)
+		t, _ := strconv.ParseInt(fields[8], 10, 64)
+		rx += r
+		tx += t
+	}
+
+	return rx, tx
+}
+
+// packageUID returns the UID of a package.
+func packageUID(shell Shell, pkg string) string {
+	out, err := shell.RunShellCommand(shellquote.Command("pm list packages -U", pkg))
+	if err != nil {
+		return ""
+	}
+
+	// Format: package:com.example.app uid:10123
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "package:"+pkg {
+			return strings.TrimPrefix(fields[1], "uid:")
+		}
+	}
+
+	return ""
+}
+
+// firstLine returns the first line of s for error messages.
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	return line
+}
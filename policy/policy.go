@@ -0,0 +1,311 @@
+// Package policy decides which shell commands and tools may run on the device.
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Risk is the risk class of a shell command or tool.
+type Risk int
+
+const (
+	// RiskRead only reads device state.
+	RiskRead Risk = iota
+	// RiskWrite changes device state in a recoverable way.
+	RiskWrite
+	// RiskDestructive loses data, reboots the device or drops the connection.
+	RiskDestructive
+)
+
+var riskNames = []string{"read", "write", "destructive"}
+
+func (r Risk) String() string {
+	if r < 0 || int(r) >= len(riskNames) {
+		return fmt.Sprintf("risk(%d)", int(r))
+	}
+	return riskNames[r]
+}
+
+// ParseRisk parses read, write or destructive.
+func ParseRisk(name string) (Risk, error) {
+	for i, n := range riskNames {
+		if strings.EqualFold(strings.TrimSpace(name), n) {
+			return Risk(i), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid risk %q, expected one of %s", name, strings.Join(riskNames, ", "))
+}
+
+func (r Risk) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+func (r *Risk) UnmarshalText(text []byte) error {
+	risk, err := ParseRisk(string(text))
+	if err != nil {
+		return err
+	}
+	*r = risk
+	return nil
+}
+
+// Rule assigns a risk class to the shell commands matching a pattern.
+type Rule struct {
+	Pattern string `json:"pattern" yaml:"pattern" toml:"pattern"`
+	Risk    Risk   `json:"risk" yaml:"risk" toml:"risk"`
+
+	re *regexp.Regexp
+}
+
+// Policy holds the rules applied to shell commands and tools. Patterns are
+// regular expressions matched against each command of a command line.
+type Policy struct {
+	ReadOnly bool     `json:"read_only" yaml:"read_only" toml:"read_only"`                         // Refuse everything that is not a read
+	Allow    []string `json:"allow" yaml:"allow" toml:"allow"`                                     // If set, commands must match one of these
+	Deny     []string `json:"deny" yaml:"deny" toml:"deny"`                                        // Commands matching one of these are refused
+	Rules    []Rule   `json:"rules" yaml:"rules" toml:"rules"`                                     // Risk classes checked before the built-in ones
+	Confirm  *Risk    `json:"confirm,omitempty" yaml:"confirm,omitempty" toml:"confirm,omitempty"` // Lowest risk needing confirm: true, nil for none
+
+	allow []*regexp.Regexp
+	deny  []*regexp.Regexp
+}
+
+// builtinRules classify common commands, the first match wins and commands
+// matching none are writes.
+var builtinRules = mustRules([]Rule{
+	{Pattern: `^(rm|rmdir|dd|mkfs\S*|reboot|wipe|format|recovery)\b`, Risk: RiskDestructive},
+	{Pattern: `^(pm|cmd package)\s+(uninstall|clear|disable\S*|hide|suspend|reset-permissions)\b`, Risk: RiskDestructive},
+	{Pattern: `^svc\s+power\s+(reboot|shutdown)\b`, Risk: RiskDestructive},
+	{Pattern: `^settings\s+(delete|reset)\b`, Risk: RiskDestructive},
+	{Pattern: `^find\b.*\s-(exec\w*|ok\w*|delete|fprint\w*)\b`, Risk: RiskDestructive},
+	{Pattern: `^dumpsys\s+\S+\s+(.*\s)?(set|unplug|reset|enable|disable|force-\S+|unforce|step|\S*whitelist)\b`, Risk: RiskWrite},
+	{Pattern: `^(ls|cat|head|tail|grep|wc|stat|find|du|df|ps|top -n|id|whoami|uptime|uname|pwd|echo|printenv|getprop|dumpsys|logcat -d)\b`, Risk: RiskRead},
+	{Pattern: `^ip(\s+-\S+)*\s+(addr|address|route|link)(\s+show\b.*)?$`, Risk: RiskRead},
+	{Pattern: `^date(\s+\+\S+)?$`, Risk: RiskRead},
+	{Pattern: `^(pm|cmd package)\s+(list|path|dump)\b`, Risk: RiskRead},
+	{Pattern: `^settings\s+(get|list)\b`, Risk: RiskRead},
+	{Pattern: `^wm\s+(size|density)\s*$`, Risk: RiskRead},
+})
+
+// wrappers match the prefixes that run the rest of a command: a directory,
+// the toybox or busybox multiplexer, sh -c, su, env and similar commands.
+var wrappers = []*regexp.Regexp{
+	regexp.MustCompile(`^\S*/`),
+	regexp.MustCompile(`^(toybox|busybox)\s+`),
+	regexp.MustCompile(`^(sh|bash|mksh)(\s+-\w+)*\s+`),
+	regexp.MustCompile(`^su(\s+(\d+|root|shell|-\w+))*\s+`),
+	regexp.MustCompile(`^env(\s+-\S+)*\s+`),
+	regexp.MustCompile(`^\w+=\S*\s+`),
+	regexp.MustCompile(`^(nohup|exec|eval|command|time|xargs(\s+-\S+)*|nice(\s+-n)?\s+-?\d+|timeout(\s+-\S+)*\s+\S+)\s+`),
+}
+
+// quotes are removed before matching, the shell joins quoted words
+var quotes = strings.NewReplacer(`'`, "", `"`, "", `\`, "")
+
+// separators split a command line into commands, including subshells
+var separators = regexp.MustCompile("&&|\\|\\||[;&|\n`()]|\\$\\(")
+
+// duplication matches file descriptor redirections, e.g. 2>&1
+var duplication = regexp.MustCompile(`[0-9]*[<>]&[0-9-]`)
+
+// redirection matches output redirected to a file, e.g. > /sdcard/out.txt
+var redirection = regexp.MustCompile(`(?:^|[^0-9&])>>?\s*([^&\s]\S*)`)
+
+// Default returns a policy allowing everything without confirmation.
+func Default() *Policy {
+	return &Policy{}
+}
+
+// Load reads a JSON policy file.
+func Load(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	p := Default()
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("invalid policy file %s: %w", path, err)
+	}
+	if err := p.Compile(); err != nil {
+		return nil, fmt.Errorf("invalid policy file %s: %w", path, err)
+	}
+
+	return p, nil
+}
+
+// Compile compiles the patterns of the policy, it must be called after
+// changing them.
+func (p *Policy) Compile() error {
+	var err error
+	if p.allow, err = compile(p.Allow); err != nil {
+		return err
+	}
+	if p.deny, err = compile(p.Deny); err != nil {
+		return err
+	}
+	for i := range p.Rules {
+		if p.Rules[i].re, err = regexp.Compile(p.Rules[i].Pattern); err != nil {
+			return fmt.Errorf("invalid rule pattern %q: %w", p.Rules[i].Pattern, err)
+		}
+	}
+	return nil
+}
+
+// Classify returns the highest risk of the commands of a command line.
+func (p *Policy) Classify(command string) Risk {
+	risk := RiskRead
+	for _, cmd := range commands(command) {
+		risk = max(risk, p.classify(cmd))
+	}
+	if hasRedirection(command) {
+		risk = max(risk, RiskWrite)
+	}
+	return risk
+}
+
+func (p *Policy) classify(cmd string) Risk {
+	for _, rules := range [][]Rule{p.Rules, builtinRules} {
+		for _, rule := range rules {
+			if rule.re != nil && rule.re.MatchString(cmd) {
+				return rule.Risk
+			}
+		}
+	}
+	return RiskWrite
+}
+
+// CheckCommand returns a *DeniedError if the shell command may not run.
+func (p *Policy) CheckCommand(command string, confirmed bool) error {
+	split := Split(command)
+	if len(split) == 0 {
+		return nil
+	}
+
+	// Deny rules also apply to the commands as written
+	for _, cmd := range split {
+		if re := firstMatch(p.deny, cmd); re != nil {
+			return &DeniedError{Subject: cmd, Reason: fmt.Sprintf("it matches the deny rule %q", re)}
+		}
+	}
+
+	for _, cmd := range commands(command) {
+		if re := firstMatch(p.deny, cmd); re != nil {
+			return &DeniedError{Subject: cmd, Reason: fmt.Sprintf("it matches the deny rule %q", re)}
+		}
+		if len(p.allow) > 0 && firstMatch(p.allow, cmd) == nil {
+			return &DeniedError{Subject: cmd, Reason: "it matches none of the allow rules"}
+		}
+	}
+
+	return p.check(fmt.Sprintf("shell command %q", command), p.Classify(command), confirmed)
+}
+
+// CheckTool returns a *DeniedError if a tool of the given risk may not run.
+func (p *Policy) CheckTool(name string, risk Risk, confirmed bool) error {
+	return p.check(fmt.Sprintf("tool %s", name), risk, confirmed)
+}
+
+func (p *Policy) check(subject string, risk Risk, confirmed bool) error {
+	if p.ReadOnly && risk > RiskRead {
+		return &DeniedError{Subject: subject, Reason: fmt.Sprintf("the server is read-only and it is a %s operation", risk)}
+	}
+	if p.NeedsConfirm(risk) && !confirmed {
+		return &DeniedError{Subject: subject, Reason: fmt.Sprintf("it is a %s operation, call again with confirm: true to run it", risk)}
+	}
+	return nil
+}
+
+// NeedsConfirm reports whether operations of the given risk need confirm: true.
+func (p *Policy) NeedsConfirm(risk Risk) bool {
+	return p.Confirm != nil && risk >= *p.Confirm
+}
+
+// DeniedError explains why a command or tool was refused.
+type DeniedError struct {
+	Subject string
+	Reason  string
+}
+
+func (e *DeniedError) Error() string {
+	return fmt.Sprintf("%s denied by policy: %s", e.Subject, e.Reason)
+}
+
+// Split splits a command line into its trimmed commands. It is not a shell
+// parser, quoted separators split too, which errs on the side of checking more.
+func Split(command string) []string {
+	var commands []string
+	command = duplication.ReplaceAllString(command, " ")
+	for _, cmd := range separators.Split(command, -1) {
+		if cmd = strings.TrimSpace(cmd); cmd != "" {
+			commands = append(commands, cmd)
+		}
+	}
+	return commands
+}
+
+// commands splits a command line and strips the wrappers of each command,
+// so rules see the command that actually runs, e.g. rm for
+// /system/bin/rm, busybox rm or sh -c 'rm'.
+func commands(command string) []string {
+	var unwrapped []string
+	for _, cmd := range Split(command) {
+		cmd = strings.TrimSpace(quotes.Replace(cmd))
+		for changed := true; changed; {
+			changed = false
+			for _, re := range wrappers {
+				if loc := re.FindStringIndex(cmd); loc != nil && loc[1] < len(cmd) {
+					cmd = strings.TrimSpace(cmd[loc[1]:])
+					changed = true
+				}
+			}
+		}
+		if cmd != "" {
+			unwrapped = append(unwrapped, cmd)
+		}
+	}
+	return unwrapped
+}
+
+// hasRedirection reports whether the command line writes to a file other
+// than /dev/null.
+func hasRedirection(command string) bool {
+	for _, match := range redirection.FindAllStringSubmatch(command, -1) {
+		if match[1] != "/dev/null" {
+			return true
+		}
+	}
+	return false
+}
+
+func compile(patterns []string) ([]*regexp.Regexp, error) {
+	var compiled []*regexp.Regexp
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+func mustRules(rules []Rule) []Rule {
+	for i := range rules {
+		rules[i].re = regexp.MustCompile(rules[i].Pattern)
+	}
+	return rules
+}
+
+func firstMatch(patterns []*regexp.Regexp, cmd string) *regexp.Regexp {
+	for _, re := range patterns {
+		if re.MatchString(cmd) {
+			return re
+		}
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package policy_test
+
+import (
+	"errors"
+	"mcp-android-adb-server/policy"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestClassify tests the built-in risk classes
+func TestClassify(t *testing.T) {
+	p := policy.Default()
+
+	tests := map[string]policy.Risk{
+		"ls -l /sdcard":                      policy.RiskRead,
+		"dumpsys battery | grep level":       policy.RiskRead,
+		"pm list packages":                   policy.RiskRead,
+		"ls > /dev/null 2>&1":                policy.RiskRead,
+		"input tap 1 1":                      policy.RiskWrite,
+		"echo hi > /sdcard/hi.txt":           policy.RiskWrite,
+		"settings put global foo 1":          policy.RiskWrite,
+		"rm -rf /sdcard":                     policy.RiskDestructive,
+		"reboot":                             policy.RiskDestructive,
+		"pm uninstall com.example.app":       policy.RiskDestructive,
+		"ls; rm -rf /sdcard":                 policy.RiskDestructive,
+		"echo $(rm -rf /sdcard)":             policy.RiskDestructive,
+		"find /sdcard -name '*.log' -delete": policy.RiskDestructive,
+		"find /sdcard -execdir rm {} +":      policy.RiskDestructive,
+		"env rm -rf /sdcard":                 policy.RiskDestructive,
+		"which reboot":                       policy.RiskWrite,
+		"ip addr show wlan0":                 policy.RiskRead,
+		"ip -o -4 addr":                      policy.RiskRead,
+		"ip link set wlan0 down":             policy.RiskWrite,
+		"ip route add default via 10.0.0.1":  policy.RiskWrite,
+		"date +%s":                           policy.RiskRead,
+		"date -s 20200101":                   policy.RiskWrite,
+		"dumpsys battery set level 5":        policy.RiskWrite,
+		"dumpsys deviceidle force-idle":      policy.RiskWrite,
+		"dumpsys activity settings":          policy.RiskRead,
+	}
+
+	for command, want := range tests {
+		if got := p.Classify(command); got != want {
+			t.Errorf("Classify(%q) = %s, expected %s", command, got, want)
+		}
+	}
+}
+
+// TestClassifyWrapped tests that wrappers do not hide the command that runs
+func TestClassifyWrapped(t *testing.T) {
+	p := policy.Default()
+
+	for _, command := range []string{
+		"/system/bin/rm -rf /sdcard",
+		"toybox rm -rf /sdcard",
+		"busybox rm -rf /sdcard",
+		"sh -c 'rm -rf /sdcard'",
+		`su 0 sh -c "rm -rf /sdcard"`,
+		"su -c 'rm -rf /sdcard'",
+		"env FOO=1 /system/bin/toybox rm -rf /sdcard",
+		"ls /sdcard | xargs rm",
+		"'r''m' -rf /sdcard",
+	} {
+		if got := p.Classify(command); got != policy.RiskDestructive {
+			t.Errorf("Classify(%q) = %s, expected destructive", command, got)
+		}
+	}
+
+	if got := p.Classify("toybox ls /sdcard"); got != policy.RiskRead {
+		t.Errorf("Expected a wrapped read to stay a read, got %s", got)
+	}
+
+	deny := &policy.Policy{Deny: []string{`^rm\b`}}
+	if err := deny.Compile(); err != nil {
+		t.Fatal(err)
+	}
+	if err := deny.CheckCommand("busybox rm /sdcard/a", true); err == nil {
+		t.Error("Expected the deny rule to apply to a wrapped command")
+	}
+}
+
+// TestCheckCommand tests allow and deny rules, read-only mode and confirmation
+func TestCheckCommand(t *testing.T) {
+	destructive := policy.RiskDestructive
+	p := &policy.Policy{
+		Allow:   []string{`^(ls|rm|input|reboot)\b`},
+		Deny:    []string{`^reboot\b`},
+		Confirm: &destructive,
+	}
+	if err := p.Compile(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		command   string
+		confirmed bool
+		denied    string
+	}{
+		{command: "ls /sdcard"},
+		{command: "input tap 1 1"},
+		{command: "reboot", confirmed: true, denied: "deny rule"},
+		{command: "ls && getprop", denied: "none of the allow rules"},
+		{command: "rm /sdcard/a", denied: "confirm: true"},
+		{command: "rm /sdcard/a", confirmed: true},
+	}
+
+	for _, tt := range tests {
+		err := p.CheckCommand(tt.command, tt.confirmed)
+		if tt.denied == "" {
+			if err != nil {
+				t.Errorf("CheckCommand(%q) = %v, expected no error", tt.command, err)
+			}
+			continue
+		}
+
+		var denied *policy.DeniedError
+		if !errors.As(err, &denied) || !strings.Contains(err.Error(), tt.denied) {
+			t.Errorf("CheckCommand(%q) = %v, expected a denial mentioning %q", tt.command, err, tt.denied)
+		}
+	}
+
+	p.ReadOnly = true
+	if err := p.CheckCommand("input tap 1 1", true); err == nil || !strings.Contains(err.Error(), "read-only") {
+		t.Errorf("Expected the read-only policy to refuse a write, got %v", err)
+	}
+	readOnly := &policy.Policy{ReadOnly: true}
+	for _, command := range []string{"env rm -rf /sdcard", "ip link set wlan0 down", "date -s 20200101", "find /sdcard -execdir rm {} +", "which reboot"} {
+		if err := readOnly.CheckCommand(command, true); err == nil {
+			t.Errorf("Expected the read-only policy to refuse %q", command)
+		}
+	}
+	if err := p.CheckTool("uninstall_app", policy.RiskDestructive, true); err == nil {
+		t.Error("Expected the read-only policy to refuse a destructive tool")
+	}
+	if err := p.CheckTool("screen_size", policy.RiskRead, false); err != nil {
+		t.Errorf("Expected the read-only policy to allow a read, got %v", err)
+	}
+}
+
+// TestLoad tests loading a policy file with custom rules
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	content := `{
+		"deny": ["^svc "],
+		"rules": [{"pattern": "^am force-stop\\b", "risk": "destructive"}],
+		"confirm": "destructive"
+	}`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := policy.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if risk := p.Classify("am force-stop com.example.app"); risk != policy.RiskDestructive {
+		t.Errorf("Expected the custom rule to apply, got %s", risk)
+	}
+	if err := p.CheckCommand("svc wifi disable", true); err == nil {
+		t.Error("Expected the deny rule to apply")
+	}
+	if !p.NeedsConfirm(policy.RiskDestructive) || p.NeedsConfirm(policy.RiskWrite) {
+		t.Error("Expected only destructive operations to need confirmation")
+	}
+
+	if err := os.WriteFile(path, []byte(`{"deny": ["("]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := policy.Load(path); err == nil {
+		t.Error("Expected an invalid pattern to fail")
+	}
+}
+
+// TestSplit tests splitting command lines
+func TestSplit(t *testing.T) {
+	got := policy.Split("ls -l && cat a | grep b; `id` || echo $(date)\nexit")
+	want := []string{"ls -l", "cat a", "grep b", "id", "echo", "date", "exit"}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Split() = %q, expected %q", got, want)
+	}
+}
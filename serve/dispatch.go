@@ -0,0 +1,113 @@
+package serve
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// methodCancelled is the notification a client sends to cancel a request.
+const methodCancelled = "notifications/cancelled"
+
+// message holds the fields needed to route a JSON-RPC message.
+type message struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params struct {
+		RequestID json.RawMessage `json:"requestId"`
+		Reason    string          `json:"reason"`
+	} `json:"params"`
+}
+
+// inflight tracks the requests being handled so they can be cancelled.
+type inflight struct {
+	mu       sync.Mutex
+	requests map[string]*request
+}
+
+// request is a request being handled.
+type request struct {
+	cancel    context.CancelFunc
+	cancelled bool // Cancelled by the client, the response is dropped
+}
+
+// handle dispatches a message and passes its response to write. Requests run
+// in their own goroutine, notifications run inline to keep their order. It
+// returns the JSON encoded id of a request, or "" for other messages.
+func handle(ctx context.Context, s *server.MCPServer, write func(v any) error, pending *inflight, wg *sync.WaitGroup, line []byte) string {
+	var msg message
+	if err := json.Unmarshal(line, &msg); err != nil || len(msg.ID) == 0 || string(msg.ID) == "null" {
+		if msg.Method == methodCancelled {
+			pending.cancel(string(msg.Params.RequestID), msg.Params.Reason)
+		}
+		if response := s.HandleMessage(ctx, line); response != nil {
+			if err := write(response); err != nil {
+				slog.Error("error writing response", "error", err)
+			}
+		}
+		return ""
+	}
+
+	id := string(msg.ID)
+	reqCtx, cancel := context.WithCancel(ctx)
+	r := pending.add(id, cancel)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer pending.remove(id, r)
+		defer cancel()
+
+		response := s.HandleMessage(reqCtx, line)
+
+		pending.mu.Lock()
+		cancelled := r.cancelled
+		pending.mu.Unlock()
+
+		// The client no longer waits for a cancelled request
+		if response == nil || cancelled {
+			return
+		}
+		if err := write(response); err != nil {
+			slog.Error("error writing response", "error", err)
+		}
+	}()
+
+	return id
+}
+
+func (p *inflight) add(id string, cancel context.CancelFunc) *request {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	r := &request{cancel: cancel}
+	p.requests[id] = r
+	return r
+}
+
+func (p *inflight) remove(id string, r *request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.requests[id] == r {
+		delete(p.requests, id)
+	}
+}
+
+// cancel cancels the request with the given JSON encoded id.
+func (p *inflight) cancel(id, reason string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	r, ok := p.requests[id]
+	if !ok {
+		return
+	}
+
+	slog.Info("request cancelled by client", "id", id, "reason", reason)
+	r.cancelled = true
+	r.cancel()
+}
@@ -0,0 +1,737 @@
+package serve
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mcp-android-adb-server/auth"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// headerSessionID carries the session of the streamable HTTP transport.
+const headerSessionID = "Mcp-Session-Id"
+
+// maxMessageSize limits the body of a POST.
+const maxMessageSize = 4 << 20
+
+// HTTPOptions configure the HTTP transports.
+type HTTPOptions struct {
+	Addr            string              // Listen address, e.g. :8080
+	BasePath        string              // Prefix of the endpoint paths, e.g. /android
+	ShutdownTimeout time.Duration       // Time given to in-flight requests on shutdown
+	TLS             *tls.Config         // Serves HTTPS if set
+	Auth            *auth.Authenticator // Authenticates every request if set
+	AllowedOrigins  []string            // Browser origins allowed besides the server itself, e.g. https://app.example.com
+}
+
+var (
+	// errSessionClosed reports a message for a session that has ended.
+	errSessionClosed = errors.New("session closed")
+	// errShuttingDown reports a new session while the server shuts down.
+	errShuttingDown = errors.New("server shutting down")
+)
+
+// httpSession is a client session of an HTTP transport.
+type httpSession struct {
+	id            string
+	owner         *auth.Identity // Client that opened the session, nil without authentication
+	notifications chan mcp.JSONRPCNotification
+	initialized   atomic.Bool
+	events        chan []byte // Messages for the event stream of the session
+	pending       *inflight
+	ctx           context.Context
+	cancel        context.CancelFunc
+	done          chan struct{}
+	closeOnce     sync.Once
+}
+
+func (s *httpSession) SessionID() string {
+	return s.id
+}
+
+func (s *httpSession) NotificationChannel() chan<- mcp.JSONRPCNotification {
+	return s.notifications
+}
+
+func (s *httpSession) Initialize() {
+	s.initialized.Store(true)
+}
+
+func (s *httpSession) Initialized() bool {
+	return s.initialized.Load()
+}
+
+// send queues a message on the event stream of the session.
+func (s *httpSession) send(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case s.events <- data:
+		return nil
+	case <-s.done:
+		return errSessionClosed
+	}
+}
+
+// requestSession routes the notifications sent while handling one request
+// of the streamable HTTP transport to the response of that request.
+type requestSession struct {
+	*httpSession
+	notifications chan mcp.JSONRPCNotification
+}
+
+func (s *requestSession) NotificationChannel() chan<- mcp.JSONRPCNotification {
+	return s.notifications
+}
+
+// Handler serves the MCP server over HTTP, either with the HTTP+SSE
+// transport of protocol version 2024-11-05 or the streamable HTTP transport
+// of version 2025-03-26.
+type Handler struct {
+	server     *server.MCPServer
+	basePath   string
+	streamable bool
+	auth       *auth.Authenticator
+	origins    map[string]bool
+	hosts      map[string]bool // Host names of the server besides the loopback ones
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu       sync.RWMutex
+	sessions map[string]*httpSession
+	closing  bool
+	wg       sync.WaitGroup // Requests being handled
+}
+
+// NewSSEHandler returns a handler serving the event stream on
+// opts.BasePath/sse and taking messages on opts.BasePath/message.
+func NewSSEHandler(s *server.MCPServer, opts HTTPOptions) *Handler {
+	return newHandler(s, opts, false)
+}
+
+// NewStreamableHandler returns a handler serving the streamable HTTP
+// transport on opts.BasePath/mcp.
+func NewStreamableHandler(s *server.MCPServer, opts HTTPOptions) *Handler {
+	return newHandler(s, opts, true)
+}
+
+func newHandler(s *server.MCPServer, opts HTTPOptions, streamable bool) *Handler {
+	origins := make(map[string]bool, len(opts.AllowedOrigins))
+	hosts := make(map[string]bool)
+	for _, origin := range opts.AllowedOrigins {
+		origins[normalizeOrigin(origin)] = true
+		if u, err := url.Parse(normalizeOrigin(origin)); err == nil && u.Hostname() != "" {
+			hosts[u.Hostname()] = true
+		}
+	}
+	if host, _, err := net.SplitHostPort(opts.Addr); err == nil && host != "" {
+		if ip := net.ParseIP(host); ip == nil || !ip.IsUnspecified() {
+			hosts[strings.ToLower(host)] = true
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Handler{
+		server:     s,
+		basePath:   strings.TrimSuffix(opts.BasePath, "/"),
+		streamable: streamable,
+		auth:       opts.Auth,
+		origins:    origins,
+		hosts:      hosts,
+		ctx:        ctx,
+		cancel:     cancel,
+		sessions:   make(map[string]*httpSession),
+	}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// A web page the user visits must not reach a server on localhost or
+	// the local network, e.g. through DNS rebinding
+	if !h.allowedOrigin(r) {
+		slog.Warn("request from a foreign origin", "remote", r.RemoteAddr, "origin", r.Header.Get("Origin"))
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+
+	if h.auth != nil {
+		id, err := h.auth.Authenticate(r)
+		if err != nil {
+			slog.Warn("unauthenticated request", "remote", r.RemoteAddr, "path", r.URL.Path)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="mcp-android-adb-server"`)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		r = r.WithContext(auth.WithIdentity(r.Context(), id))
+	}
+
+	switch {
+	case h.streamable && r.URL.Path == h.basePath+"/mcp":
+		h.serveStreamable(w, r)
+	case !h.streamable && r.URL.Path == h.basePath+"/sse":
+		h.serveEvents(w, r)
+	case !h.streamable && r.URL.Path == h.basePath+"/message":
+		h.serveMessage(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// allowedOrigin reports whether r has no Origin, as requests of clients
+// other than browsers, or comes from an allowed origin or the server
+// itself. After DNS rebinding the Origin of a page matches the Host it
+// sends, so a page is only the server itself if the Host names the server.
+func (h *Handler) allowedOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	if h.origins[normalizeOrigin(origin)] {
+		return true
+	}
+
+	if !h.serverHost(r.Host) {
+		return false
+	}
+
+	u, err := url.Parse(origin)
+	return err == nil && u.Host != "" && strings.EqualFold(u.Host, r.Host)
+}
+
+// serverHost reports whether host, the Host header of a request, is a
+// loopback name, the listen host or the host of an allowed origin.
+func (h *Handler) serverHost(host string) bool {
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		host = name
+	}
+	host = strings.ToLower(strings.Trim(host, "[]"))
+
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") || h.hosts[host] {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// normalizeOrigin lowercases an origin and drops a trailing slash.
+func normalizeOrigin(origin string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(origin), "/"))
+}
+
+// Shutdown stops accepting messages and waits until the requests being
+// handled finish or ctx is done, then ends all sessions.
+func (h *Handler) Shutdown(ctx context.Context) error {
+	h.mu.Lock()
+	h.closing = true
+	h.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		h.wg.Wait()
+		close(finished)
+	}()
+
+	var err error
+	select {
+	case <-finished:
+	case <-ctx.Done():
+		err = fmt.Errorf("requests still running: %w", ctx.Err())
+	}
+
+	h.mu.Lock()
+	sessions := make([]*httpSession, 0, len(h.sessions))
+	for _, session := range h.sessions {
+		sessions = append(sessions, session)
+	}
+	h.mu.Unlock()
+
+	for _, session := range sessions {
+		h.closeSession(session)
+	}
+	h.cancel()
+
+	return err
+}
+
+// begin counts a message being handled, it fails once Shutdown started.
+func (h *Handler) begin() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if h.closing {
+		return false
+	}
+	h.wg.Add(1)
+	return true
+}
+
+// newSession opens a session owned by the client of r.
+func (h *Handler) newSession(r *http.Request) (*httpSession, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(h.ctx)
+	owner := auth.FromContext(r.Context())
+	if owner != nil {
+		ctx = auth.WithIdentity(ctx, owner)
+	}
+
+	session := &httpSession{
+		id:            hex.EncodeToString(id),
+		owner:         owner,
+		notifications: make(chan mcp.JSONRPCNotification, 100),
+		events:        make(chan []byte, 100),
+		pending:       &inflight{requests: make(map[string]*request)},
+		cancel:        cancel,
+		done:          make(chan struct{}),
+	}
+	session.ctx = h.server.WithContext(ctx, session)
+
+	if err := h.server.RegisterSession(session); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	h.mu.Lock()
+	closing := h.closing
+	if !closing {
+		h.sessions[session.id] = session
+	}
+	h.mu.Unlock()
+
+	if closing {
+		h.server.UnregisterSession(session.id)
+		cancel()
+		return nil, errShuttingDown
+	}
+
+	// Forward notifications sent to the whole session to its event stream
+	go func() {
+		for {
+			select {
+			case notification := <-session.notifications:
+				_ = session.send(notification)
+			case <-session.done:
+				return
+			}
+		}
+	}()
+
+	return session, nil
+}
+
+// session returns the session with the given id, or writes an error and
+// returns nil if it does not exist or belongs to another client.
+func (h *Handler) session(w http.ResponseWriter, r *http.Request, id string) *httpSession {
+	h.mu.RLock()
+	session := h.sessions[id]
+	h.mu.RUnlock()
+
+	if session == nil {
+		http.Error(w, "unknown session", http.StatusNotFound)
+		return nil
+	}
+	if session.owner != nil {
+		if id := auth.FromContext(r.Context()); id == nil || id.Name != session.owner.Name {
+			http.Error(w, "session belongs to another client", http.StatusForbidden)
+			return nil
+		}
+	}
+	return session
+}
+
+// closeSession ends a session and cancels its requests.
+func (h *Handler) closeSession(session *httpSession) {
+	session.closeOnce.Do(func() {
+		h.mu.Lock()
+		delete(h.sessions, session.id)
+		h.mu.Unlock()
+
+		h.server.UnregisterSession(session.id)
+		session.cancel()
+		close(session.done)
+	})
+}
+
+// serveEvents opens a session of the HTTP+SSE transport and streams its
+// messages until the client disconnects.
+func (h *Handler) serveEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	session, err := h.newSession(r)
+	if errors.Is(err, errShuttingDown) {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer h.closeSession(session)
+
+	slog.Info("sse session opened", "session", session.id, "remote", r.RemoteAddr, "identity", identityName(session.owner))
+
+	stream, ok := newEventStream(w)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	endpoint := fmt.Sprintf("%s/message?sessionId=%s", h.basePath, session.id)
+	if err := stream.event("endpoint", []byte(endpoint)); err != nil {
+		return
+	}
+	h.stream(r.Context(), stream, session)
+
+	slog.Info("sse session closed", "session", session.id)
+}
+
+// serveMessage takes a message of the HTTP+SSE transport, its response is
+// sent on the event stream of the session.
+func (h *Handler) serveMessage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	session := h.session(w, r, r.URL.Query().Get("sessionId"))
+	if session == nil {
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxMessageSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !h.begin() {
+		http.Error(w, errShuttingDown.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	defer h.wg.Done()
+
+	handle(session.ctx, h.server, session.send, session.pending, &h.wg, body)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// serveStreamable serves the single endpoint of the streamable HTTP
+// transport: POST sends messages, GET opens an event stream for the
+// notifications of the session and DELETE ends the session.
+func (h *Handler) serveStreamable(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		h.servePost(w, r)
+	case http.MethodGet:
+		session := h.requireSession(w, r)
+		if session == nil {
+			return
+		}
+		stream, ok := newEventStream(w)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+		h.stream(r.Context(), stream, session)
+	case http.MethodDelete:
+		session := h.requireSession(w, r)
+		if session == nil {
+			return
+		}
+		h.closeSession(session)
+		slog.Info("http session closed", "session", session.id)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// servePost handles a message or a batch of messages of the streamable
+// HTTP transport. Responses to requests are returned as JSON, or as an event
+// stream that also carries the progress of the requests when the client
+// accepts one.
+func (h *Handler) servePost(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxMessageSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	batch := bytes.HasPrefix(bytes.TrimSpace(body), []byte("["))
+	var lines []json.RawMessage
+	if batch {
+		err = json.Unmarshal(body, &lines)
+	} else {
+		lines = []json.RawMessage{body}
+	}
+	if err != nil || len(lines) == 0 {
+		http.Error(w, "invalid JSON-RPC batch", http.StatusBadRequest)
+		return
+	}
+
+	initialize := false
+	requests := 0
+	for _, line := range lines {
+		var msg message
+		if err := json.Unmarshal(line, &msg); err != nil {
+			requests++ // Answered with a parse error
+			continue
+		}
+		if msg.Method == string(mcp.MethodInitialize) {
+			initialize = true
+		}
+		if len(msg.ID) > 0 && string(msg.ID) != "null" && msg.Method != "" {
+			requests++
+		}
+	}
+
+	if !h.begin() {
+		http.Error(w, errShuttingDown.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	defer h.wg.Done()
+
+	var session *httpSession
+	if initialize {
+		if session, err = h.newSession(r); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set(headerSessionID, session.id)
+		slog.Info("http session opened", "session", session.id, "remote", r.RemoteAddr, "identity", identityName(session.owner))
+	} else if session = h.requireSession(w, r); session == nil {
+		return
+	}
+
+	rs := &requestSession{httpSession: session, notifications: make(chan mcp.JSONRPCNotification, 100)}
+	ctx := h.server.WithContext(session.ctx, rs)
+
+	responses := make(chan any, len(lines))
+	write := func(v any) error {
+		responses <- v
+		return nil
+	}
+
+	var ids []string
+	for _, line := range lines {
+		if id := handle(ctx, h.server, write, session.pending, &h.wg, line); id != "" {
+			ids = append(ids, id)
+		}
+	}
+
+	if requests == 0 {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	// The client no longer waits for the requests it disconnected from
+	defer func() {
+		if r.Context().Err() != nil {
+			for _, id := range ids {
+				session.pending.cancel(id, "client disconnected")
+			}
+		}
+	}()
+
+	if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		stream, ok := newEventStream(w)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+		for received := 0; received < requests; {
+			select {
+			case notification := <-rs.notifications:
+				if err := stream.message(notification); err != nil {
+					return
+				}
+			case response := <-responses:
+				received++
+				if err := stream.message(response); err != nil {
+					return
+				}
+			case <-r.Context().Done():
+				return
+			case <-session.done:
+				return
+			}
+		}
+		return
+	}
+
+	var results []any
+	for len(results) < requests {
+		select {
+		case response := <-responses:
+			results = append(results, response)
+		case <-r.Context().Done():
+			return
+		case <-session.done:
+			http.Error(w, errSessionClosed.Error(), http.StatusNotFound)
+			return
+		}
+	}
+
+	if batch {
+		writeJSON(w, http.StatusOK, results)
+	} else {
+		writeJSON(w, http.StatusOK, results[0])
+	}
+}
+
+// requireSession returns the session named by the Mcp-Session-Id header, or
+// writes an error and returns nil.
+func (h *Handler) requireSession(w http.ResponseWriter, r *http.Request) *httpSession {
+	id := r.Header.Get(headerSessionID)
+	if id == "" {
+		http.Error(w, "missing "+headerSessionID+" header", http.StatusBadRequest)
+		return nil
+	}
+
+	return h.session(w, r, id)
+}
+
+// stream writes the messages of a session until ctx or the session is done.
+func (h *Handler) stream(ctx context.Context, stream *eventStream, session *httpSession) {
+	for {
+		select {
+		case data := <-session.events:
+			if err := stream.event("message", data); err != nil {
+				return
+			}
+		case <-ctx.Done():
+			return
+		case <-session.done:
+			return
+		}
+	}
+}
+
+// eventStream writes server-sent events.
+type eventStream struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+func newEventStream(w http.ResponseWriter) (*eventStream, bool) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, false
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	return &eventStream{w: w, flusher: flusher}, true
+}
+
+func (s *eventStream) event(name string, data []byte) error {
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", name, data); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+func (s *eventStream) message(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.event("message", data)
+}
+
+func identityName(id *auth.Identity) string {
+	if id == nil {
+		return ""
+	}
+	return id.Name
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("error writing response", "error", err)
+	}
+}
+
+// SSE serves s with the HTTP+SSE transport until ctx is done.
+func SSE(ctx context.Context, s *server.MCPServer, opts HTTPOptions) error {
+	return listenAndServe(ctx, NewSSEHandler(s, opts), opts)
+}
+
+// StreamableHTTP serves s with the streamable HTTP transport until ctx is
+// done.
+func StreamableHTTP(ctx context.Context, s *server.MCPServer, opts HTTPOptions) error {
+	return listenAndServe(ctx, NewStreamableHandler(s, opts), opts)
+}
+
+// listenAndServe serves h on opts.Addr. When ctx is done it stops accepting
+// messages and gives the requests being handled opts.ShutdownTimeout to
+// finish before closing the connections.
+func listenAndServe(ctx context.Context, h *Handler, opts HTTPOptions) error {
+	srv := &http.Server{
+		Addr:              opts.Addr,
+		Handler:           h,
+		ReadHeaderTimeout: 10 * time.Second,
+		TLSConfig:         opts.TLS,
+	}
+
+	served := make(chan error, 1)
+	go func() {
+		if opts.TLS != nil {
+			served <- srv.ListenAndServeTLS("", "")
+		} else {
+			served <- srv.ListenAndServe()
+		}
+	}()
+	slog.Info("listening", "address", opts.Addr, "base_path", opts.BasePath, "streamable", h.streamable,
+		"tls", opts.TLS != nil, "auth", opts.Auth != nil)
+
+	select {
+	case err := <-served:
+		h.cancel()
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutting down, waiting for requests", "timeout", opts.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), opts.ShutdownTimeout)
+	defer cancel()
+
+	err := h.Shutdown(shutdownCtx)
+	if serr := srv.Shutdown(shutdownCtx); err == nil {
+		err = serr
+	}
+	return err
+}
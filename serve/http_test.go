@@ -0,0 +1,316 @@
+package serve_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mcp-android-adb-server/auth"
+	"mcp-android-adb-server/serve"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const initializeRequest = `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05","capabilities":{},"clientInfo":{"name":"test","version":"1.0.0"}}}`
+
+// newSlowServer returns a server with a slow tool that reports progress and
+// finishes when release is closed.
+func newSlowServer(release <-chan struct{}) *server.MCPServer {
+	s := server.NewMCPServer("test", "1.0.0")
+	s.AddTool(mcp.NewTool("slow"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if request.Params.Meta != nil && request.Params.Meta.ProgressToken != nil {
+			_ = server.ServerFromContext(ctx).SendNotificationToClient(ctx, "notifications/progress", map[string]any{
+				"progressToken": request.Params.Meta.ProgressToken,
+				"progress":      1,
+			})
+		}
+		select {
+		case <-release:
+			return mcp.NewToolResultText("finished"), nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	})
+	return s
+}
+
+func post(t *testing.T, url, session, accept, body string) *http.Response {
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", accept)
+	if session != "" {
+		req.Header.Set("Mcp-Session-Id", session)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to post: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func decode(t *testing.T, r io.Reader) map[string]any {
+	var v map[string]any
+	if err := json.NewDecoder(r).Decode(&v); err != nil {
+		t.Fatalf("Invalid response: %v", err)
+	}
+	return v
+}
+
+// readEvent reads the next server-sent event.
+func readEvent(t *testing.T, r *bufio.Reader) (name, data string) {
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Failed to read event: %v", err)
+		}
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		case line == "" && name != "":
+			return name, data
+		}
+	}
+}
+
+// TestStreamableHTTP tests sessions, JSON responses and streamed progress
+func TestStreamableHTTP(t *testing.T) {
+	release := make(chan struct{})
+	close(release)
+
+	ts := httptest.NewServer(serve.NewStreamableHandler(newSlowServer(release), serve.HTTPOptions{BasePath: "/android"}))
+	defer ts.Close()
+	url := ts.URL + "/android/mcp"
+
+	resp := post(t, url, "", "application/json", initializeRequest)
+	session := resp.Header.Get("Mcp-Session-Id")
+	if resp.StatusCode != http.StatusOK || session == "" {
+		t.Fatalf("Expected 200 with a session id, got %d %q", resp.StatusCode, session)
+	}
+	if result, _ := decode(t, resp.Body)["result"].(map[string]any); result["serverInfo"] == nil {
+		t.Fatalf("Expected an initialize result, got %v", result)
+	}
+
+	if resp := post(t, url, session, "application/json", `{"jsonrpc":"2.0","method":"notifications/initialized"}`); resp.StatusCode != http.StatusAccepted {
+		t.Errorf("Expected 202 for a notification, got %d", resp.StatusCode)
+	}
+	if resp := post(t, url, "", "application/json", `{"jsonrpc":"2.0","id":2,"method":"ping"}`); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 without a session, got %d", resp.StatusCode)
+	}
+	if resp := post(t, url, "unknown", "application/json", `{"jsonrpc":"2.0","id":2,"method":"ping"}`); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown session, got %d", resp.StatusCode)
+	}
+
+	resp = post(t, url, session, "application/json", `[{"jsonrpc":"2.0","id":3,"method":"ping"},{"jsonrpc":"2.0","id":4,"method":"ping"}]`)
+	var batch []map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&batch); err != nil || len(batch) != 2 {
+		t.Errorf("Expected two batch responses, got %v (%v)", batch, err)
+	}
+
+	resp = post(t, url, session, "application/json, text/event-stream",
+		`{"jsonrpc":"2.0","id":5,"method":"tools/call","params":{"name":"slow","arguments":{},"_meta":{"progressToken":"p1"}}}`)
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %q", ct)
+	}
+	events := bufio.NewReader(resp.Body)
+	if _, data := readEvent(t, events); !strings.Contains(data, "notifications/progress") {
+		t.Errorf("Expected a progress notification first, got %s", data)
+	}
+	if _, data := readEvent(t, events); !strings.Contains(data, `"id":5`) || !strings.Contains(data, "finished") {
+		t.Errorf("Expected the tool result, got %s", data)
+	}
+}
+
+// TestSSE tests that responses of the HTTP+SSE transport arrive on the
+// event stream
+func TestSSE(t *testing.T) {
+	ts := httptest.NewServer(serve.NewSSEHandler(newSlowServer(nil), serve.HTTPOptions{}))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/sse")
+	if err != nil {
+		t.Fatalf("Failed to open event stream: %v", err)
+	}
+	defer resp.Body.Close()
+	events := bufio.NewReader(resp.Body)
+
+	name, endpoint := readEvent(t, events)
+	if name != "endpoint" || !strings.HasPrefix(endpoint, "/message?sessionId=") {
+		t.Fatalf("Expected the endpoint event, got %s %s", name, endpoint)
+	}
+
+	if resp := post(t, ts.URL+endpoint, "", "application/json", `{"jsonrpc":"2.0","id":7,"method":"ping"}`); resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d", resp.StatusCode)
+	}
+	if name, data := readEvent(t, events); name != "message" || !strings.Contains(data, `"id":7`) {
+		t.Errorf("Expected the ping response, got %s %s", name, data)
+	}
+
+	if resp := post(t, ts.URL+"/message?sessionId=unknown", "", "application/json", `{"jsonrpc":"2.0","id":8,"method":"ping"}`); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown session, got %d", resp.StatusCode)
+	}
+}
+
+// TestHTTPShutdown tests that shutdown refuses new messages but lets the
+// tool calls in flight finish
+func TestHTTPShutdown(t *testing.T) {
+	release := make(chan struct{})
+	h := serve.NewStreamableHandler(newSlowServer(release), serve.HTTPOptions{})
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+	url := ts.URL + "/mcp"
+
+	session := post(t, url, "", "application/json", initializeRequest).Header.Get("Mcp-Session-Id")
+
+	result := make(chan map[string]any, 1)
+	go func() {
+		req, _ := http.NewRequest(http.MethodPost, url, strings.NewReader(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"slow","arguments":{}}}`))
+		req.Header.Set("Mcp-Session-Id", session)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			result <- nil
+			return
+		}
+		defer resp.Body.Close()
+		var v map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&v)
+		result <- v
+	}()
+
+	// Wait for the tool call to start
+	time.Sleep(100 * time.Millisecond)
+
+	shutdown := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		shutdown <- h.Shutdown(ctx)
+	}()
+
+	// Wait for the shutdown to start
+	time.Sleep(100 * time.Millisecond)
+	if resp := post(t, url, session, "application/json", `{"jsonrpc":"2.0","id":3,"method":"ping"}`); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 while shutting down, got %d", resp.StatusCode)
+	}
+
+	close(release)
+	if v := <-result; v == nil || v["result"] == nil {
+		t.Errorf("Expected the tool call to finish, got %v", v)
+	}
+	if err := <-shutdown; err != nil {
+		t.Errorf("Shutdown returned error: %v", err)
+	}
+}
+
+// TestHTTPAuth tests that clients authenticate, that tool calls see their
+// identity and that a session only serves the client that opened it
+func TestHTTPAuth(t *testing.T) {
+	a, err := auth.New([]auth.Identity{
+		{Name: "alice", Token: "alice-token", Scope: auth.ScopeAdmin},
+		{Name: "bob", Token: "bob-token", Scope: auth.ScopeRead},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := server.NewMCPServer("test", "1.0.0")
+	s.AddTool(mcp.NewTool("whoami"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText(auth.FromContext(ctx).Name), nil
+	})
+
+	ts := httptest.NewServer(serve.NewStreamableHandler(s, serve.HTTPOptions{Auth: a}))
+	defer ts.Close()
+
+	send := func(token, session, body string) *http.Response {
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/mcp", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		if session != "" {
+			req.Header.Set("Mcp-Session-Id", session)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to post: %v", err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	if resp := send("wrong", "", initializeRequest); resp.StatusCode != http.StatusUnauthorized || resp.Header.Get("WWW-Authenticate") == "" {
+		t.Errorf("Expected 401 with a challenge, got %d", resp.StatusCode)
+	}
+
+	session := send("alice-token", "", initializeRequest).Header.Get("Mcp-Session-Id")
+	if session == "" {
+		t.Fatal("Expected a session")
+	}
+
+	resp := send("alice-token", session, `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"whoami","arguments":{}}}`)
+	if v := decode(t, resp.Body); !strings.Contains(fmt.Sprint(v["result"]), "alice") {
+		t.Errorf("Expected the tool to see alice, got %v", v)
+	}
+
+	if resp := send("bob-token", session, `{"jsonrpc":"2.0","id":3,"method":"ping"}`); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected 403 for the session of another client, got %d", resp.StatusCode)
+	}
+}
+
+// TestOrigin tests that requests from web pages of other origins are
+// rejected, including pages that reach the server through DNS rebinding
+func TestOrigin(t *testing.T) {
+	ts := httptest.NewServer(serve.NewStreamableHandler(newSlowServer(nil), serve.HTTPOptions{
+		Addr:           "mcp.internal:8080",
+		AllowedOrigins: []string{"https://App.example.com/"},
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		origin   string
+		host     string
+		expected int
+	}{
+		{"", "", http.StatusOK},
+		{ts.URL, "", http.StatusOK},
+		{"http://localhost:8080", "localhost:8080", http.StatusOK},
+		{"http://mcp.internal:8080", "mcp.internal:8080", http.StatusOK},
+		{"https://app.example.com", "", http.StatusOK},
+		{"https://app.example.com", "192.168.1.5:8080", http.StatusOK},
+		{"https://evil.example", "", http.StatusForbidden},
+		{"http://evil.example", "evil.example", http.StatusForbidden},
+		{"http://localhost.evil", "localhost.evil", http.StatusForbidden},
+		{"null", "", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/mcp", strings.NewReader(initializeRequest))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json, text/event-stream")
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		if tt.host != "" {
+			req.Host = tt.host
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to post: %v", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != tt.expected {
+			t.Errorf("Origin %q, Host %q: expected status %d, got %d", tt.origin, tt.host, tt.expected, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+// Package serve runs the MCP server over its transports. Requests are
+// handled concurrently so that a long tool call neither blocks other
+// requests nor its own cancellation by the client.
+package serve
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// stdioSession is the single client session of the stdio transport.
+type stdioSession struct {
+	notifications chan mcp.JSONRPCNotification
+	initialized   atomic.Bool
+}
+
+func (s *stdioSession) SessionID() string {
+	return "stdio"
+}
+
+func (s *stdioSession) NotificationChannel() chan<- mcp.JSONRPCNotification {
+	return s.notifications
+}
+
+func (s *stdioSession) Initialize() {
+	s.initialized.Store(true)
+}
+
+func (s *stdioSession) Initialized() bool {
+	return s.initialized.Load()
+}
+
+// Stdio serves s on in and out until ctx is done or in is closed. Each
+// request is handled in its own goroutine with a context that is cancelled
+// when the client sends notifications/cancelled for it.
+func Stdio(ctx context.Context, s *server.MCPServer, in io.Reader, out io.Writer) error {
+	session := &stdioSession{notifications: make(chan mcp.JSONRPCNotification, 100)}
+	if err := s.RegisterSession(session); err != nil {
+		return fmt.Errorf("register session: %w", err)
+	}
+	defer s.UnregisterSession(session.SessionID())
+
+	ctx, cancel := context.WithCancel(s.WithContext(ctx, session))
+	defer cancel()
+
+	w := &lineWriter{w: out}
+	go func() {
+		for {
+			select {
+			case notification := <-session.notifications:
+				if err := w.write(notification); err != nil {
+					slog.Error("error writing notification", "error", err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	lines := make(chan []byte)
+	readErr := make(chan error, 1)
+	go func() {
+		reader := bufio.NewReader(in)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if len(bytes.TrimSpace(line)) > 0 {
+				select {
+				case lines <- line:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if err != nil {
+				readErr <- err
+				return
+			}
+		}
+	}()
+
+	pending := &inflight{requests: make(map[string]*request)}
+	var wg sync.WaitGroup
+	defer func() {
+		// The client is gone, stop the requests it is no longer waiting for
+		cancel()
+		wg.Wait()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-readErr:
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		case line := <-lines:
+			handle(ctx, s, w.write, pending, &wg, line)
+		}
+	}
+}
+
+// lineWriter writes newline delimited JSON messages.
+type lineWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (w *lineWriter) write(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	_, err = fmt.Fprintf(w.w, "%s\n", data)
+	return err
+}
@@ -0,0 +1,97 @@
+package serve_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"mcp-android-adb-server/serve"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// client drives the stdio transport through pipes.
+type client struct {
+	in  *io.PipeWriter
+	out *bufio.Reader
+}
+
+func newClient(t *testing.T, s *server.MCPServer) *client {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- serve.Stdio(context.Background(), s, inR, outW)
+		outW.Close()
+	}()
+	t.Cleanup(func() {
+		inW.Close()
+		if err := <-done; err != nil {
+			t.Errorf("Stdio returned error: %v", err)
+		}
+	})
+
+	return &client{in: inW, out: bufio.NewReader(outR)}
+}
+
+func (c *client) send(t *testing.T, msg string) {
+	if _, err := io.WriteString(c.in, msg+"\n"); err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+}
+
+func (c *client) receive(t *testing.T) map[string]any {
+	line, err := c.out.ReadBytes('\n')
+	if err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+
+	var response map[string]any
+	if err := json.Unmarshal(line, &response); err != nil {
+		t.Fatalf("Invalid response %q: %v", line, err)
+	}
+	return response
+}
+
+// TestStdioCancel tests that a slow tool call does not block other requests
+// and is cancelled by notifications/cancelled
+func TestStdioCancel(t *testing.T) {
+	cancelled := make(chan struct{})
+
+	s := server.NewMCPServer("test", "1.0.0")
+	s.AddTool(mcp.NewTool("slow"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		select {
+		case <-ctx.Done():
+			close(cancelled)
+			return nil, ctx.Err()
+		case <-time.After(10 * time.Second):
+			return mcp.NewToolResultText("finished"), nil
+		}
+	})
+
+	c := newClient(t, s)
+	c.send(t, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"slow","arguments":{}}}`)
+	c.send(t, `{"jsonrpc":"2.0","id":2,"method":"ping"}`)
+
+	if response := c.receive(t); response["id"] != float64(2) {
+		t.Fatalf("Expected the ping response first, got %v", response)
+	}
+
+	c.send(t, `{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":1,"reason":"user aborted"}}`)
+
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Tool call was not cancelled")
+	}
+
+	// The cancelled request gets no response, the next one does
+	c.send(t, `{"jsonrpc":"2.0","id":3,"method":"ping"}`)
+	if response := c.receive(t); response["id"] != float64(3) {
+		t.Errorf("Expected the response to request 3, got %v", response)
+	}
+}
@@ -0,0 +1,39 @@
+// Package shellquote builds command lines for the device shell.
+package shellquote
+
+import "strings"
+
+// Quote quotes s so that the device shell treats it as a single word.
+func Quote(s string) string {
+	if s == "" {
+		return "''"
+	}
+
+	if strings.IndexFunc(s, needsQuote) < 0 {
+		return s
+	}
+
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Command builds a shell command line from a trusted command, e.g.
+// "pm path", and arguments that are quoted so each stays a single word.
+func Command(cmd string, args ...string) string {
+	words := make([]string, 0, len(args)+1)
+	words = append(words, cmd)
+	for _, arg := range args {
+		words = append(words, Quote(arg))
+	}
+
+	return strings.Join(words, " ")
+}
+
+// needsQuote reports whether r has a special meaning to the shell.
+func needsQuote(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return false
+	}
+
+	return !strings.ContainsRune("-_./:=,+@%", r)
+}
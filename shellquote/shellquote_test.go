@@ -0,0 +1,29 @@
+package shellquote_test
+
+import (
+	"mcp-android-adb-server/shellquote"
+	"os/exec"
+	"testing"
+)
+
+// TestQuote tests that quoted words reach the shell unchanged
+func TestQuote(t *testing.T) {
+	for _, word := range []string{"", "com.example.app", "a b", "it's", "$(reboot)", "`id`", "a;b|c&d", "*", "\\n", "\"x\""} {
+		out, err := exec.Command("sh", "-c", "printf %s "+shellquote.Quote(word)).Output()
+		if err != nil {
+			t.Fatalf("Failed to run shell for %q: %v", word, err)
+		}
+		if string(out) != word {
+			t.Errorf("Word changed by the shell: expected %q, got %q", word, out)
+		}
+	}
+}
+
+// TestCommand tests that only the arguments are quoted
+func TestCommand(t *testing.T) {
+	got := shellquote.Command("dumpsys gfxinfo", "com.example.app; reboot", "reset")
+	expected := "dumpsys gfxinfo 'com.example.app; reboot' reset"
+	if got != expected {
+		t.Errorf("Command does not match expected: expected %q, got %q", expected, got)
+	}
+}
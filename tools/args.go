@@ -0,0 +1,55 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// optionalString returns a string argument, or def if it is absent
+func optionalString(request mcp.CallToolRequest, name, def string) string {
+	if v, ok := request.Params.Arguments[name].(string); ok {
+		return v
+	}
+	return def
+}
+
+// optionalNumber returns a number argument, or def if it is absent
+func optionalNumber(request mcp.CallToolRequest, name string, def float64) float64 {
+	if v, ok := request.Params.Arguments[name].(float64); ok {
+		return v
+	}
+	return def
+}
+
+// optionalBool returns a boolean argument, or def if it is absent
+func optionalBool(request mcp.CallToolRequest, name string, def bool) bool {
+	if v, ok := request.Params.Arguments[name].(bool); ok {
+		return v
+	}
+	return def
+}
+
+// optionalStrings returns a string array argument, or nil if it is absent
+func optionalStrings(request mcp.CallToolRequest, name string) []string {
+	items, _ := request.Params.Arguments[name].([]interface{})
+
+	var values []string
+	for _, item := range items {
+		if v, ok := item.(string); ok {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// jsonResult converts v to a JSON text result
+func jsonResult(v any) (*mcp.CallToolResult, error) {
+	jsonString, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert result to JSON: %w", err)
+	}
+
+	return mcp.NewToolResultText(string(jsonString)), nil
+}
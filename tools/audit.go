@@ -0,0 +1,61 @@
+package tools
+
+import (
+	"context"
+	"log/slog"
+	"mcp-android-adb-server/auth"
+	"slices"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Audit records every tool call with the identity of the client, the
+// default logger if nil
+var Audit *slog.Logger
+
+// AuditRedact names the arguments whose values are kept out of the audit
+// log, either "argument" for every tool or "tool.argument", e.g. the pairing
+// code, typed text and setting values
+var AuditRedact = []string{"code", "text", "put_setting.value"}
+
+// redactedValue replaces the value of a redacted argument
+const redactedValue = "[redacted]"
+
+// audit records a tool call, clients without an identity are local
+func audit(ctx context.Context, name string, request mcp.CallToolRequest, started time.Time, result *mcp.CallToolResult, err error) {
+	logger := Audit
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	identity := "local"
+	if id := auth.FromContext(ctx); id != nil {
+		identity = id.Name
+	}
+
+	attrs := []any{
+		"identity", identity,
+		"tool", name,
+		"arguments", redactArguments(name, request.Params.Arguments),
+		"duration", time.Since(started),
+	}
+	if err != nil {
+		logger.Warn("tool call failed", append(attrs, "error", err)...)
+		return
+	}
+	logger.Info("tool call", append(attrs, "is_error", result != nil && result.IsError)...)
+}
+
+// redactArguments returns a copy of the arguments of a tool call with the
+// values named by AuditRedact replaced
+func redactArguments(tool string, arguments map[string]interface{}) map[string]interface{} {
+	redacted := make(map[string]interface{}, len(arguments))
+	for name, value := range arguments {
+		if slices.Contains(AuditRedact, name) || slices.Contains(AuditRedact, tool+"."+name) {
+			value = redactedValue
+		}
+		redacted[name] = value
+	}
+	return redacted
+}
@@ -0,0 +1,47 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"mcp-android-adb-server/device"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// AddToolGetClipboard adds a tool for reading the clipboard
+func AddToolGetClipboard(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("get_clipboard",
+		mcp.WithDescription("Get the text currently on the Android device clipboard"),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		text, err := d.Clipboard()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get clipboard: %w", err)
+		}
+
+		return mcp.NewToolResultText(text), nil
+	})
+}
+
+// AddToolSetClipboard adds a tool for writing the clipboard
+func AddToolSetClipboard(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("set_clipboard",
+		mcp.WithDescription("Put text on the Android device clipboard"),
+		mcp.WithString("text",
+			mcp.Required(),
+			mcp.Description("Text to copy to the clipboard"),
+		),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		text := request.Params.Arguments["text"].(string)
+
+		if err := d.SetClipboard(text); err != nil {
+			return nil, fmt.Errorf("failed to set clipboard: %w", err)
+		}
+
+		return mcp.NewToolResultText("Clipboard set"), nil
+	})
+}
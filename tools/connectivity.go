@@ -0,0 +1,70 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"mcp-android-adb-server/device"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// addRadioTool adds a tool toggling a radio of the device
+func addRadioTool(s *server.MCPServer, d *device.AndroidDevice, name, label string, set func(*device.AndroidDevice, bool) error) {
+	addTool(s, d, mcp.NewTool(name,
+		mcp.WithDescription(fmt.Sprintf("Enable or disable %s on the Android device. The original state is restored when the server exits", label)),
+		mcp.WithBoolean("enabled",
+			mcp.Required(),
+			mcp.Description(fmt.Sprintf("Whether %s is enabled", label)),
+		),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		enabled := request.Params.Arguments["enabled"].(bool)
+
+		if err := set(d, enabled); err != nil {
+			return nil, fmt.Errorf("failed to set %s: %w", label, err)
+		}
+
+		if enabled {
+			return mcp.NewToolResultText(fmt.Sprintf("%s enabled", label)), nil
+		}
+		return mcp.NewToolResultText(fmt.Sprintf("%s disabled", label)), nil
+	})
+}
+
+// AddToolSetWifi adds a tool for toggling Wi-Fi
+func AddToolSetWifi(s *server.MCPServer, d *device.AndroidDevice) {
+	addRadioTool(s, d, "set_wifi", "Wi-Fi", (*device.AndroidDevice).SetWifi)
+}
+
+// AddToolSetMobileData adds a tool for toggling mobile data
+func AddToolSetMobileData(s *server.MCPServer, d *device.AndroidDevice) {
+	addRadioTool(s, d, "set_mobile_data", "Mobile data", (*device.AndroidDevice).SetMobileData)
+}
+
+// AddToolSetAirplaneMode adds a tool for toggling airplane mode
+func AddToolSetAirplaneMode(s *server.MCPServer, d *device.AndroidDevice) {
+	addRadioTool(s, d, "set_airplane_mode", "Airplane mode", (*device.AndroidDevice).SetAirplaneMode)
+}
+
+// AddToolSetBluetooth adds a tool for toggling Bluetooth
+func AddToolSetBluetooth(s *server.MCPServer, d *device.AndroidDevice) {
+	addRadioTool(s, d, "set_bluetooth", "Bluetooth", (*device.AndroidDevice).SetBluetooth)
+}
+
+// AddToolNetworkStatus adds a tool for getting the network status
+func AddToolNetworkStatus(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("network_status",
+		mcp.WithDescription("Get the network status of the Android device as JSON: active network, IP addresses, DNS servers, validation and radio states"),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		status, err := d.NetworkStatus()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get network status: %w", err)
+		}
+
+		return jsonResult(status)
+	})
+}
@@ -0,0 +1,156 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"mcp-android-adb-server/device"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// AddToolSetRotation adds a tool for locking the screen rotation
+func AddToolSetRotation(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("set_rotation",
+		mcp.WithDescription("Lock the Android device screen to a rotation, disabling auto-rotate"),
+		mcp.WithNumber("rotation",
+			mcp.Required(),
+			mcp.Description("Rotation in degrees: 0 (natural), 90, 180 or 270"),
+		),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		rotation := int(request.Params.Arguments["rotation"].(float64))
+
+		if err := d.SetRotation(rotation); err != nil {
+			return nil, fmt.Errorf("failed to set rotation: %w", err)
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("Screen rotation locked to %d", rotation)), nil
+	})
+}
+
+// AddToolSetAutoRotate adds a tool for toggling auto-rotate
+func AddToolSetAutoRotate(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("set_auto_rotate",
+		mcp.WithDescription("Enable or disable automatic screen rotation on the Android device"),
+		mcp.WithBoolean("enabled",
+			mcp.Required(),
+			mcp.Description("Whether the screen follows the accelerometer"),
+		),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		enabled := request.Params.Arguments["enabled"].(bool)
+
+		if err := d.SetAutoRotate(enabled); err != nil {
+			return nil, fmt.Errorf("failed to set auto-rotate: %w", err)
+		}
+
+		if enabled {
+			return mcp.NewToolResultText("Auto-rotate enabled"), nil
+		}
+		return mcp.NewToolResultText("Auto-rotate disabled"), nil
+	})
+}
+
+// AddToolSetScreenSize adds a tool for overriding the screen size
+func AddToolSetScreenSize(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("set_screen_size",
+		mcp.WithDescription("Override the screen resolution of the Android device in its natural orientation"),
+		mcp.WithNumber("width",
+			mcp.Required(),
+			mcp.Description("Screen width in pixels"),
+		),
+		mcp.WithNumber("height",
+			mcp.Required(),
+			mcp.Description("Screen height in pixels"),
+		),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		width := int(request.Params.Arguments["width"].(float64))
+		height := int(request.Params.Arguments["height"].(float64))
+
+		if err := d.SetScreenSize(width, height); err != nil {
+			return nil, fmt.Errorf("failed to set screen size: %w", err)
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("Screen size overridden to %dx%d", width, height)), nil
+	})
+}
+
+// AddToolResetScreenSize adds a tool for resetting the screen size
+func AddToolResetScreenSize(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("reset_screen_size",
+		mcp.WithDescription("Reset the screen resolution of the Android device to its physical size"),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		if err := d.ResetScreenSize(); err != nil {
+			return nil, fmt.Errorf("failed to reset screen size: %w", err)
+		}
+
+		return mcp.NewToolResultText("Screen size reset"), nil
+	})
+}
+
+// AddToolSetScreenDensity adds a tool for overriding the screen density
+func AddToolSetScreenDensity(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("set_screen_density",
+		mcp.WithDescription("Override the screen density (DPI) of the Android device"),
+		mcp.WithNumber("dpi",
+			mcp.Required(),
+			mcp.Description("Screen density, e.g. 320"),
+		),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		dpi := int(request.Params.Arguments["dpi"].(float64))
+
+		if err := d.SetScreenDensity(dpi); err != nil {
+			return nil, fmt.Errorf("failed to set screen density: %w", err)
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("Screen density overridden to %d", dpi)), nil
+	})
+}
+
+// AddToolResetScreenDensity adds a tool for resetting the screen density
+func AddToolResetScreenDensity(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("reset_screen_density",
+		mcp.WithDescription("Reset the screen density of the Android device to its physical density"),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		if err := d.ResetScreenDensity(); err != nil {
+			return nil, fmt.Errorf("failed to reset screen density: %w", err)
+		}
+
+		return mcp.NewToolResultText("Screen density reset"), nil
+	})
+}
+
+// AddToolSetDarkMode adds a tool for toggling the dark theme
+func AddToolSetDarkMode(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("set_dark_mode",
+		mcp.WithDescription("Enable or disable the dark theme on the Android device"),
+		mcp.WithBoolean("enabled",
+			mcp.Required(),
+			mcp.Description("Whether the dark theme is enabled"),
+		),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		enabled := request.Params.Arguments["enabled"].(bool)
+
+		if err := d.SetDarkMode(enabled); err != nil {
+			return nil, fmt.Errorf("failed to set dark mode: %w", err)
+		}
+
+		if enabled {
+			return mcp.NewToolResultText("Dark mode enabled"), nil
+		}
+		return mcp.NewToolResultText("Dark mode disabled"), nil
+	})
+}
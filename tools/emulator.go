@@ -0,0 +1,269 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"mcp-android-adb-server/device"
+	"mcp-android-adb-server/emulator"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// withConsole runs fn on a console connection to the emulator of d
+func withConsole(d *device.AndroidDevice, fn func(c *emulator.Console) error) error {
+	c, err := emulator.DialSerial(d.Serial())
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	return fn(c)
+}
+
+// AddToolEmulatorGeoFix adds a tool for setting the emulator GPS location
+func AddToolEmulatorGeoFix(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("emulator_geo_fix",
+		mcp.WithDescription("Set the GPS location reported by the emulator"),
+		mcp.WithNumber("latitude",
+			mcp.Required(),
+			mcp.Min(-90),
+			mcp.Max(90),
+			mcp.Description("Latitude in degrees"),
+		),
+		mcp.WithNumber("longitude",
+			mcp.Required(),
+			mcp.Min(-180),
+			mcp.Max(180),
+			mcp.Description("Longitude in degrees"),
+		),
+		mcp.WithNumber("altitude",
+			mcp.DefaultNumber(0),
+			mcp.Description("Altitude in meters, default is 0"),
+		),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		latitude := request.Params.Arguments["latitude"].(float64)
+		longitude := request.Params.Arguments["longitude"].(float64)
+		altitude := optionalNumber(request, "altitude", 0)
+
+		if err := withConsole(d, func(c *emulator.Console) error {
+			return c.GeoFix(latitude, longitude, altitude)
+		}); err != nil {
+			return nil, fmt.Errorf("failed to set location: %w", err)
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("Location set to %g,%g", latitude, longitude)), nil
+	})
+}
+
+// AddToolEmulatorSMS adds a tool for simulating an incoming SMS
+func AddToolEmulatorSMS(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("emulator_sms",
+		mcp.WithDescription("Simulate an incoming SMS on the emulator"),
+		mcp.WithString("from",
+			mcp.Required(),
+			mcp.Description("Sender phone number, e.g. 5551234"),
+		),
+		mcp.WithString("text",
+			mcp.Required(),
+			mcp.Description("Message text"),
+		),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		from := request.Params.Arguments["from"].(string)
+		text := request.Params.Arguments["text"].(string)
+
+		if err := withConsole(d, func(c *emulator.Console) error {
+			return c.SendSMS(from, text)
+		}); err != nil {
+			return nil, fmt.Errorf("failed to send SMS: %w", err)
+		}
+
+		return mcp.NewToolResultText("SMS sent"), nil
+	})
+}
+
+// AddToolEmulatorCall adds a tool for simulating phone calls
+func AddToolEmulatorCall(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("emulator_call",
+		mcp.WithDescription("Simulate an incoming phone call on the emulator, or accept, reject (busy), hang up (cancel) or hold it"),
+		mcp.WithString("action",
+			mcp.Enum(emulator.CallActions...),
+			mcp.DefaultString("call"),
+			mcp.Description("call starts an incoming call, default is call"),
+		),
+		mcp.WithString("number",
+			mcp.Description("Caller phone number, required except for hold"),
+		),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		action := optionalString(request, "action", "call")
+		number := optionalString(request, "number", "")
+
+		if err := withConsole(d, func(c *emulator.Console) error {
+			return c.Call(action, number)
+		}); err != nil {
+			return nil, fmt.Errorf("failed to simulate call: %w", err)
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("Call %s done", action)), nil
+	})
+}
+
+// AddToolEmulatorNetwork adds a tool for emulating network conditions
+func AddToolEmulatorNetwork(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("emulator_network",
+		mcp.WithDescription("Emulate the network speed and latency of a mobile network on the emulator. Use full and none to remove the limits"),
+		mcp.WithString("speed",
+			mcp.Enum(emulator.NetworkSpeeds...),
+			mcp.Description("Speed profile, omit to keep the current speed"),
+		),
+		mcp.WithString("delay",
+			mcp.Enum(emulator.NetworkDelays...),
+			mcp.Description("Latency profile, omit to keep the current latency"),
+		),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		speed := optionalString(request, "speed", "")
+		delay := optionalString(request, "delay", "")
+		if speed == "" && delay == "" {
+			return nil, fmt.Errorf("speed or delay is required")
+		}
+
+		if err := withConsole(d, func(c *emulator.Console) error {
+			if speed != "" {
+				if err := c.SetNetworkSpeed(speed); err != nil {
+					return err
+				}
+			}
+			if delay != "" {
+				return c.SetNetworkDelay(delay)
+			}
+			return nil
+		}); err != nil {
+			return nil, fmt.Errorf("failed to set network profile: %w", err)
+		}
+
+		return mcp.NewToolResultText("Network profile applied"), nil
+	})
+}
+
+// AddToolEmulatorSensor adds a tool for setting emulator sensor values
+func AddToolEmulatorSensor(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("emulator_sensor",
+		mcp.WithDescription("Set the values of an emulator sensor such as acceleration, gyroscope, magnetic-field, orientation, temperature, proximity, light, pressure or humidity. Omit values to list the available sensors"),
+		mcp.WithString("name",
+			mcp.Description("Sensor name, e.g. acceleration"),
+		),
+		mcp.WithString("values",
+			mcp.Description("One to three values separated by colons, e.g. 0:9.81:0"),
+		),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		name := optionalString(request, "name", "")
+		values := optionalString(request, "values", "")
+
+		var result string
+		if err := withConsole(d, func(c *emulator.Console) error {
+			if name == "" || values == "" {
+				sensors, err := c.Sensors()
+				result = "Available sensors: " + strings.Join(sensors, ", ")
+				return err
+			}
+
+			var numbers []float64
+			for _, v := range strings.Split(values, ":") {
+				n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+				if err != nil {
+					return fmt.Errorf("invalid sensor value %q", v)
+				}
+				numbers = append(numbers, n)
+			}
+
+			result = fmt.Sprintf("Sensor %s set to %s", name, values)
+			return c.SetSensor(name, numbers...)
+		}); err != nil {
+			return nil, fmt.Errorf("failed to set sensor: %w", err)
+		}
+
+		return mcp.NewToolResultText(result), nil
+	})
+}
+
+// AddToolEmulatorBattery adds a tool for setting the emulator battery state
+func AddToolEmulatorBattery(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("emulator_battery",
+		mcp.WithDescription("Set the battery level, charger and status of the emulator hardware"),
+		mcp.WithNumber("level",
+			mcp.Min(0),
+			mcp.Max(100),
+			mcp.Description("Battery level percentage, omit to keep the current level"),
+		),
+		mcp.WithBoolean("ac",
+			mcp.Description("Whether the charger is connected, omit to keep the current state"),
+		),
+		mcp.WithString("status",
+			mcp.Enum(emulator.BatteryStatuses...),
+			mcp.Description("Battery status, omit to keep the current status"),
+		),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		level := int(optionalNumber(request, "level", -1))
+		status := optionalString(request, "status", "")
+
+		var ac *bool
+		if v, ok := request.Params.Arguments["ac"].(bool); ok {
+			ac = &v
+		}
+
+		if err := withConsole(d, func(c *emulator.Console) error {
+			return c.SetBattery(level, ac, status)
+		}); err != nil {
+			return nil, fmt.Errorf("failed to set battery: %w", err)
+		}
+
+		return mcp.NewToolResultText("Emulator battery state set"), nil
+	})
+}
+
+// AddToolEmulatorSnapshot adds a tool for managing emulator snapshots
+func AddToolEmulatorSnapshot(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("emulator_snapshot",
+		mcp.WithDescription("Save, load, delete or list emulator snapshots to reset the emulator to a known state"),
+		mcp.WithString("action",
+			mcp.Required(),
+			mcp.Enum(emulator.SnapshotActions...),
+			mcp.Description("Snapshot action"),
+		),
+		mcp.WithString("name",
+			mcp.Description("Snapshot name, required except for list"),
+		),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		action := request.Params.Arguments["action"].(string)
+		name := optionalString(request, "name", "")
+
+		var out string
+		if err := withConsole(d, func(c *emulator.Console) (err error) {
+			out, err = c.Snapshot(action, name)
+			return err
+		}); err != nil {
+			return nil, fmt.Errorf("failed to %s snapshot: %w", action, err)
+		}
+
+		if out == "" {
+			out = fmt.Sprintf("Snapshot %s done", action)
+		}
+		return mcp.NewToolResultText(out), nil
+	})
+}
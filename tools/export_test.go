@@ -0,0 +1,44 @@
+package tools
+
+// Unexported functions exposed to the tests.
+var (
+	AddTool         = addTool
+	CheckIdentity   = checkIdentity
+	CheckPolicy     = checkPolicy
+	RedactArguments = redactArguments
+	ToolClass       = toolClass
+	ToolRisk        = toolRisk
+)
+
+// ToolNames returns the names of every tool.
+func ToolNames() []string {
+	return toolNames
+}
+
+// ListedTools returns the tool names listed by the tool classes, the
+// profiles and the timeouts, keyed by the list naming them.
+func ListedTools() map[string][]string {
+	keys := func(set map[string]bool) []string {
+		names := make([]string, 0, len(set))
+		for name := range set {
+			names = append(names, name)
+		}
+		return names
+	}
+
+	timeouts := make([]string, 0, len(toolTimeouts))
+	for name := range toolTimeouts {
+		timeouts = append(timeouts, name)
+	}
+
+	return map[string][]string{
+		"readTools":        keys(readTools),
+		"unlockedTools":    keys(unlockedTools),
+		"destructiveTools": keys(destructiveTools),
+		"writeTools":       keys(writeTools),
+		"optInTools":       keys(optInTools),
+		"minimalTools":     minimalTools,
+		"appTestingTools":  appTestingTools,
+		"toolTimeouts":     timeouts,
+	}
+}
@@ -0,0 +1,106 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"mcp-android-adb-server/device"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// AddToolForward adds a tool for forwarding a host socket to the device
+func AddToolForward(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("forward",
+		mcp.WithDescription("Forward connections on a host socket to a device socket, e.g. tcp:9222 to localabstract:chrome_devtools_remote for WebView debugging. The forward is removed when the server exits"),
+		mcp.WithString("local",
+			mcp.Required(),
+			mcp.Description("Host socket: tcp:<port> (tcp:0 picks a free port) or localabstract:<name>"),
+		),
+		mcp.WithString("remote",
+			mcp.Required(),
+			mcp.Description("Device socket: tcp:<port>, localabstract:<name> or jdwp:<pid>"),
+		),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		local := request.Params.Arguments["local"].(string)
+		remote := request.Params.Arguments["remote"].(string)
+
+		local, err := d.Forward(local, remote)
+		if err != nil {
+			return nil, fmt.Errorf("failed to forward: %w", err)
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("Forwarding %s to %s", local, remote)), nil
+	})
+}
+
+// AddToolReverse adds a tool for forwarding a device socket to the host
+func AddToolReverse(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("reverse",
+		mcp.WithDescription("Forward connections on a device socket to a host socket, e.g. tcp:8081 to tcp:8081 to reach a local backend from the device. The reverse forward is removed when the server exits"),
+		mcp.WithString("remote",
+			mcp.Required(),
+			mcp.Description("Device socket: tcp:<port> (tcp:0 picks a free port) or localabstract:<name>"),
+		),
+		mcp.WithString("local",
+			mcp.Required(),
+			mcp.Description("Host socket: tcp:<port> or localabstract:<name>"),
+		),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		remote := request.Params.Arguments["remote"].(string)
+		local := request.Params.Arguments["local"].(string)
+
+		remote, err := d.Reverse(remote, local)
+		if err != nil {
+			return nil, fmt.Errorf("failed to reverse: %w", err)
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("Forwarding device %s to host %s", remote, local)), nil
+	})
+}
+
+// AddToolListForwards adds a tool for listing forwards
+func AddToolListForwards(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("list_forwards",
+		mcp.WithDescription("List the forwards and reverse forwards of the Android device as JSON, local is the host socket and remote the device socket"),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		forwards, err := d.ListForwards()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list forwards: %w", err)
+		}
+
+		return jsonResult(forwards)
+	})
+}
+
+// AddToolRemoveForward adds a tool for removing a forward
+func AddToolRemoveForward(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("remove_forward",
+		mcp.WithDescription("Remove a forward by its host socket, or a reverse forward by its device socket"),
+		mcp.WithString("socket",
+			mcp.Required(),
+			mcp.Description("Listening socket of the forward, e.g. tcp:9222"),
+		),
+		mcp.WithBoolean("reverse",
+			mcp.DefaultBool(false),
+			mcp.Description("Whether to remove a reverse forward, default is false"),
+		),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		socket := request.Params.Arguments["socket"].(string)
+		reverse := optionalBool(request, "reverse", false)
+
+		if err := d.RemoveForward(socket, reverse); err != nil {
+			return nil, fmt.Errorf("failed to remove forward: %w", err)
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("Forward %s removed", socket)), nil
+	})
+}
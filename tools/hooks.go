@@ -0,0 +1,91 @@
+package tools
+
+import (
+	"log/slog"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Hooks log the messages handled by the MCP server. The arguments of tool
+// calls are redacted as in the audit log and their results, which may hold
+// device data such as the clipboard, are left out
+func Hooks() *server.Hooks {
+	hooks := &server.Hooks{}
+
+	hooks.AddBeforeAny(func(id any, method mcp.MCPMethod, message any) {
+		slog.Info("before any hook called",
+			"method", method,
+			"id", id,
+			"message", loggedMessage(message))
+	})
+
+	hooks.AddOnSuccess(func(id any, method mcp.MCPMethod, message any, result any) {
+		slog.Info("operation completed successfully",
+			"method", method,
+			"id", id,
+			"message", loggedMessage(message),
+			"result", loggedResult(result))
+	})
+
+	hooks.AddOnError(func(id any, method mcp.MCPMethod, message any, err error) {
+		slog.Error("operation failed",
+			"method", method,
+			"id", id,
+			"message", loggedMessage(message),
+			"error", err)
+	})
+
+	hooks.AddBeforeInitialize(func(id any, message *mcp.InitializeRequest) {
+		slog.Info("initializing",
+			"id", id,
+			"message", message)
+	})
+
+	hooks.AddAfterInitialize(func(id any, message *mcp.InitializeRequest, result *mcp.InitializeResult) {
+		slog.Info("initialization completed",
+			"id", id,
+			"message", message,
+			"result", result)
+	})
+
+	hooks.AddAfterCallTool(func(id any, message *mcp.CallToolRequest, result *mcp.CallToolResult) {
+		slog.Info("tool call completed",
+			"id", id,
+			"message", loggedMessage(message),
+			"result", loggedResult(result))
+	})
+
+	hooks.AddBeforeCallTool(func(id any, message *mcp.CallToolRequest) {
+		slog.Info("calling tool",
+			"id", id,
+			"message", loggedMessage(message))
+	})
+
+	return hooks
+}
+
+// loggedMessage returns a tool call with its arguments redacted, other
+// messages unchanged
+func loggedMessage(message any) any {
+	request, ok := message.(*mcp.CallToolRequest)
+	if !ok || request == nil {
+		return message
+	}
+
+	return map[string]any{
+		"name":      request.Params.Name,
+		"arguments": redactArguments(request.Params.Name, request.Params.Arguments),
+	}
+}
+
+// loggedResult returns whether a tool call failed instead of its content,
+// other results unchanged
+func loggedResult(result any) any {
+	toolResult, ok := result.(*mcp.CallToolResult)
+	if !ok || toolResult == nil {
+		return result
+	}
+
+	return map[string]any{"is_error": toolResult.IsError}
+}
@@ -0,0 +1,136 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"mcp-android-adb-server/device"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// AddToolSetLocale adds a tool for changing the system locale
+func AddToolSetLocale(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("set_locale",
+		mcp.WithDescription("Change the system locale of the Android device. Requires root (e.g. an emulator), otherwise use set_app_locale. Returns whether the change took effect"),
+		mcp.WithString("locale",
+			mcp.Required(),
+			mcp.Description("Language tag, e.g. zh-CN, en-US, ja-JP"),
+		),
+		mcp.WithBoolean("restart",
+			mcp.DefaultBool(false),
+			mcp.Description("Restart the Android framework so running apps pick up the new locale"),
+		),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		locale := request.Params.Arguments["locale"].(string)
+		restart := optionalBool(request, "restart", false)
+
+		result, err := d.SetLocale(locale, restart)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set locale: %w", err)
+		}
+
+		return jsonResult(result)
+	})
+}
+
+// AddToolSetAppLocale adds a tool for changing the locale of a single app
+func AddToolSetAppLocale(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("set_app_locale",
+		mcp.WithDescription("Change the locale of a single application (Android 13+). Returns whether the change took effect"),
+		mcp.WithString("package_name",
+			mcp.Required(),
+			mcp.Description("Android application package name, e.g. com.example.app"),
+		),
+		mcp.WithString("locale",
+			mcp.Required(),
+			mcp.Description("Language tag, e.g. zh-CN, en-US, ja-JP. Empty to follow the system locale"),
+		),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		packageName := request.Params.Arguments["package_name"].(string)
+		locale := request.Params.Arguments["locale"].(string)
+
+		result, err := d.SetAppLocale(packageName, locale)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set app locale: %w", err)
+		}
+
+		return jsonResult(result)
+	})
+}
+
+// AddToolSetTimeZone adds a tool for changing the time zone
+func AddToolSetTimeZone(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("set_time_zone",
+		mcp.WithDescription("Disable automatic time zone and change the time zone of the Android device. Returns whether the change took effect"),
+		mcp.WithString("time_zone",
+			mcp.Required(),
+			mcp.Description("Time zone ID, e.g. Asia/Shanghai, America/New_York, UTC"),
+		),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		zone := request.Params.Arguments["time_zone"].(string)
+
+		result, err := d.SetTimeZone(zone)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set time zone: %w", err)
+		}
+
+		return jsonResult(result)
+	})
+}
+
+// AddToolSetAutoTime adds a tool for toggling network provided time
+func AddToolSetAutoTime(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("set_auto_time",
+		mcp.WithDescription("Enable or disable network provided date and time on the Android device"),
+		mcp.WithBoolean("enabled",
+			mcp.Required(),
+			mcp.Description("Whether the device clock is synchronized automatically"),
+		),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		enabled := request.Params.Arguments["enabled"].(bool)
+
+		if err := d.SetAutoTime(enabled); err != nil {
+			return nil, fmt.Errorf("failed to set auto time: %w", err)
+		}
+
+		if enabled {
+			return mcp.NewToolResultText("Automatic date and time enabled"), nil
+		}
+		return mcp.NewToolResultText("Automatic date and time disabled"), nil
+	})
+}
+
+// AddToolSetDateTime adds a tool for setting the device clock
+func AddToolSetDateTime(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("set_date_time",
+		mcp.WithDescription("Disable automatic time and set the date and time of the Android device. Requires an emulator or rooted device. Returns whether the change took effect"),
+		mcp.WithString("date_time",
+			mcp.Required(),
+			mcp.Description("Date and time in RFC 3339 format, e.g. 2024-01-31T09:30:00+08:00"),
+		),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		t, err := time.Parse(time.RFC3339, request.Params.Arguments["date_time"].(string))
+		if err != nil {
+			return nil, fmt.Errorf("invalid date_time: %w", err)
+		}
+
+		result, err := d.SetDateTime(t)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set date and time: %w", err)
+		}
+
+		return jsonResult(result)
+	})
+}
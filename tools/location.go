@@ -0,0 +1,113 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"mcp-android-adb-server/device"
+	"os"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// AddToolSetLocation adds a tool for setting a mock location
+func AddToolSetLocation(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("set_location",
+		mcp.WithDescription("Set the GPS location of the Android device. Emulators are set through the emulator console, physical devices need the io.appium.settings mock location helper app"),
+		mcp.WithNumber("latitude",
+			mcp.Required(),
+			mcp.Min(-90),
+			mcp.Max(90),
+			mcp.Description("Latitude in degrees"),
+		),
+		mcp.WithNumber("longitude",
+			mcp.Required(),
+			mcp.Min(-180),
+			mcp.Max(180),
+			mcp.Description("Longitude in degrees"),
+		),
+		mcp.WithNumber("altitude",
+			mcp.DefaultNumber(0),
+			mcp.Description("Altitude in meters, default is 0"),
+		),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		latitude := request.Params.Arguments["latitude"].(float64)
+		longitude := request.Params.Arguments["longitude"].(float64)
+		altitude := optionalNumber(request, "altitude", 0)
+
+		if err := d.SetLocation(latitude, longitude, altitude); err != nil {
+			return nil, fmt.Errorf("failed to set location: %w", err)
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("Location set to %g,%g", latitude, longitude)), nil
+	})
+}
+
+// AddToolPlayRoute adds a tool for moving the location along a GPX or KML track
+func AddToolPlayRoute(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("play_route",
+		mcp.WithDescription("Move the GPS location of the Android device along a GPX or KML track in the background. Use stop_route to stop early"),
+		mcp.WithString("file_path",
+			mcp.Description("Local path of the GPX or KML file"),
+		),
+		mcp.WithString("content",
+			mcp.Description("GPX or KML document, used when file_path is omitted"),
+		),
+		mcp.WithNumber("speed_kmh",
+			mcp.DefaultNumber(30),
+			mcp.Description("Travel speed in kilometers per hour, default is 30"),
+		),
+		mcp.WithNumber("interval_ms",
+			mcp.DefaultNumber(1000),
+			mcp.Min(100),
+			mcp.Description("Time between location updates in milliseconds, default is 1000"),
+		),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		data := []byte(optionalString(request, "content", ""))
+		if filePath := optionalString(request, "file_path", ""); filePath != "" {
+			var err error
+			if data, err = os.ReadFile(filePath); err != nil {
+				return nil, fmt.Errorf("failed to read route: %w", err)
+			}
+		}
+		if len(data) == 0 {
+			return nil, fmt.Errorf("file_path or content is required")
+		}
+
+		points, err := device.ParseRoute(data)
+		if err != nil {
+			return nil, err
+		}
+
+		speed := optionalNumber(request, "speed_kmh", 30) / 3.6
+		interval := time.Duration(optionalNumber(request, "interval_ms", 1000)) * time.Millisecond
+
+		info, err := d.PlayRoute(points, speed, interval)
+		if err != nil {
+			return nil, fmt.Errorf("failed to play route: %w", err)
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("Playing %d points over %.0f meters, arriving in %s",
+			info.Waypoints, info.Distance, info.Duration.Round(time.Second))), nil
+	})
+}
+
+// AddToolStopRoute adds a tool for stopping the route being played
+func AddToolStopRoute(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("stop_route",
+		mcp.WithDescription("Stop the route started by play_route, the location stays at the current point"),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		if err := d.StopRoute(); err != nil {
+			return nil, fmt.Errorf("route stopped with error: %w", err)
+		}
+
+		return mcp.NewToolResultText("Route stopped"), nil
+	})
+}
@@ -0,0 +1,93 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"mcp-android-adb-server/device"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// AddToolListNotifications adds a tool for listing notifications
+func AddToolListNotifications(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("list_notifications",
+		mcp.WithDescription("List the active notifications on the Android device as JSON, including package, title, text, post time, key and actions"),
+		mcp.WithString("package_name",
+			mcp.Description("Only list notifications posted by this package, e.g. com.example.app"),
+		),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		packageName := optionalString(request, "package_name", "")
+
+		notifications, err := d.ListNotifications(packageName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list notifications: %w", err)
+		}
+
+		if len(notifications) == 0 {
+			return mcp.NewToolResultText("No notifications found on the device"), nil
+		}
+
+		return jsonResult(notifications)
+	})
+}
+
+// AddToolOpenNotificationShade adds a tool for expanding the notification shade
+func AddToolOpenNotificationShade(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("open_notification_shade",
+		mcp.WithDescription("Expand the notification shade on the Android device"),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		if err := d.OpenNotificationShade(); err != nil {
+			return nil, fmt.Errorf("failed to open notification shade: %w", err)
+		}
+
+		return mcp.NewToolResultText("Notification shade opened"), nil
+	})
+}
+
+// AddToolClearNotifications adds a tool for dismissing all notifications
+func AddToolClearNotifications(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("clear_notifications",
+		mcp.WithDescription("Dismiss all clearable notifications on the Android device"),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		if err := d.ClearNotifications(); err != nil {
+			return nil, fmt.Errorf("failed to clear notifications: %w", err)
+		}
+
+		return mcp.NewToolResultText("Notifications cleared"), nil
+	})
+}
+
+// AddToolTapNotification adds a tool for opening a notification
+func AddToolTapNotification(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("tap_notification",
+		mcp.WithDescription("Open the first notification matching a package and/or title or text"),
+		mcp.WithString("package_name",
+			mcp.Description("Package that posted the notification, e.g. com.example.app"),
+		),
+		mcp.WithString("match",
+			mcp.Description("Case-insensitive text contained in the notification title or text"),
+		),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		packageName := optionalString(request, "package_name", "")
+		match := optionalString(request, "match", "")
+		if packageName == "" && match == "" {
+			return nil, fmt.Errorf("package_name or match is required")
+		}
+
+		n, err := d.TapNotification(packageName, match)
+		if err != nil {
+			return nil, fmt.Errorf("failed to tap notification: %w", err)
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("Notification %q from %s opened", n.Title, n.Package)), nil
+	})
+}
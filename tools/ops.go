@@ -0,0 +1,60 @@
+package tools
+
+import (
+	"context"
+	"mcp-android-adb-server/device"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// readTools only read device state and may run in parallel with each other
+var readTools = map[string]bool{
+	"screen_size":            true,
+	"screen_dpi":             true,
+	"screenshot":             true,
+	"screenshot_description": true,
+	"system_info":            true,
+	"list_app":               true,
+	"is_app_installed":       true,
+	"is_screen_locked":       true,
+	"is_screen_active":       true,
+	"network_status":         true,
+	"power_status":           true,
+	"list_notifications":     true,
+	"get_clipboard":          true,
+	"get_setting":            true,
+	"list_settings":          true,
+	"list_forwards":          true,
+	"perf_start":             true,
+}
+
+// unlockedTools do not operate on the device and never wait for it
+var unlockedTools = map[string]bool{
+	"connection_status": true,
+	"operation_status":  true,
+	"perf_stop":         true,
+	"stop_route":        true,
+}
+
+// toolClass returns the operation class of a tool, and false for tools that
+// run without taking the device. Tools are mutating unless listed otherwise.
+func toolClass(name string) (device.OpClass, bool) {
+	switch {
+	case unlockedTools[name]:
+		return 0, false
+	case readTools[name]:
+		return device.OpRead, true
+	default:
+		return device.OpMutate, true
+	}
+}
+
+// AddToolOperationStatus adds a tool for getting the operations running and queued on the device
+func AddToolOperationStatus(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("operation_status",
+		mcp.WithDescription("Get the number of tool calls running on and queued for the Android device as JSON. Read-only calls run in parallel, calls changing the device run one at a time in arrival order"),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return jsonResult(d.OperationStatus())
+	})
+}
@@ -0,0 +1,73 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"mcp-android-adb-server/device"
+	"mcp-android-adb-server/perf"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// perfMonitor holds the sampling sessions started by perf_start
+var perfMonitor = perf.NewMonitor()
+
+// AddToolPerfStart adds a tool for starting performance sampling
+func AddToolPerfStart(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("perf_start",
+		mcp.WithDescription("Start sampling CPU, memory (PSS), frame rate, jank and network usage of a running application"),
+		mcp.WithString("package_name",
+			mcp.Required(),
+			mcp.Description("Android application package name, e.g. com.example.app"),
+		),
+		mcp.WithNumber("interval_ms",
+			mcp.DefaultNumber(1000),
+			mcp.Min(100),
+			mcp.Description("Sampling interval in milliseconds, default is 1000"),
+		),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		// Sampling outlives the call, so it uses the device without the call context
+		packageName := request.Params.Arguments["package_name"].(string)
+		interval := time.Duration(optionalNumber(request, "interval_ms", 1000)) * time.Millisecond
+
+		if err := perfMonitor.Start(d, packageName, interval); err != nil {
+			return nil, fmt.Errorf("failed to start sampling: %w", err)
+		}
+		d.OnClose("stop performance sampling", func() error {
+			perfMonitor.StopAll()
+			return nil
+		})
+
+		return mcp.NewToolResultText(fmt.Sprintf("Sampling %s every %s, call perf_stop to get the results", packageName, interval)), nil
+	})
+}
+
+// AddToolPerfStop adds a tool for stopping performance sampling
+func AddToolPerfStop(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("perf_stop",
+		mcp.WithDescription("Stop sampling an application and return the time series and summary percentiles as JSON"),
+		mcp.WithString("package_name",
+			mcp.Required(),
+			mcp.Description("Android application package name, e.g. com.example.app"),
+		),
+		mcp.WithBoolean("include_samples",
+			mcp.DefaultBool(true),
+			mcp.Description("Whether to include every sample, or only the summary"),
+		),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		packageName := request.Params.Arguments["package_name"].(string)
+
+		report, err := perfMonitor.Stop(packageName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stop sampling: %w", err)
+		}
+
+		if !optionalBool(request, "include_samples", true) {
+			report.Samples = nil
+		}
+
+		return jsonResult(report)
+	})
+}
@@ -0,0 +1,107 @@
+package tools
+
+import (
+	"context"
+	"mcp-android-adb-server/auth"
+	"mcp-android-adb-server/device"
+	"mcp-android-adb-server/policy"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Policy decides which shell commands and tools may run
+var Policy = policy.Default()
+
+// destructiveTools lose data or the connection to the device
+var destructiveTools = map[string]bool{
+	"uninstall_app":  true,
+	"adb_disconnect": true,
+	"adb_tcpip":      true,
+}
+
+// writeTools change the device or end the work of another client, although
+// they run alongside reads or without taking the device
+var writeTools = map[string]bool{
+	"perf_start": true,
+	"perf_stop":  true,
+	"stop_route": true,
+}
+
+// toolRisk returns the risk class of a tool, readTools and unlockedTools
+// only decide how tools take the device
+func toolRisk(name string) policy.Risk {
+	switch {
+	case name == "shell_command":
+		// Checked per command, declared with the worst case
+		return policy.RiskDestructive
+	case destructiveTools[name]:
+		return policy.RiskDestructive
+	case writeTools[name]:
+		return policy.RiskWrite
+	case readTools[name] || unlockedTools[name]:
+		return policy.RiskRead
+	default:
+		return policy.RiskWrite
+	}
+}
+
+// checkPolicy returns an error if the policy refuses the tool call
+func checkPolicy(name string, request mcp.CallToolRequest) error {
+	confirmed := optionalBool(request, "confirm", false)
+
+	if name == "shell_command" {
+		command, _ := request.Params.Arguments["command"].(string)
+		return Policy.CheckCommand(command, confirmed)
+	}
+	return Policy.CheckTool(name, toolRisk(name), confirmed)
+}
+
+// callRisk returns the risk of a tool call, shell commands are classified.
+// A shell can hide a write from the classifier, so shell commands are never
+// read operations for the scope of a client
+func callRisk(name string, request mcp.CallToolRequest) policy.Risk {
+	if name == "shell_command" {
+		command, _ := request.Params.Arguments["command"].(string)
+		return max(Policy.Classify(command), policy.RiskWrite)
+	}
+	return toolRisk(name)
+}
+
+// checkIdentity returns an error if the authenticated client may not make
+// the tool call, on the device of the server or the address it names
+func checkIdentity(ctx context.Context, d *device.AndroidDevice, name string, request mcp.CallToolRequest) error {
+	id := auth.FromContext(ctx)
+	if id == nil {
+		return nil
+	}
+
+	if err := id.CheckTool(name, callRisk(name, request)); err != nil {
+		return err
+	}
+	if err := id.CheckDevice(d.Serial()); err != nil {
+		return err
+	}
+	if address, ok := request.Params.Arguments["address"].(string); ok {
+		return id.CheckDevice(address)
+	}
+	return nil
+}
+
+// withConfirm declares the confirm argument on tools the policy may ask to confirm
+func withConfirm(tool mcp.Tool) mcp.Tool {
+	if !Policy.NeedsConfirm(toolRisk(tool.Name)) {
+		return tool
+	}
+
+	properties := make(map[string]interface{}, len(tool.InputSchema.Properties)+1)
+	for k, v := range tool.InputSchema.Properties {
+		properties[k] = v
+	}
+	properties["confirm"] = map[string]interface{}{
+		"type":        "boolean",
+		"description": "Set to true to confirm a risky operation refused by the server policy",
+	}
+	tool.InputSchema.Properties = properties
+
+	return tool
+}
@@ -0,0 +1,158 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"mcp-android-adb-server/device"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// AddToolSetBattery adds a tool for simulating the battery state
+func AddToolSetBattery(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("set_battery",
+		mcp.WithDescription("Simulate the battery level, charging source and status of the Android device. The real state is restored by reset_battery or when the server exits"),
+		mcp.WithNumber("level",
+			mcp.Min(0),
+			mcp.Max(100),
+			mcp.Description("Battery level percentage, omit to keep the current level"),
+		),
+		mcp.WithString("source",
+			mcp.Enum(device.PowerSourceNone, device.PowerSourceAC, device.PowerSourceUSB, device.PowerSourceWireless),
+			mcp.Description("Charging source, none means unplugged. Omit to keep the current source"),
+		),
+		mcp.WithString("status",
+			mcp.Enum("unknown", "charging", "discharging", "not_charging", "full"),
+			mcp.Description("Battery status, omit to keep the current status"),
+		),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		state := device.BatteryState{
+			Source: optionalString(request, "source", ""),
+			Status: optionalString(request, "status", ""),
+		}
+		if level, ok := request.Params.Arguments["level"].(float64); ok {
+			l := int(level)
+			state.Level = &l
+		}
+
+		if err := d.SetBattery(state); err != nil {
+			return nil, fmt.Errorf("failed to set battery: %w", err)
+		}
+
+		return mcp.NewToolResultText("Battery state simulated"), nil
+	})
+}
+
+// AddToolResetBattery adds a tool for restoring the real battery state
+func AddToolResetBattery(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("reset_battery",
+		mcp.WithDescription("Stop simulating the battery and report the real battery state again"),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		if err := d.ResetBattery(); err != nil {
+			return nil, fmt.Errorf("failed to reset battery: %w", err)
+		}
+
+		return mcp.NewToolResultText("Battery state reset"), nil
+	})
+}
+
+// AddToolSetDoze adds a tool for forcing Doze mode
+func AddToolSetDoze(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("set_doze",
+		mcp.WithDescription("Force the Android device into Doze (deep idle) or bring it back out"),
+		mcp.WithBoolean("enabled",
+			mcp.Required(),
+			mcp.Description("Whether the device is forced into Doze"),
+		),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		enabled := request.Params.Arguments["enabled"].(bool)
+
+		if err := d.SetDoze(enabled); err != nil {
+			return nil, fmt.Errorf("failed to set doze: %w", err)
+		}
+
+		if enabled {
+			return mcp.NewToolResultText("Device forced into Doze"), nil
+		}
+		return mcp.NewToolResultText("Device left Doze"), nil
+	})
+}
+
+// AddToolSetAppInactive adds a tool for marking an application inactive
+func AddToolSetAppInactive(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("set_app_inactive",
+		mcp.WithDescription("Mark an application as inactive or active for App Standby"),
+		mcp.WithString("package_name",
+			mcp.Required(),
+			mcp.Description("Android application package name, e.g. com.example.app"),
+		),
+		mcp.WithBoolean("inactive",
+			mcp.Required(),
+			mcp.Description("Whether the application is inactive"),
+		),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		packageName := request.Params.Arguments["package_name"].(string)
+		inactive := request.Params.Arguments["inactive"].(bool)
+
+		if err := d.SetAppInactive(packageName, inactive); err != nil {
+			return nil, fmt.Errorf("failed to set app inactive: %w", err)
+		}
+
+		if inactive {
+			return mcp.NewToolResultText(fmt.Sprintf("Application %s marked inactive", packageName)), nil
+		}
+		return mcp.NewToolResultText(fmt.Sprintf("Application %s marked active", packageName)), nil
+	})
+}
+
+// AddToolSetStandbyBucket adds a tool for changing the App Standby bucket
+func AddToolSetStandbyBucket(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("set_standby_bucket",
+		mcp.WithDescription("Move an application to an App Standby bucket to test power restrictions"),
+		mcp.WithString("package_name",
+			mcp.Required(),
+			mcp.Description("Android application package name, e.g. com.example.app"),
+		),
+		mcp.WithString("bucket",
+			mcp.Required(),
+			mcp.Enum("active", "working_set", "frequent", "rare", "restricted"),
+			mcp.Description("App Standby bucket"),
+		),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		packageName := request.Params.Arguments["package_name"].(string)
+		bucket := request.Params.Arguments["bucket"].(string)
+
+		if err := d.SetStandbyBucket(packageName, bucket); err != nil {
+			return nil, fmt.Errorf("failed to set standby bucket: %w", err)
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("Application %s moved to standby bucket %s", packageName, bucket)), nil
+	})
+}
+
+// AddToolPowerStatus adds a tool for getting the power status
+func AddToolPowerStatus(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("power_status",
+		mcp.WithDescription("Get the battery level, status, charging source and Doze state of the Android device as JSON"),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		status, err := d.PowerStatus()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get power status: %w", err)
+		}
+
+		return jsonResult(status)
+	})
+}
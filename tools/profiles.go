@@ -0,0 +1,119 @@
+package tools
+
+import (
+	"fmt"
+	"mcp-android-adb-server/policy"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// DefaultProfile registers every tool
+const DefaultProfile = "full"
+
+// profiles name the sets of tools offered to the client, smaller sets leave
+// the model more context
+var profiles = map[string]func(name string) bool{
+	"full": func(string) bool { return true },
+
+	// Tools that only read the device
+	"observe-only": func(name string) bool { return toolRisk(name) == policy.RiskRead },
+
+	"minimal":     oneOf(minimalTools...),
+	"app-testing": oneOf(appTestingTools...),
+}
+
+// minimalTools drive the UI of an app
+var minimalTools = []string{
+	"launch_app", "list_app", "screen_size",
+	"tap", "long_tap", "swipe_up", "swipe_down", "swipe_left", "swipe_right",
+	"back", "input_text", "input_key",
+}
+
+// appTestingTools install, drive and measure an app
+var appTestingTools = []string{
+	"install_app", "uninstall_app", "launch_app", "terminate_app", "list_app", "is_app_installed",
+	"unlock_screen", "lock_screen", "is_screen_locked", "is_screen_active",
+	"screen_size", "screen_dpi", "system_info",
+	"tap", "long_tap", "swipe_up", "swipe_down", "swipe_left", "swipe_right",
+	"back", "input_text", "input_key", "shell_command",
+	"get_clipboard", "set_clipboard", "list_notifications", "tap_notification", "clear_notifications",
+	"set_rotation", "set_dark_mode", "set_locale", "set_app_locale",
+	"measure_startup", "perf_start", "perf_stop", "connection_status", "operation_status",
+}
+
+// optInTools are only registered when included, screenshot returns an image
+// that most clients cannot use and screenshot_description sends screenshots
+// to the visual model
+var optInTools = map[string]bool{
+	"screenshot":             true,
+	"screenshot_description": true,
+}
+
+// selection decides which tools are registered
+var selection = struct {
+	profile func(name string) bool
+	include map[string]bool
+	exclude map[string]bool
+}{
+	profile: profiles[DefaultProfile],
+}
+
+// ProfileNames returns the names of the tool profiles
+func ProfileNames() []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ValidProfile returns an error if there is no profile with the given name
+func ValidProfile(name string) error {
+	if _, ok := profiles[name]; !ok {
+		return fmt.Errorf("unknown tool profile %q, expected one of %s", name, strings.Join(ProfileNames(), ", "))
+	}
+	return nil
+}
+
+// Select registers the tools of a profile and the included ones, except the
+// excluded ones
+func Select(profile string, include, exclude []string) error {
+	if err := ValidProfile(profile); err != nil {
+		return err
+	}
+
+	selection.profile = profiles[profile]
+	selection.include = toSet(include)
+	selection.exclude = toSet(exclude)
+	return nil
+}
+
+// Selected reports whether a tool is registered
+func Selected(name string) bool {
+	switch {
+	case selection.exclude[name]:
+		return false
+	case selection.include[name]:
+		return true
+	case optInTools[name]:
+		return false
+	default:
+		return selection.profile(name)
+	}
+}
+
+func oneOf(names ...string) func(name string) bool {
+	return func(name string) bool {
+		return slices.Contains(names, name)
+	}
+}
+
+func toSet(names []string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[strings.TrimSpace(name)] = true
+	}
+	return set
+}
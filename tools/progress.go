@@ -0,0 +1,41 @@
+package tools
+
+import (
+	"context"
+	"log/slog"
+	"mcp-android-adb-server/device"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// ShellMaxOutput is the default and maximum number of bytes of each output
+// stream returned by shell_command
+var ShellMaxOutput = device.DefaultMaxOutput
+
+// progressNotifier returns a function sending command output to the client
+// as progress notifications, or nil if the client did not ask for progress
+func progressNotifier(ctx context.Context, request mcp.CallToolRequest) func(stream string, data []byte) {
+	if request.Params.Meta == nil || request.Params.Meta.ProgressToken == nil {
+		return nil
+	}
+	s := server.ServerFromContext(ctx)
+	if s == nil {
+		return nil
+	}
+
+	token := request.Params.Meta.ProgressToken
+	progress := 0
+	return func(stream string, data []byte) {
+		progress += len(data)
+		err := s.SendNotificationToClient(ctx, "notifications/progress", map[string]any{
+			"progressToken": token,
+			"progress":      progress,
+			"message":       string(data),
+			"stream":        stream,
+		})
+		if err != nil {
+			slog.Warn("error sending progress", "error", err)
+		}
+	}
+}
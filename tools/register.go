@@ -0,0 +1,126 @@
+package tools
+
+import (
+	"mcp-android-adb-server/device"
+	"slices"
+
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// DeviceTools add the tools available on every device
+var DeviceTools = []func(*server.MCPServer, *device.AndroidDevice){
+	AddToolInstallApp,
+	AddToolUninstallApp,
+	AddToolTerminateApp,
+	AddToolLaunchApp,
+	AddToolListApp,
+	AddToolInstalledApp,
+	AddToolUnlockScreen,
+	AddToolLockScreen,
+	AddToolIsScreenLocked,
+	AddToolIsScreenActive,
+	AddToolInputText,
+	AddToolInputKey,
+	AddToolShellCommand,
+	AddToolSwipeUp,
+	AddToolSwipeDown,
+	AddToolSwipeLeft,
+	AddToolSwipeRight,
+	AddToolScreenSize,
+	AddToolScreenDpi,
+	AddToolSetRotation,
+	AddToolSetAutoRotate,
+	AddToolSetScreenSize,
+	AddToolResetScreenSize,
+	AddToolSetScreenDensity,
+	AddToolResetScreenDensity,
+	AddToolSetDarkMode,
+	AddToolSetLocale,
+	AddToolSetAppLocale,
+	AddToolSetTimeZone,
+	AddToolSetAutoTime,
+	AddToolSetDateTime,
+	AddToolSetWifi,
+	AddToolSetMobileData,
+	AddToolSetAirplaneMode,
+	AddToolSetBluetooth,
+	AddToolNetworkStatus,
+	AddToolAdbConnect,
+	AddToolAdbDisconnect,
+	AddToolAdbPair,
+	AddToolAdbTcpip,
+	AddToolConnectionStatus,
+	AddToolOperationStatus,
+	AddToolForward,
+	AddToolReverse,
+	AddToolListForwards,
+	AddToolRemoveForward,
+	AddToolSetLocation,
+	AddToolPlayRoute,
+	AddToolStopRoute,
+	AddToolSetBattery,
+	AddToolResetBattery,
+	AddToolSetDoze,
+	AddToolSetAppInactive,
+	AddToolSetStandbyBucket,
+	AddToolPowerStatus,
+	AddToolListNotifications,
+	AddToolOpenNotificationShade,
+	AddToolClearNotifications,
+	AddToolTapNotification,
+	AddToolGetClipboard,
+	AddToolSetClipboard,
+	AddToolPerfStart,
+	AddToolPerfStop,
+	AddToolMeasureStartup,
+	AddToolScreenshot,
+	AddToolTap,
+	AddToolLongTap,
+	AddToolBack,
+	AddToolSystemInfo,
+	AddToolGetSetting,
+	AddToolPutSetting,
+	AddToolListSettings,
+	AddToolRestoreSettings,
+}
+
+// EmulatorTools add the tools of the emulator console
+var EmulatorTools = []func(*server.MCPServer, *device.AndroidDevice){
+	AddToolEmulatorGeoFix,
+	AddToolEmulatorSMS,
+	AddToolEmulatorCall,
+	AddToolEmulatorNetwork,
+	AddToolEmulatorSensor,
+	AddToolEmulatorBattery,
+	AddToolEmulatorSnapshot,
+}
+
+// toolNames are the names of every tool, registered or not, in the order of
+// DeviceTools, EmulatorTools and the vision tool
+var toolNames = []string{
+	"install_app", "uninstall_app", "terminate_app", "launch_app", "list_app", "is_app_installed",
+	"unlock_screen", "lock_screen", "is_screen_locked", "is_screen_active",
+	"input_text", "input_key", "shell_command",
+	"swipe_up", "swipe_down", "swipe_left", "swipe_right",
+	"screen_size", "screen_dpi", "set_rotation", "set_auto_rotate",
+	"set_screen_size", "reset_screen_size", "set_screen_density", "reset_screen_density",
+	"set_dark_mode", "set_locale", "set_app_locale", "set_time_zone", "set_auto_time", "set_date_time",
+	"set_wifi", "set_mobile_data", "set_airplane_mode", "set_bluetooth", "network_status",
+	"adb_connect", "adb_disconnect", "adb_pair", "adb_tcpip", "connection_status", "operation_status",
+	"forward", "reverse", "list_forwards", "remove_forward",
+	"set_location", "play_route", "stop_route",
+	"set_battery", "reset_battery", "set_doze", "set_app_inactive", "set_standby_bucket", "power_status",
+	"list_notifications", "open_notification_shade", "clear_notifications", "tap_notification",
+	"get_clipboard", "set_clipboard", "perf_start", "perf_stop", "measure_startup",
+	"screenshot", "tap", "long_tap", "back", "system_info",
+	"get_setting", "put_setting", "list_settings", "restore_settings",
+	"emulator_geo_fix", "emulator_sms", "emulator_call", "emulator_network",
+	"emulator_sensor", "emulator_battery", "emulator_snapshot",
+	"screenshot_description",
+}
+
+// KnownTool reports whether a tool with the given name exists, whether or
+// not it is registered for the device
+func KnownTool(name string) bool {
+	return slices.Contains(toolNames, name)
+}
@@ -0,0 +1,130 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mcp-android-adb-server/device"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// settingsNamespaceOption describes the namespace argument shared by the settings tools
+func settingsNamespaceOption() mcp.ToolOption {
+	return mcp.WithString("namespace",
+		mcp.Required(),
+		mcp.Enum(string(device.SettingsSystem), string(device.SettingsSecure), string(device.SettingsGlobal)),
+		mcp.Description("Settings namespace: system, secure or global"),
+	)
+}
+
+// AddToolGetSetting adds a tool for reading a device setting
+func AddToolGetSetting(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("get_setting",
+		mcp.WithDescription("Read a setting of the Android device from the system, secure or global namespace"),
+		settingsNamespaceOption(),
+		mcp.WithString("key",
+			mcp.Required(),
+			mcp.Description("Setting key, e.g. screen_off_timeout"),
+		),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		namespace, err := device.ParseSettingsNamespace(request.Params.Arguments["namespace"].(string))
+		if err != nil {
+			return nil, err
+		}
+		key := request.Params.Arguments["key"].(string)
+
+		value, err := d.GetSetting(namespace, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get setting: %w", err)
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("%s/%s = %s", namespace, key, value)), nil
+	})
+}
+
+// AddToolPutSetting adds a tool for changing a device setting
+func AddToolPutSetting(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("put_setting",
+		mcp.WithDescription("Change a setting of the Android device. The original value is restored when the server exits"),
+		settingsNamespaceOption(),
+		mcp.WithString("key",
+			mcp.Required(),
+			mcp.Description("Setting key, e.g. screen_off_timeout"),
+		),
+		mcp.WithString("value",
+			mcp.Required(),
+			mcp.Description("New value of the setting"),
+		),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		namespace, err := device.ParseSettingsNamespace(request.Params.Arguments["namespace"].(string))
+		if err != nil {
+			return nil, err
+		}
+		key := request.Params.Arguments["key"].(string)
+		value := request.Params.Arguments["value"].(string)
+
+		if err := d.PutSetting(namespace, key, value); err != nil {
+			return nil, fmt.Errorf("failed to put setting: %w", err)
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("Setting %s/%s changed to %s", namespace, key, value)), nil
+	})
+}
+
+// AddToolListSettings adds a tool for listing device settings
+func AddToolListSettings(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("list_settings",
+		mcp.WithDescription("List all settings of the Android device in the system, secure or global namespace"),
+		settingsNamespaceOption(),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		namespace, err := device.ParseSettingsNamespace(request.Params.Arguments["namespace"].(string))
+		if err != nil {
+			return nil, err
+		}
+
+		settings, err := d.ListSettings(namespace)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list settings: %w", err)
+		}
+
+		values := make(map[string]string, len(settings))
+		for _, setting := range settings {
+			values[setting.Key] = setting.Value
+		}
+
+		jsonString, err := json.Marshal(values)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert settings to JSON: %w", err)
+		}
+
+		return mcp.NewToolResultText(string(jsonString)), nil
+	})
+}
+
+// AddToolRestoreSettings adds a tool for restoring the settings changed during the session
+func AddToolRestoreSettings(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("restore_settings",
+		mcp.WithDescription("Restore all settings changed during this session to their original values"),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		changed := d.ChangedSettings()
+		if len(changed) == 0 {
+			return mcp.NewToolResultText("No settings have been changed"), nil
+		}
+
+		if err := d.RestoreSettings(); err != nil {
+			return nil, fmt.Errorf("failed to restore settings: %w", err)
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("Restored %d setting(s)", len(changed))), nil
+	})
+}
@@ -0,0 +1,49 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"mcp-android-adb-server/device"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// AddToolMeasureStartup adds a tool for measuring application startup time
+func AddToolMeasureStartup(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("measure_startup",
+		mcp.WithDescription("Launch an application several times and return the per-run and aggregate (min/median/p90) launch times as JSON"),
+		mcp.WithString("package_name",
+			mcp.Required(),
+			mcp.Description("Android application package name, e.g. com.example.app"),
+		),
+		mcp.WithNumber("runs",
+			mcp.DefaultNumber(5),
+			mcp.Min(1),
+			mcp.Description("Number of measured launches, default is 5"),
+		),
+		mcp.WithString("mode",
+			mcp.DefaultString(device.StartupCold),
+			mcp.Enum(device.StartupCold, device.StartupWarm, device.StartupHot),
+			mcp.Description("cold force-stops the app before each launch, warm destroys the activity with Don't keep activities and keeps the process, hot moves it to the background"),
+		),
+		mcp.WithBoolean("drop_caches",
+			mcp.DefaultBool(false),
+			mcp.Description("Drop the page cache before each cold launch, requires root"),
+		),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		packageName := request.Params.Arguments["package_name"].(string)
+		runs := int(optionalNumber(request, "runs", 5))
+		mode := optionalString(request, "mode", device.StartupCold)
+		dropCaches := optionalBool(request, "drop_caches", false)
+
+		report, err := d.MeasureStartup(packageName, runs, mode, dropCaches)
+		if err != nil {
+			return nil, fmt.Errorf("failed to measure startup: %w", err)
+		}
+
+		return jsonResult(report)
+	})
+}
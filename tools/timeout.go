@@ -0,0 +1,86 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mcp-android-adb-server/device"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// DefaultTimeout bounds tool calls without a specific timeout
+var DefaultTimeout = device.DefaultTimeout
+
+// toolTimeouts holds the timeouts of tools that run longer than DefaultTimeout
+var toolTimeouts = map[string]time.Duration{
+	"install_app":            10 * time.Minute,
+	"measure_startup":        15 * time.Minute,
+	"screenshot_description": 5 * time.Minute,
+	"emulator_snapshot":      5 * time.Minute,
+}
+
+// ConfigureTimeouts sets the default timeout, unless it is zero, and
+// per-tool timeouts
+func ConfigureTimeouts(defaultTimeout time.Duration, overrides map[string]time.Duration) {
+	if defaultTimeout > 0 {
+		DefaultTimeout = defaultTimeout
+	}
+
+	for name, timeout := range overrides {
+		toolTimeouts[name] = timeout
+	}
+}
+
+// Timeout returns the timeout of a tool
+func Timeout(name string) time.Duration {
+	if timeout, ok := toolTimeouts[name]; ok {
+		return timeout
+	}
+	return DefaultTimeout
+}
+
+// addTool adds a selected tool whose calls are audited, checked against the
+// identity of the client and the policy, cancelled after the tool timeout and
+// wait for their turn on the device according to the tool class
+func addTool(s *server.MCPServer, d *device.AndroidDevice, tool mcp.Tool, handler server.ToolHandlerFunc) {
+	if !Selected(tool.Name) {
+		return
+	}
+
+	s.AddTool(withConfirm(tool), func(ctx context.Context, request mcp.CallToolRequest) (result *mcp.CallToolResult, err error) {
+		defer func(started time.Time) {
+			audit(ctx, tool.Name, request, started, result, err)
+		}(time.Now())
+
+		if err := checkIdentity(ctx, d, tool.Name, request); err != nil {
+			return nil, err
+		}
+		if err := checkPolicy(tool.Name, request); err != nil {
+			return nil, err
+		}
+
+		timeout := Timeout(tool.Name)
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		if class, locked := toolClass(tool.Name); locked {
+			release, err := d.Acquire(ctx, class)
+			if errors.Is(err, context.DeadlineExceeded) {
+				return nil, fmt.Errorf("%s timed out after %s waiting for the device: %w", tool.Name, timeout, err)
+			} else if err != nil {
+				return nil, fmt.Errorf("%s cancelled waiting for the device: %w", tool.Name, err)
+			}
+			defer release()
+		}
+
+		result, err = handler(ctx, request)
+		if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("%s timed out after %s: %w", tool.Name, timeout, err)
+		}
+
+		return result, err
+	})
+}
@@ -3,7 +3,6 @@ package tools
 import (
 	"context"
 	"encoding/base64"
-	"encoding/json"
 	"fmt"
 	"log/slog"
 	"mcp-android-adb-server/device"
@@ -17,13 +16,15 @@ import (
 
 // AddToolInstallApp adds a tool for installing applications
 func AddToolInstallApp(s *server.MCPServer, d *device.AndroidDevice) {
-	s.AddTool(mcp.NewTool("install_app",
+	addTool(s, d, mcp.NewTool("install_app",
 		mcp.WithDescription("Install an application on the Android device"),
 		mcp.WithString("file",
 			mcp.Required(),
 			mcp.Description("Path to the application package file with .apk extension"),
 		),
 	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
 		file := request.Params.Arguments["file"].(string)
 
 		if err := d.InstallApp(file, true); err != nil {
@@ -36,13 +37,15 @@ func AddToolInstallApp(s *server.MCPServer, d *device.AndroidDevice) {
 
 // AddToolUninstallApp adds a tool for uninstalling applications
 func AddToolUninstallApp(s *server.MCPServer, d *device.AndroidDevice) {
-	s.AddTool(mcp.NewTool("uninstall_app",
+	addTool(s, d, mcp.NewTool("uninstall_app",
 		mcp.WithDescription("Uninstall an application from the Android device"),
 		mcp.WithString("package_name",
 			mcp.Required(),
 			mcp.Description("Android application package name, e.g. com.example.app"),
 		),
 	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
 		packageName := request.Params.Arguments["package_name"].(string)
 
 		if err := d.UninstallApp(packageName); err != nil {
@@ -55,13 +58,15 @@ func AddToolUninstallApp(s *server.MCPServer, d *device.AndroidDevice) {
 
 // AddToolTerminateApp adds a tool for terminating running applications
 func AddToolTerminateApp(s *server.MCPServer, d *device.AndroidDevice) {
-	s.AddTool(mcp.NewTool("terminate_app",
+	addTool(s, d, mcp.NewTool("terminate_app",
 		mcp.WithDescription("Terminate a running application on the Android device"),
 		mcp.WithString("package_name",
 			mcp.Required(),
 			mcp.Description("Android application package name, e.g. com.example.app"),
 		),
 	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
 		packageName := request.Params.Arguments["package_name"].(string)
 
 		if err := d.TerminateApp(packageName); err != nil {
@@ -74,15 +79,30 @@ func AddToolTerminateApp(s *server.MCPServer, d *device.AndroidDevice) {
 
 // AddToolLaunchApp adds a tool for launching applications
 func AddToolLaunchApp(s *server.MCPServer, d *device.AndroidDevice) {
-	s.AddTool(mcp.NewTool("launch_app",
+	addTool(s, d, mcp.NewTool("launch_app",
 		mcp.WithDescription("Launch an application on the Android device"),
 		mcp.WithString("package_name",
 			mcp.Required(),
 			mcp.Description("Android application package name, e.g. com.example.app"),
 		),
+		mcp.WithBoolean("measure",
+			mcp.DefaultBool(false),
+			mcp.Description("Launch with 'am start -W' and return the launch state, TotalTime and WaitTime as JSON"),
+		),
 	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
 		packageName := request.Params.Arguments["package_name"].(string)
 
+		if optionalBool(request, "measure", false) {
+			result, err := d.LaunchAppMeasured(packageName)
+			if err != nil {
+				return nil, fmt.Errorf("failed to launch application: %w", err)
+			}
+
+			return jsonResult(result)
+		}
+
 		if err := d.LaunchApp(packageName); err != nil {
 			return nil, fmt.Errorf("failed to launch application: %w", err)
 		}
@@ -93,9 +113,11 @@ func AddToolLaunchApp(s *server.MCPServer, d *device.AndroidDevice) {
 
 // AddToolListApp adds a tool for listing installed applications
 func AddToolListApp(s *server.MCPServer, d *device.AndroidDevice) {
-	s.AddTool(mcp.NewTool("list_app",
+	addTool(s, d, mcp.NewTool("list_app",
 		mcp.WithDescription("List all installed applications on the Android device"),
 	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
 		apps, err := d.ListApp()
 		if err != nil {
 			return nil, fmt.Errorf("failed to get application list: %w", err)
@@ -119,13 +141,15 @@ func AddToolListApp(s *server.MCPServer, d *device.AndroidDevice) {
 
 // AddToolInstalledApp adds a tool for checking if an application is installed
 func AddToolInstalledApp(s *server.MCPServer, d *device.AndroidDevice) {
-	s.AddTool(mcp.NewTool("is_app_installed",
+	addTool(s, d, mcp.NewTool("is_app_installed",
 		mcp.WithDescription("Check if a specific application is installed on the Android device"),
 		mcp.WithString("package_name",
 			mcp.Required(),
 			mcp.Description("Android application package name, e.g. com.example.app"),
 		),
 	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
 		packageName := request.Params.Arguments["package_name"].(string)
 
 		installed, err := d.InstalledApp(packageName)
@@ -143,9 +167,11 @@ func AddToolInstalledApp(s *server.MCPServer, d *device.AndroidDevice) {
 
 // AddToolUnlockScreen adds a tool for unlocking the device screen
 func AddToolUnlockScreen(s *server.MCPServer, d *device.AndroidDevice) {
-	s.AddTool(mcp.NewTool("unlock_screen",
+	addTool(s, d, mcp.NewTool("unlock_screen",
 		mcp.WithDescription("Unlock the Android device screen"),
 	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
 		if err := d.UnlockScreen(); err != nil {
 			return nil, fmt.Errorf("failed to unlock screen: %w", err)
 		}
@@ -156,9 +182,11 @@ func AddToolUnlockScreen(s *server.MCPServer, d *device.AndroidDevice) {
 
 // AddToolLockScreen adds a tool for locking the device screen
 func AddToolLockScreen(s *server.MCPServer, d *device.AndroidDevice) {
-	s.AddTool(mcp.NewTool("lock_screen",
+	addTool(s, d, mcp.NewTool("lock_screen",
 		mcp.WithDescription("Lock the Android device screen"),
 	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
 		if err := d.LockScreen(); err != nil {
 			return nil, fmt.Errorf("failed to lock screen: %w", err)
 		}
@@ -169,9 +197,11 @@ func AddToolLockScreen(s *server.MCPServer, d *device.AndroidDevice) {
 
 // AddToolIsScreenLocked adds a tool for checking if the screen is locked
 func AddToolIsScreenLocked(s *server.MCPServer, d *device.AndroidDevice) {
-	s.AddTool(mcp.NewTool("is_screen_locked",
+	addTool(s, d, mcp.NewTool("is_screen_locked",
 		mcp.WithDescription("Check if the Android device screen is locked"),
 	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
 		locked, err := d.IsScreenLocked()
 		if err != nil {
 			return nil, fmt.Errorf("failed to check screen lock status: %w", err)
@@ -187,9 +217,11 @@ func AddToolIsScreenLocked(s *server.MCPServer, d *device.AndroidDevice) {
 
 // AddToolIsScreenActive adds a tool for checking if the screen is active
 func AddToolIsScreenActive(s *server.MCPServer, d *device.AndroidDevice) {
-	s.AddTool(mcp.NewTool("is_screen_active",
+	addTool(s, d, mcp.NewTool("is_screen_active",
 		mcp.WithDescription("Check if the Android device screen is active"),
 	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
 		active, err := d.IsScreenActive()
 		if err != nil {
 			return nil, fmt.Errorf("failed to check screen active status: %w", err)
@@ -205,16 +237,33 @@ func AddToolIsScreenActive(s *server.MCPServer, d *device.AndroidDevice) {
 
 // AddToolInputText adds a tool for inputting text
 func AddToolInputText(s *server.MCPServer, d *device.AndroidDevice) {
-	s.AddTool(mcp.NewTool("input_text",
+	addTool(s, d, mcp.NewTool("input_text",
 		mcp.WithDescription("Input text on the Android device"),
 		mcp.WithString("text",
 			mcp.Required(),
 			mcp.Description("Text content to input"),
 		),
+		mcp.WithString("method",
+			mcp.DefaultString("auto"),
+			mcp.Enum("auto", "type", "paste"),
+			mcp.Description("How to enter the text: type with key events, paste through the clipboard, or auto to paste long or non-ASCII text"),
+		),
 	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
 		text := request.Params.Arguments["text"].(string)
 
-		if err := d.InputText(text); err != nil {
+		var paste bool
+		switch method := optionalString(request, "method", "auto"); method {
+		case "auto":
+			paste = device.ShouldPaste(text)
+		case "type", "paste":
+			paste = method == "paste"
+		default:
+			return nil, fmt.Errorf("invalid input method: %s", method)
+		}
+
+		if err := d.InputText(text, paste); err != nil {
 			return nil, fmt.Errorf("failed to input text: %w", err)
 		}
 
@@ -224,13 +273,15 @@ func AddToolInputText(s *server.MCPServer, d *device.AndroidDevice) {
 
 // AddToolInputKey adds a tool for inputting key presses
 func AddToolInputKey(s *server.MCPServer, d *device.AndroidDevice) {
-	s.AddTool(mcp.NewTool("input_key",
+	addTool(s, d, mcp.NewTool("input_key",
 		mcp.WithDescription("Input key press on the Android device"),
 		mcp.WithNumber("key_code",
 			mcp.Required(),
 			mcp.Description("Key code to input, e.g. 3 for Home key, 4 for Back key"),
 		),
 	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
 		keyCode := int(request.Params.Arguments["key_code"].(float64))
 
 		if err := d.InputKey(keyCode); err != nil {
@@ -243,29 +294,62 @@ func AddToolInputKey(s *server.MCPServer, d *device.AndroidDevice) {
 
 // AddToolShellCommand adds a tool for executing shell commands
 func AddToolShellCommand(s *server.MCPServer, d *device.AndroidDevice) {
-	s.AddTool(mcp.NewTool("shell_command",
-		mcp.WithDescription("Execute a shell command on the Android device"),
+	addTool(s, d, mcp.NewTool("shell_command",
+		mcp.WithDescription("Execute a shell command on the Android device and return its output, stderr and exit code. Long output is streamed as progress notifications when the client sends a progress token"),
 		mcp.WithString("command",
 			mcp.Required(),
 			mcp.Description("Shell command to execute, input the part after 'shell:', e.g. 'ls -l'"),
 		),
+		mcp.WithBoolean("json",
+			mcp.DefaultBool(false),
+			mcp.Description("Whether to return {exit_code, stdout, stderr, truncated} as JSON instead of text"),
+		),
+		mcp.WithNumber("max_output",
+			mcp.Min(1),
+			mcp.Description("Bytes of stdout and of stderr to return, the rest is dropped, defaults to and is capped at the server limit"),
+		),
 	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
 		command := request.Params.Arguments["command"].(string)
+		maxOutput := min(int(optionalNumber(request, "max_output", float64(ShellMaxOutput))), ShellMaxOutput)
 
-		output, err := d.RunShellCommand(command)
+		result, err := d.Exec(command, device.ExecOptions{
+			MaxOutput: maxOutput,
+			OnOutput:  progressNotifier(ctx, request),
+		})
 		if err != nil {
 			return nil, fmt.Errorf("failed to execute shell command: %w", err)
 		}
 
-		return mcp.NewToolResultText(fmt.Sprintf("Command execution result:\n%s", output)), nil
+		if optionalBool(request, "json", false) {
+			return jsonResult(result)
+		}
+
+		text := fmt.Sprintf("Command execution result:\n%s", result.Stdout)
+		if result.Stderr != "" {
+			text += fmt.Sprintf("\nstderr:\n%s", result.Stderr)
+		}
+		if result.ExitCode != 0 {
+			text += fmt.Sprintf("\nExit code: %d", result.ExitCode)
+		}
+		if result.Truncated && maxOutput < ShellMaxOutput {
+			text += fmt.Sprintf("\n[output truncated to %d bytes per stream, use max_output to get more]", maxOutput)
+		} else if result.Truncated {
+			text += fmt.Sprintf("\n[output truncated to the server limit of %d bytes per stream]", maxOutput)
+		}
+
+		return mcp.NewToolResultText(text), nil
 	})
 }
 
 // AddToolSwipeUp adds a tool for swiping up on the screen
 func AddToolSwipeUp(s *server.MCPServer, d *device.AndroidDevice) {
-	s.AddTool(mcp.NewTool("swipe_up",
+	addTool(s, d, mcp.NewTool("swipe_up",
 		mcp.WithDescription("Perform a swipe up gesture on the Android device screen"),
 	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
 		if err := d.SwipeUp(); err != nil {
 			return nil, fmt.Errorf("failed to swipe up: %w", err)
 		}
@@ -276,9 +360,11 @@ func AddToolSwipeUp(s *server.MCPServer, d *device.AndroidDevice) {
 
 // AddToolSwipeDown adds a tool for swiping down on the screen
 func AddToolSwipeDown(s *server.MCPServer, d *device.AndroidDevice) {
-	s.AddTool(mcp.NewTool("swipe_down",
+	addTool(s, d, mcp.NewTool("swipe_down",
 		mcp.WithDescription("Perform a swipe down gesture on the Android device screen"),
 	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
 		if err := d.SwipeDown(); err != nil {
 			return nil, fmt.Errorf("failed to swipe down: %w", err)
 		}
@@ -289,9 +375,11 @@ func AddToolSwipeDown(s *server.MCPServer, d *device.AndroidDevice) {
 
 // AddToolSwipeLeft adds a tool for swiping left on the screen
 func AddToolSwipeLeft(s *server.MCPServer, d *device.AndroidDevice) {
-	s.AddTool(mcp.NewTool("swipe_left",
+	addTool(s, d, mcp.NewTool("swipe_left",
 		mcp.WithDescription("Perform a swipe left gesture on the Android device screen"),
 	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
 		if err := d.SwipeLeft(); err != nil {
 			return nil, fmt.Errorf("failed to swipe left: %w", err)
 		}
@@ -302,9 +390,11 @@ func AddToolSwipeLeft(s *server.MCPServer, d *device.AndroidDevice) {
 
 // AddToolSwipeRight adds a tool for swiping right on the screen
 func AddToolSwipeRight(s *server.MCPServer, d *device.AndroidDevice) {
-	s.AddTool(mcp.NewTool("swipe_right",
+	addTool(s, d, mcp.NewTool("swipe_right",
 		mcp.WithDescription("Perform a swipe right gesture on the Android device screen"),
 	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
 		if err := d.SwipeRight(); err != nil {
 			return nil, fmt.Errorf("failed to swipe right: %w", err)
 		}
@@ -315,23 +405,28 @@ func AddToolSwipeRight(s *server.MCPServer, d *device.AndroidDevice) {
 
 // AddToolScreenSize adds a tool for getting screen size information
 func AddToolScreenSize(s *server.MCPServer, d *device.AndroidDevice) {
-	s.AddTool(mcp.NewTool("screen_size",
-		mcp.WithDescription("Get the screen size information of the Android device"),
+	addTool(s, d, mcp.NewTool("screen_size",
+		mcp.WithDescription("Get the effective screen size and orientation of the Android device"),
 	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		width, height, err := d.ScreenSize()
+		d := d.WithContext(ctx)
+
+		display, err := d.Display()
 		if err != nil {
 			return nil, fmt.Errorf("failed to get screen size: %w", err)
 		}
 
-		return mcp.NewToolResultText(fmt.Sprintf("Screen size: %dx%d", width, height)), nil
+		return mcp.NewToolResultText(fmt.Sprintf("Screen size: %dx%d, orientation: %s (rotation %d)",
+			display.Width, display.Height, display.Orientation, display.Rotation)), nil
 	})
 }
 
 // AddToolScreenDpi adds a tool for getting screen DPI information
 func AddToolScreenDpi(s *server.MCPServer, d *device.AndroidDevice) {
-	s.AddTool(mcp.NewTool("screen_dpi",
+	addTool(s, d, mcp.NewTool("screen_dpi",
 		mcp.WithDescription("Get the screen DPI information of the Android device"),
 	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
 		dpi, err := d.ScreenDpi()
 		if err != nil {
 			return nil, fmt.Errorf("failed to get screen DPI: %w", err)
@@ -343,9 +438,11 @@ func AddToolScreenDpi(s *server.MCPServer, d *device.AndroidDevice) {
 
 // AddToolScreenshot adds a tool for taking screenshots
 func AddToolScreenshot(s *server.MCPServer, d *device.AndroidDevice) {
-	s.AddTool(mcp.NewTool("screenshot",
+	addTool(s, d, mcp.NewTool("screenshot",
 		mcp.WithDescription("Take a screenshot of the Android device screen to analyze operations and verify goals"),
 	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
 		file, err := d.Screenshot()
 		if err != nil {
 			return nil, fmt.Errorf("failed to take screenshot: %w", err)
@@ -367,7 +464,7 @@ func AddToolScreenshot(s *server.MCPServer, d *device.AndroidDevice) {
 
 // AddToolTap adds a tool for tapping on the screen
 func AddToolTap(s *server.MCPServer, d *device.AndroidDevice) {
-	s.AddTool(mcp.NewTool("tap",
+	addTool(s, d, mcp.NewTool("tap",
 		mcp.WithDescription("Perform a tap operation on the Android device screen"),
 		mcp.WithNumber("x",
 			mcp.Required(),
@@ -378,6 +475,8 @@ func AddToolTap(s *server.MCPServer, d *device.AndroidDevice) {
 			mcp.Description("Y coordinate of the tap position"),
 		),
 	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
 		x := int(request.Params.Arguments["x"].(float64))
 		y := int(request.Params.Arguments["y"].(float64))
 
@@ -391,7 +490,7 @@ func AddToolTap(s *server.MCPServer, d *device.AndroidDevice) {
 
 // AddToolLongTap adds a tool for long-pressing on the screen
 func AddToolLongTap(s *server.MCPServer, d *device.AndroidDevice) {
-	s.AddTool(mcp.NewTool("long_tap",
+	addTool(s, d, mcp.NewTool("long_tap",
 		mcp.WithDescription("Perform a long press operation on the Android device screen"),
 		mcp.WithNumber("x",
 			mcp.Required(),
@@ -402,6 +501,8 @@ func AddToolLongTap(s *server.MCPServer, d *device.AndroidDevice) {
 			mcp.Description("Y coordinate of the long press position"),
 		),
 	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
 		x := int(request.Params.Arguments["x"].(float64))
 		y := int(request.Params.Arguments["y"].(float64))
 
@@ -415,13 +516,15 @@ func AddToolLongTap(s *server.MCPServer, d *device.AndroidDevice) {
 
 // AddToolBack adds a tool for performing back operations
 func AddToolBack(s *server.MCPServer, d *device.AndroidDevice) {
-	s.AddTool(mcp.NewTool("back",
+	addTool(s, d, mcp.NewTool("back",
 		mcp.WithDescription("Perform a back operation on the Android device"),
 		mcp.WithNumber("steps",
 			mcp.DefaultNumber(1),
 			mcp.Description("Number of back steps, default is 1 step, e.g. 2 means go back twice"),
 		),
 	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
 		steps := int(request.Params.Arguments["steps"].(float64))
 
 		if err := d.Back(steps); err != nil {
@@ -434,28 +537,31 @@ func AddToolBack(s *server.MCPServer, d *device.AndroidDevice) {
 
 // AddToolSystemInfo adds a tool for getting device system information
 func AddToolSystemInfo(s *server.MCPServer, d *device.AndroidDevice) {
-	s.AddTool(mcp.NewTool("system_info",
-		mcp.WithDescription("Get system information of the Android device including model, brand, Android version, etc."),
+	addTool(s, d, mcp.NewTool("system_info",
+		mcp.WithDescription("Get system information of the Android device. Groups: build (model, brand, Android version, fingerprint, serial), battery, display, network, location, memory, storage, cpu, gpu, sensors. Groups that cannot be read on this device are reported under errors"),
+		mcp.WithArray("fields",
+			mcp.Description("Groups to collect, all groups if omitted"),
+			mcp.Items(map[string]any{"type": "string", "enum": device.SystemInfoGroups()}),
+		),
 	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		info, err := d.SystemInfo()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get system information: %w", err)
-		}
+		d := d.WithContext(ctx)
 
-		jsonString, err := json.Marshal(info)
+		info, err := d.SystemInfo(optionalStrings(request, "fields")...)
 		if err != nil {
-			return nil, fmt.Errorf("failed to convert system information to JSON: %w", err)
+			return nil, fmt.Errorf("failed to get system information: %w", err)
 		}
 
-		return mcp.NewToolResultText(string(jsonString)), nil
+		return jsonResult(info)
 	})
 }
 
 // AddToolScreenshotDescription adds a tool for getting screenshot description
 func AddToolScreenshotDescription(s *server.MCPServer, d *device.AndroidDevice, m *vision.Model) {
-	s.AddTool(mcp.NewTool("screenshot_description",
+	addTool(s, d, mcp.NewTool("screenshot_description",
 		mcp.WithDescription("Take a screenshot to get description information, used to verify operation results or obtain coordinates of operable elements"),
 	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
 		file, err := d.Screenshot()
 		if err != nil {
 			return nil, fmt.Errorf("failed to take screenshot: %w", err)
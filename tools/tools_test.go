@@ -0,0 +1,364 @@
+package tools_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mcp-android-adb-server/auth"
+	"mcp-android-adb-server/device"
+	"mcp-android-adb-server/policy"
+	"mcp-android-adb-server/tools"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// call returns a tool call request with the given arguments
+func call(name string, arguments map[string]interface{}) mcp.CallToolRequest {
+	var request mcp.CallToolRequest
+	request.Params.Name = name
+	request.Params.Arguments = arguments
+	return request
+}
+
+// send sends a JSON-RPC message to s and returns the response as JSON
+func send(t *testing.T, ctx context.Context, s *server.MCPServer, message string) string {
+	data, err := json.Marshal(s.HandleMessage(ctx, json.RawMessage(message)))
+	if err != nil {
+		t.Fatalf("Failed to encode the response: %v", err)
+	}
+	return string(data)
+}
+
+// listTools returns the sorted names of the tools registered on s
+func listTools(t *testing.T, s *server.MCPServer) []string {
+	var list struct {
+		Result struct {
+			Tools []struct {
+				Name string `json:"name"`
+			} `json:"tools"`
+		} `json:"result"`
+	}
+	response := send(t, context.Background(), s, `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)
+	if err := json.Unmarshal([]byte(response), &list); err != nil {
+		t.Fatalf("Failed to list tools: %v", err)
+	}
+
+	names := make([]string, 0, len(list.Result.Tools))
+	for _, tool := range list.Result.Tools {
+		names = append(names, tool.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// selectTools selects tools for one test
+func selectTools(t *testing.T, profile string, include, exclude []string) {
+	if err := tools.Select(profile, include, exclude); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = tools.Select(tools.DefaultProfile, nil, nil) })
+}
+
+// usePolicy sets the policy for one test
+func usePolicy(t *testing.T, p *policy.Policy) {
+	original := tools.Policy
+	tools.Policy = p
+	t.Cleanup(func() { tools.Policy = original })
+}
+
+// TestRegisteredTools tests that every tool name known to the server and
+// listed by the classes, profiles and timeouts is registered
+func TestRegisteredTools(t *testing.T) {
+	selectTools(t, tools.DefaultProfile, []string{"screenshot", "screenshot_description"}, nil)
+
+	s := server.NewMCPServer("test", "1.0.0")
+	d := device.NewDetachedDevice("emulator-5554")
+	for _, register := range append(slices.Clone(tools.DeviceTools), tools.EmulatorTools...) {
+		register(s, d)
+	}
+	tools.AddToolScreenshotDescription(s, d, nil)
+
+	registered := listTools(t, s)
+	known := slices.Sorted(slices.Values(tools.ToolNames()))
+	if !slices.Equal(registered, known) {
+		t.Errorf("Registered tools do not match the known tools:\nregistered %v\nknown      %v", registered, known)
+	}
+
+	for list, names := range tools.ListedTools() {
+		for _, name := range names {
+			if !slices.Contains(registered, name) {
+				t.Errorf("%s lists %q, which is not a registered tool", list, name)
+			}
+			if !tools.KnownTool(name) {
+				t.Errorf("%s lists %q, which is not a known tool", list, name)
+			}
+		}
+	}
+}
+
+// TestSelected tests the profiles and the include and exclude lists
+func TestSelected(t *testing.T) {
+	tests := []struct {
+		profile  string
+		include  []string
+		exclude  []string
+		selected []string
+		omitted  []string
+	}{
+		{tools.DefaultProfile, nil, nil, []string{"tap", "shell_command", "uninstall_app"}, []string{"screenshot", "screenshot_description"}},
+		{"observe-only", nil, nil, []string{"screen_size", "system_info", "connection_status"}, []string{"tap", "shell_command", "uninstall_app", "screenshot", "perf_start", "stop_route"}},
+		{"minimal", nil, nil, []string{"launch_app", "tap", "input_text"}, []string{"install_app", "shell_command", "screenshot_description"}},
+		{"app-testing", nil, nil, []string{"install_app", "shell_command", "measure_startup"}, []string{"adb_tcpip", "emulator_sms"}},
+		{"minimal", []string{"screenshot", "system_info"}, []string{"tap", "screenshot"}, []string{"system_info", "launch_app"}, []string{"tap", "screenshot"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.profile, func(t *testing.T) {
+			selectTools(t, tt.profile, tt.include, tt.exclude)
+			for _, name := range tt.selected {
+				if !tools.Selected(name) {
+					t.Errorf("Expected %s to be selected", name)
+				}
+			}
+			for _, name := range tt.omitted {
+				if tools.Selected(name) {
+					t.Errorf("Expected %s not to be selected", name)
+				}
+			}
+		})
+	}
+
+	if err := tools.Select("everything", nil, nil); err == nil {
+		t.Error("Should return error for an unknown profile")
+	}
+}
+
+// TestToolClassAndRisk tests the operation and risk classes of tools
+func TestToolClassAndRisk(t *testing.T) {
+	tests := []struct {
+		name   string
+		class  device.OpClass
+		locked bool
+		risk   policy.Risk
+	}{
+		{"screen_size", device.OpRead, true, policy.RiskRead},
+		{"perf_start", device.OpRead, true, policy.RiskWrite},
+		{"connection_status", 0, false, policy.RiskRead},
+		{"perf_stop", 0, false, policy.RiskWrite},
+		{"stop_route", 0, false, policy.RiskWrite},
+		{"tap", device.OpMutate, true, policy.RiskWrite},
+		{"uninstall_app", device.OpMutate, true, policy.RiskDestructive},
+		{"shell_command", device.OpMutate, true, policy.RiskDestructive},
+	}
+
+	for _, tt := range tests {
+		class, locked := tools.ToolClass(tt.name)
+		if class != tt.class || locked != tt.locked {
+			t.Errorf("%s: expected class %v locked %v, got %v %v", tt.name, tt.class, tt.locked, class, locked)
+		}
+		if risk := tools.ToolRisk(tt.name); risk != tt.risk {
+			t.Errorf("%s: expected risk %s, got %s", tt.name, tt.risk, risk)
+		}
+	}
+	// A tool listed as both would silently skip the device lock
+	for _, name := range tools.ListedTools()["readTools"] {
+		if slices.Contains(tools.ListedTools()["unlockedTools"], name) {
+			t.Errorf("%s is listed as both a read and an unlocked tool", name)
+		}
+	}
+}
+
+// TestCheckPolicy tests read-only servers and confirmations
+func TestCheckPolicy(t *testing.T) {
+	readOnly := policy.Default()
+	readOnly.ReadOnly = true
+
+	confirmWrite := policy.Default()
+	risk := policy.RiskWrite
+	confirmWrite.Confirm = &risk
+
+	tests := []struct {
+		name    string
+		policy  *policy.Policy
+		tool    string
+		args    map[string]interface{}
+		allowed bool
+	}{
+		{"read tool", readOnly, "screen_size", nil, true},
+		{"write tool", readOnly, "tap", nil, false},
+		{"read-locked write tool", readOnly, "perf_start", nil, false},
+		{"read shell command", readOnly, "shell_command", map[string]interface{}{"command": "getprop ro.product.model"}, true},
+		{"write shell command", readOnly, "shell_command", map[string]interface{}{"command": "settings put global adb_enabled 0"}, false},
+		{"unconfirmed", confirmWrite, "tap", nil, false},
+		{"confirmed", confirmWrite, "tap", map[string]interface{}{"confirm": true}, true},
+		{"unconfirmed shell command", confirmWrite, "shell_command", map[string]interface{}{"command": "rm /sdcard/a"}, false},
+		{"read without confirmation", confirmWrite, "shell_command", map[string]interface{}{"command": "ls /sdcard"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usePolicy(t, tt.policy)
+			err := tools.CheckPolicy(tt.tool, call(tt.tool, tt.args))
+			if tt.allowed != (err == nil) {
+				t.Errorf("Expected allowed %v, got %v", tt.allowed, err)
+			}
+		})
+	}
+}
+
+// TestAddTool tests that calls are audited, then checked against the
+// identity before the policy, and run with the tool timeout
+func TestAddTool(t *testing.T) {
+	selectTools(t, "minimal", nil, nil)
+	readOnly := policy.Default()
+	readOnly.ReadOnly = true
+	usePolicy(t, readOnly)
+
+	var log bytes.Buffer
+	defer func(logger *slog.Logger) { tools.Audit = logger }(tools.Audit)
+	tools.Audit = slog.New(slog.NewTextHandler(&log, nil))
+
+	s := server.NewMCPServer("test", "1.0.0")
+	d := device.NewDetachedDevice("emulator-5554")
+	var deadline time.Duration
+	handler := func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if expires, ok := ctx.Deadline(); ok {
+			deadline = time.Until(expires)
+		}
+		return mcp.NewToolResultText("ran"), nil
+	}
+	tools.AddTool(s, d, mcp.NewTool("tap"), handler)
+	tools.AddTool(s, d, mcp.NewTool("screen_size"), handler)
+	tools.AddTool(s, d, mcp.NewTool("set_wifi"), handler)
+
+	if names := listTools(t, s); !slices.Equal(names, []string{"screen_size", "tap"}) {
+		t.Errorf("Expected only the selected tools to be registered, got %v", names)
+	}
+
+	callTool := func(ctx context.Context, name string) string {
+		return send(t, ctx, s, fmt.Sprintf(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":%q,"arguments":{}}}`, name))
+	}
+
+	reader := auth.WithIdentity(context.Background(), &auth.Identity{Name: "dashboard", Scope: auth.ScopeRead})
+	if response := callTool(reader, "tap"); !strings.Contains(response, "dashboard has the read scope") {
+		t.Errorf("Expected the identity to refuse the call before the policy, got %s", response)
+	}
+	if response := callTool(context.Background(), "tap"); !strings.Contains(response, "the server is read-only") {
+		t.Errorf("Expected the policy to refuse the call, got %s", response)
+	}
+	if !strings.Contains(log.String(), "tool call failed") || !strings.Contains(log.String(), "identity=dashboard") || !strings.Contains(log.String(), "identity=local") {
+		t.Errorf("Expected refused calls to be audited, got %s", log.String())
+	}
+
+	if response := callTool(reader, "screen_size"); !strings.Contains(response, "ran") {
+		t.Errorf("Expected the call to run, got %s", response)
+	}
+	if deadline <= 0 || deadline > tools.Timeout("screen_size") {
+		t.Errorf("Expected the call to run with the tool timeout, got %s", deadline)
+	}
+}
+
+// TestCheckIdentity tests the scope, tool and device restrictions of clients
+func TestCheckIdentity(t *testing.T) {
+	d := &device.AndroidDevice{}
+	reader := auth.WithIdentity(context.Background(), &auth.Identity{Name: "dashboard", Scope: auth.ScopeRead})
+	writer := auth.WithIdentity(context.Background(), &auth.Identity{Name: "ci", Scope: auth.ScopeWrite, Tools: []string{"tap", "shell_command"}, Devices: []string{""}})
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		tool    string
+		args    map[string]interface{}
+		allowed bool
+	}{
+		{"local client", context.Background(), "uninstall_app", nil, true},
+		{"read tool", reader, "screen_size", nil, true},
+		{"write tool", reader, "tap", nil, false},
+		{"read-locked write tool", reader, "perf_start", nil, false},
+		{"unlocked write tool", reader, "stop_route", nil, false},
+		{"write tool ending sampling", reader, "perf_stop", nil, false},
+		{"unlocked read tool", reader, "connection_status", nil, true},
+		{"read shell command", reader, "shell_command", map[string]interface{}{"command": "getprop ro.build.version.sdk"}, false},
+		{"hidden write", reader, "shell_command", map[string]interface{}{"command": "dumpsys battery unplug"}, false},
+		{"write shell command", writer, "shell_command", map[string]interface{}{"command": "input tap 1 1"}, true},
+		{"destructive shell command", writer, "shell_command", map[string]interface{}{"command": "reboot"}, false},
+		{"tool not listed", writer, "back", nil, false},
+		{"device not listed", writer, "tap", map[string]interface{}{"address": "192.168.1.2:5555"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tools.CheckIdentity(tt.ctx, d, tt.tool, call(tt.tool, tt.args))
+			if tt.allowed && err != nil {
+				t.Errorf("Expected the call to be allowed, got %v", err)
+			}
+			var denied *policy.DeniedError
+			if !tt.allowed && !errors.As(err, &denied) {
+				t.Errorf("Expected the call to be denied, got %v", err)
+			}
+		})
+	}
+}
+
+// TestRedactArguments tests that secrets are kept out of the audit log
+func TestRedactArguments(t *testing.T) {
+	arguments := map[string]interface{}{"address": "192.168.1.2:37000", "code": "123456"}
+	redacted := tools.RedactArguments("adb_pair", arguments)
+	if redacted["code"] != "[redacted]" || redacted["address"] != "192.168.1.2:37000" {
+		t.Errorf("Unexpected redacted arguments: %v", redacted)
+	}
+	if arguments["code"] != "123456" {
+		t.Error("Should not change the arguments of the call")
+	}
+
+	if redacted := tools.RedactArguments("put_setting", map[string]interface{}{"key": "k", "value": "secret"}); redacted["value"] != "[redacted]" || redacted["key"] != "k" {
+		t.Errorf("Expected the setting value to be redacted, got %v", redacted)
+	}
+	if redacted := tools.RedactArguments("get_setting", map[string]interface{}{"value": "v"}); redacted["value"] != "v" {
+		t.Errorf("Expected value to be redacted only for put_setting, got %v", redacted)
+	}
+
+	defer func(names []string) { tools.AuditRedact = names }(tools.AuditRedact)
+	tools.AuditRedact = append(tools.AuditRedact, "emulator_sms.number")
+	if redacted := tools.RedactArguments("emulator_sms", map[string]interface{}{"number": "5551234", "text": "hi"}); redacted["number"] != "[redacted]" || redacted["text"] != "[redacted]" {
+		t.Errorf("Expected the configured argument to be redacted, got %v", redacted)
+	}
+}
+
+// TestHooksRedact tests that redacted arguments and tool results never
+// reach the default logger, which is also the audit log by default
+func TestHooksRedact(t *testing.T) {
+	var log bytes.Buffer
+	defer func(logger *slog.Logger) { slog.SetDefault(logger) }(slog.Default())
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&log, nil)))
+	defer func(logger *slog.Logger) { tools.Audit = logger }(tools.Audit)
+	tools.Audit = nil
+
+	s := server.NewMCPServer("test", "1.0.0", server.WithHooks(tools.Hooks()))
+	tools.AddTool(s, device.NewDetachedDevice("emulator-5554"), mcp.NewTool("connection_status"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("clipboard: hunter2"), nil
+	})
+
+	response := send(t, context.Background(), s, `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"connection_status","arguments":{"code":"482913","text":"p4ssw0rd"}}}`)
+	if !strings.Contains(response, "hunter2") {
+		t.Fatalf("Expected the tool to run, got %s", response)
+	}
+
+	for _, secret := range []string{"482913", "p4ssw0rd", "hunter2"} {
+		if strings.Contains(log.String(), secret) {
+			t.Errorf("Expected %q to be kept out of the log, got %s", secret, log.String())
+		}
+	}
+	if !strings.Contains(log.String(), "calling tool") || !strings.Contains(log.String(), "[redacted]") {
+		t.Errorf("Expected the call to be logged redacted, got %s", log.String())
+	}
+}
@@ -0,0 +1,116 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"mcp-android-adb-server/device"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// AddToolAdbConnect adds a tool for connecting to a device over the network
+func AddToolAdbConnect(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("adb_connect",
+		mcp.WithDescription("Connect the ADB server to a device over Wi-Fi, like adb connect"),
+		mcp.WithString("address",
+			mcp.Required(),
+			mcp.Description("Device address host[:port], the port defaults to 5555"),
+		),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		address := request.Params.Arguments["address"].(string)
+
+		out, err := d.AdbConnect(address)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect: %w", err)
+		}
+
+		return mcp.NewToolResultText(out), nil
+	})
+}
+
+// AddToolAdbDisconnect adds a tool for disconnecting a network device
+func AddToolAdbDisconnect(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("adb_disconnect",
+		mcp.WithDescription("Disconnect the ADB server from a device connected over Wi-Fi, like adb disconnect"),
+		mcp.WithString("address",
+			mcp.Required(),
+			mcp.Description("Device address host[:port], the port defaults to 5555"),
+		),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		address := request.Params.Arguments["address"].(string)
+
+		out, err := d.AdbDisconnect(address)
+		if err != nil {
+			return nil, fmt.Errorf("failed to disconnect: %w", err)
+		}
+
+		return mcp.NewToolResultText(out), nil
+	})
+}
+
+// AddToolAdbPair adds a tool for pairing with a device using a pairing code
+func AddToolAdbPair(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("adb_pair",
+		mcp.WithDescription("Pair the ADB server with an Android 11+ device using the code from Developer options > Wireless debugging > Pair device with pairing code. Connect afterwards with adb_connect to the address shown under Wireless debugging"),
+		mcp.WithString("address",
+			mcp.Required(),
+			mcp.Description("Pairing address host:port shown next to the pairing code"),
+		),
+		mcp.WithString("code",
+			mcp.Required(),
+			mcp.Description("Six digit pairing code"),
+		),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		address := request.Params.Arguments["address"].(string)
+		code := request.Params.Arguments["code"].(string)
+
+		out, err := d.AdbPair(address, code)
+		if err != nil {
+			return nil, fmt.Errorf("failed to pair: %w", err)
+		}
+
+		return mcp.NewToolResultText(out), nil
+	})
+}
+
+// AddToolAdbTcpip adds a tool for switching adbd to TCP mode
+func AddToolAdbTcpip(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("adb_tcpip",
+		mcp.WithDescription("Restart adbd on the USB connected device listening on a TCP port, like adb tcpip, and return the Wi-Fi address to pass to adb_connect"),
+		mcp.WithNumber("port",
+			mcp.DefaultNumber(device.DefaultTcpipPort),
+			mcp.Min(1),
+			mcp.Max(65535),
+			mcp.Description("TCP port, default is 5555"),
+		),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		port := int(optionalNumber(request, "port", device.DefaultTcpipPort))
+
+		address, err := d.AdbTcpip(port)
+		if err != nil {
+			return nil, fmt.Errorf("failed to switch to TCP mode: %w", err)
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("adbd is listening on port %d, connect with adb_connect %s", port, address)), nil
+	})
+}
+
+// AddToolConnectionStatus adds a tool for getting the connection state
+func AddToolConnectionStatus(s *server.MCPServer, d *device.AndroidDevice) {
+	addTool(s, d, mcp.NewTool("connection_status",
+		mcp.WithDescription("Get the state of the connection to the Android device as JSON: connected, reconnecting or disconnected, with the number of reconnects and the last connection error"),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		d := d.WithContext(ctx)
+
+		return jsonResult(d.ConnectionStatus())
+	})
+}